@@ -0,0 +1,218 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// createUserRequest is the body of a user creation request
+type createUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+	ClientID *int64 `json:"client_id"`
+}
+
+// updateUserRoleRequest is the body of a role assignment request
+type updateUserRoleRequest struct {
+	Role     string `json:"role" binding:"required"`
+	ClientID *int64 `json:"client_id"`
+}
+
+// updateUserStatusRequest is the body of a user activation request
+type updateUserStatusRequest struct {
+	Active *bool `json:"active" binding:"required"`
+}
+
+// updateUserPasswordRequest is the body of a password reset request
+type updateUserPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ListUsers retrieves all users
+func ListUsers(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	userService := &models.UserService{DB: db}
+
+	users, err := userService.ListUsers()
+	if err != nil {
+		log.Printf("Error retrieving users: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(200, users)
+}
+
+// GetUser retrieves a single user
+func GetUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	userService := &models.UserService{DB: db}
+
+	user, err := userService.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Error retrieving user %d: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if user == nil {
+		c.JSON(404, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// CreateUser creates a new user with a role
+func CreateUser(c *gin.Context) {
+	var req createUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	user := models.User{
+		Username: req.Username,
+		Role:     models.Role(req.Role),
+		ClientID: req.ClientID,
+		Active:   true,
+	}
+	if err := user.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidatePassword(req.Password); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	userService := &models.UserService{DB: db}
+	err := userService.CreateUser(&user, req.Password)
+	if errors.Is(err, models.ErrDuplicateUsername) {
+		c.JSON(409, gin.H{"error": "Username already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating user %s: %v", req.Username, err)
+		c.JSON(500, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	c.JSON(201, user)
+}
+
+// UpdateUserRole assigns a role to a user
+func UpdateUserRole(c *gin.Context) {
+	var req updateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	updateUser(c, func(us *models.UserService, user *models.User) (int, error) {
+		user.Role = models.Role(req.Role)
+		user.ClientID = req.ClientID
+		if err := user.Validate(); err != nil {
+			return 400, err
+		}
+		return 0, us.UpdateRole(user.ID, user.Role, user.ClientID)
+	})
+}
+
+// UpdateUserStatus activates or deactivates a user
+func UpdateUserStatus(c *gin.Context) {
+	var req updateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	updateUser(c, func(us *models.UserService, user *models.User) (int, error) {
+		user.Active = *req.Active
+		return 0, us.SetActive(user.ID, user.Active)
+	})
+}
+
+// UpdateUserPassword resets a user's password
+func UpdateUserPassword(c *gin.Context) {
+	var req updateUserPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := models.ValidatePassword(req.Password); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	updateUser(c, func(us *models.UserService, user *models.User) (int, error) {
+		return 0, us.SetPassword(user.ID, req.Password)
+	})
+}
+
+// DeleteUser deletes a user
+func DeleteUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	userService := &models.UserService{DB: db}
+	if err := userService.DeleteUser(userID); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "User deleted successfully"})
+}
+
+// updateUser loads the user named by the id parameter and applies a change.
+// apply returns a non-zero status with its error for client errors.
+func updateUser(c *gin.Context, apply func(us *models.UserService, user *models.User) (int, error)) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	userService := &models.UserService{DB: db}
+
+	user, err := userService.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Error retrieving user %d: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if user == nil {
+		c.JSON(404, gin.H{"error": "User not found"})
+		return
+	}
+
+	status, err := apply(userService, user)
+	if err != nil && status != 0 {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating user %d: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(200, user)
+}
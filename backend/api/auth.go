@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+	"github.com/minirisk/utils"
+)
+
+// loginRequest is the body of a login request
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Login exchanges a username and password for a signed JWT
+func Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	userService := &models.UserService{DB: db}
+
+	user, err := userService.Authenticate(req.Username, req.Password)
+	if err != nil {
+		log.Printf("Error authenticating user %s: %v", req.Username, err)
+		c.JSON(500, gin.H{"error": "Failed to authenticate"})
+		return
+	}
+	if user == nil {
+		c.JSON(401, gin.H{"error": "Invalid username or password"})
+		return
+	}
+
+	cfg := c.MustGet("config").(*config.Config)
+	now := time.Now()
+	expiresAt := now.Add(cfg.Security.JWTExpiration)
+	token, err := utils.GenerateJWT(cfg.Security.JWTSecret, utils.Claims{
+		Subject:   fmt.Sprintf("%d", user.ID),
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      string(user.Role),
+		ClientID:  user.ClientID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		log.Printf("Error issuing token for user %s: %v", user.Username, err)
+		c.JSON(500, gin.H{"error": "Failed to issue token"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": expiresAt,
+		"user":       user,
+	})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// ListBorrowRates retrieves the borrow rate of every symbol with one
+func ListBorrowRates(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	borrowService := &models.BorrowService{DB: db}
+	rates, err := borrowService.GetRates()
+	if err != nil {
+		log.Printf("Error retrieving borrow rates: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve borrow rates"})
+		return
+	}
+
+	c.JSON(200, rates)
+}
+
+// SetBorrowRate sets the annual borrow rate of a symbol
+func SetBorrowRate(c *gin.Context) {
+	var req struct {
+		AnnualRate *float64 `json:"annual_rate"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.AnnualRate == nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if *req.AnnualRate < 0 {
+		c.JSON(400, gin.H{"error": "Borrow rate cannot be negative"})
+		return
+	}
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	db := c.MustGet("db").(*sql.DB)
+	borrowService := &models.BorrowService{DB: db}
+	if err := borrowService.SetRate(symbol, *req.AnnualRate); err != nil {
+		log.Printf("Error setting borrow rate for %s: %v", symbol, err)
+		c.JSON(500, gin.H{"error": "Failed to set borrow rate"})
+		return
+	}
+
+	c.JSON(200, gin.H{"symbol": symbol, "annual_rate": *req.AnnualRate})
+}
+
+// GetBorrowFees retrieves the borrow fees accrued on a client's short positions
+// in [from, to)
+func GetBorrowFees(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	to, err := parseTimeParam(c.Query("to"), time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	borrowService := &models.BorrowService{DB: db}
+	accruals, err := borrowService.GetAccruals(clientID, from, to)
+	if err != nil {
+		log.Printf("Error retrieving borrow fees for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve borrow fees"})
+		return
+	}
+
+	var total models.Decimal
+	for _, a := range accruals {
+		total += a.Fee
+	}
+
+	c.JSON(200, gin.H{"from": from, "to": to, "total_fees": total, "accruals": accruals})
+}
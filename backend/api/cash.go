@@ -0,0 +1,127 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/middleware"
+	"github.com/minirisk/models"
+)
+
+// cashRequest is the body of a deposit or withdrawal
+type cashRequest struct {
+	ClientID    int64          `json:"client_id"`
+	Amount      models.Decimal `json:"amount"`
+	Description string         `json:"description"`
+}
+
+// GetCashLedger retrieves the cash ledger entries posted for a client in
+// [from, to), with the loan balance after each
+func GetCashLedger(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	to, err := parseTimeParam(c.Query("to"), time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	cashService := &models.CashService{DB: db}
+	transactions, err := cashService.GetTransactions(clientID, from, to)
+	if err != nil {
+		log.Printf("Error retrieving cash ledger for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve cash ledger"})
+		return
+	}
+
+	c.JSON(200, transactions)
+}
+
+// CreateDeposit pays cash into a client's account
+func CreateDeposit(c *gin.Context) {
+	var req cashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if !middleware.CanAccessClient(c, req.ClientID) {
+		c.JSON(403, gin.H{"error": "Access to this client is not allowed"})
+		return
+	}
+	if req.Amount <= 0 {
+		c.JSON(400, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	cashService := &models.CashService{DB: db}
+	deposit, err := cashService.Deposit(req.ClientID, req.Amount, req.Description)
+	if errors.Is(err, models.ErrNoMarginAccount) {
+		c.JSON(404, gin.H{"error": "Margin data not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording deposit for client %d: %v", req.ClientID, err)
+		c.JSON(500, gin.H{"error": "Failed to record deposit"})
+		return
+	}
+
+	c.JSON(201, deposit)
+}
+
+// CreateWithdrawal pays cash out of a client's account unless it would put the
+// account in margin call
+func CreateWithdrawal(c *gin.Context) {
+	var req cashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if !middleware.CanAccessClient(c, req.ClientID) {
+		c.JSON(403, gin.H{"error": "Access to this client is not allowed"})
+		return
+	}
+	if req.Amount <= 0 {
+		c.JSON(400, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	pricing, err := pricingPolicy(c)
+	if err != nil {
+		log.Printf("Error loading pricing policy: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to record withdrawal"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	cashService := &models.CashService{DB: db, Pricing: pricing, Concentration: concentrationPolicy(c)}
+	withdrawal, status, err := cashService.Withdraw(req.ClientID, req.Amount, req.Description)
+	if errors.Is(err, models.ErrWithdrawalMarginCall) {
+		c.JSON(422, gin.H{"error": "Withdrawal would put the account in margin call", "projected": status})
+		return
+	}
+	if errors.Is(err, models.ErrNoMarginAccount) {
+		c.JSON(404, gin.H{"error": "Margin data not found"})
+		return
+	}
+	if !respondMarginError(c, req.ClientID, err) {
+		return
+	}
+
+	c.JSON(201, gin.H{"withdrawal": withdrawal, "projected": status})
+}
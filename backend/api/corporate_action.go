@@ -0,0 +1,216 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/middleware"
+	"github.com/minirisk/models"
+)
+
+// corporateActionRequest is the body of a new corporate action. Dates are
+// YYYY-MM-DD or RFC3339.
+type corporateActionRequest struct {
+	Symbol         string                     `json:"symbol"`
+	Type           models.CorporateActionType `json:"type"`
+	ExDate         string                     `json:"ex_date"`
+	PayDate        string                     `json:"pay_date"`
+	RatioNew       int                        `json:"ratio_new"`
+	RatioOld       int                        `json:"ratio_old"`
+	AmountPerShare float64                    `json:"amount_per_share"`
+	NewSymbol      string                     `json:"new_symbol"`
+	Description    string                     `json:"description"`
+}
+
+// ListCorporateActions retrieves corporate actions, optionally filtered by
+// symbol and status
+func ListCorporateActions(c *gin.Context) {
+	filter := models.CorporateActionFilter{
+		Symbol: strings.ToUpper(c.Query("symbol")),
+		Status: models.CorporateActionStatus(c.Query("status")),
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	actionService := &models.CorporateActionService{DB: db}
+	actions, err := actionService.ListActions(filter)
+	if err != nil {
+		log.Printf("Error retrieving corporate actions: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve corporate actions"})
+		return
+	}
+
+	c.JSON(200, actions)
+}
+
+// GetCorporateAction retrieves a corporate action with the position adjustments
+// it made. Client users only see their own adjustments.
+func GetCorporateAction(c *gin.Context) {
+	actionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid corporate action ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	actionService := &models.CorporateActionService{DB: db}
+
+	action, err := actionService.GetAction(actionID)
+	if err != nil {
+		log.Printf("Error retrieving corporate action %d: %v", actionID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve corporate action"})
+		return
+	}
+	if action == nil {
+		c.JSON(404, gin.H{"error": "Corporate action not found"})
+		return
+	}
+
+	adjustments, err := actionService.GetAdjustments(actionID)
+	if err != nil {
+		log.Printf("Error retrieving adjustments for corporate action %d: %v", actionID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve corporate action"})
+		return
+	}
+	visible := []models.CorporateActionAdjustment{}
+	for _, adj := range adjustments {
+		if middleware.CanAccessClient(c, adj.ClientID) {
+			visible = append(visible, adj)
+		}
+	}
+
+	c.JSON(200, gin.H{"corporate_action": action, "adjustments": visible})
+}
+
+// CreateCorporateAction records a pending corporate action, to be applied on its
+// ex-date
+func CreateCorporateAction(c *gin.Context) {
+	var req corporateActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	exDate, err := parseTimeParam(req.ExDate, time.Time{})
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ex_date, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	action := models.CorporateAction{
+		Symbol:         strings.ToUpper(req.Symbol),
+		Type:           req.Type,
+		ExDate:         exDate.UTC().Truncate(24 * time.Hour),
+		RatioNew:       req.RatioNew,
+		RatioOld:       req.RatioOld,
+		AmountPerShare: req.AmountPerShare,
+		NewSymbol:      strings.ToUpper(req.NewSymbol),
+		Description:    req.Description,
+	}
+	if req.PayDate != "" {
+		payDate, err := parseTimeParam(req.PayDate, time.Time{})
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid pay_date, expected YYYY-MM-DD or RFC3339"})
+			return
+		}
+		payDate = payDate.UTC().Truncate(24 * time.Hour)
+		action.PayDate = &payDate
+	}
+	if err := action.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	actionService := &models.CorporateActionService{DB: db}
+	if err := actionService.CreateAction(&action); err != nil {
+		log.Printf("Error creating corporate action for %s: %v", action.Symbol, err)
+		c.JSON(500, gin.H{"error": "Failed to create corporate action"})
+		return
+	}
+
+	created, err := actionService.GetAction(action.ID)
+	if err != nil || created == nil {
+		log.Printf("Error retrieving corporate action %d: %v", action.ID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve corporate action"})
+		return
+	}
+
+	c.JSON(201, created)
+}
+
+// PreviewCorporateAction reports the adjustments applying a pending corporate
+// action would make, without making them
+func PreviewCorporateAction(c *gin.Context) {
+	runCorporateAction(c, (*models.CorporateActionService).Preview)
+}
+
+// ApplyCorporateAction applies a pending corporate action whose ex-date has been
+// reached, ahead of the scheduled processor
+func ApplyCorporateAction(c *gin.Context) {
+	runCorporateAction(c, (*models.CorporateActionService).Apply)
+}
+
+// CancelCorporateAction cancels a pending corporate action
+func CancelCorporateAction(c *gin.Context) {
+	actionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid corporate action ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	actionService := &models.CorporateActionService{DB: db}
+	found, err := actionService.CancelAction(actionID)
+	if errors.Is(err, models.ErrActionNotPending) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error cancelling corporate action %d: %v", actionID, err)
+		c.JSON(500, gin.H{"error": "Failed to cancel corporate action"})
+		return
+	}
+	if !found {
+		c.JSON(404, gin.H{"error": "Corporate action not found"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Corporate action cancelled successfully"})
+}
+
+// runCorporateAction parses the corporate action ID and applies or previews the
+// action, mapping actions that are not pending, not yet due or split after
+// trades in split shares to 409 and
+// missing FX rates to 422
+func runCorporateAction(c *gin.Context, run func(cas *models.CorporateActionService, id int64) (*models.CorporateActionResult, error)) {
+	actionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid corporate action ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	actionService := &models.CorporateActionService{DB: db}
+	result, err := run(actionService, actionID)
+	switch {
+	case errors.Is(err, models.ErrActionNotPending), errors.Is(err, models.ErrActionNotDue), errors.Is(err, models.ErrTradesSinceExDate):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrNoFXRate):
+		c.JSON(422, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Error applying corporate action %d: %v", actionID, err)
+		c.JSON(500, gin.H{"error": "Failed to apply corporate action"})
+		return
+	case result == nil:
+		c.JSON(404, gin.H{"error": "Corporate action not found"})
+		return
+	}
+
+	c.JSON(200, result)
+}
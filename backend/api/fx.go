@@ -0,0 +1,60 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// ListFXRates retrieves the latest rate of every currency pair
+func ListFXRates(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	fxService := &models.FXService{DB: db}
+	rates, err := fxService.ListRates()
+	if err != nil {
+		log.Printf("Error retrieving FX rates: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve FX rates"})
+		return
+	}
+
+	c.JSON(200, rates)
+}
+
+// SetFXRate sets the rate of a currency pair such as EURUSD
+func SetFXRate(c *gin.Context) {
+	var req struct {
+		Rate float64 `json:"rate"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if req.Rate <= 0 {
+		c.JSON(400, gin.H{"error": "FX rate must be positive"})
+		return
+	}
+
+	from, to, err := models.ParseCurrencyPair(c.Param("pair"))
+	if err == nil {
+		err = models.ValidateCurrency(from)
+	}
+	if err == nil {
+		err = models.ValidateCurrency(to)
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	fxService := &models.FXService{DB: db}
+	if err := fxService.UpdateRate(from, to, req.Rate); err != nil {
+		log.Printf("Error setting FX rate for %s%s: %v", from, to, err)
+		c.JSON(500, gin.H{"error": "Failed to set FX rate"})
+		return
+	}
+
+	c.JSON(200, gin.H{"from_currency": from, "to_currency": to, "rate": req.Rate})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// ListInstruments retrieves the instrument master, optionally filtered by asset
+// class, sector and status
+func ListInstruments(c *gin.Context) {
+	filter := models.InstrumentFilter{
+		AssetClass: models.AssetClass(c.Query("assetClass")),
+		Sector:     c.Query("sector"),
+		Status:     models.InstrumentStatus(c.Query("status")),
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	instrumentService := &models.InstrumentService{DB: db}
+	instruments, err := instrumentService.ListInstruments(filter)
+	if err != nil {
+		log.Printf("Error retrieving instruments: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve instruments"})
+		return
+	}
+
+	c.JSON(200, instruments)
+}
+
+// GetInstrument retrieves the reference data of a symbol
+func GetInstrument(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+	db := c.MustGet("db").(*sql.DB)
+	instrumentService := &models.InstrumentService{DB: db}
+
+	instrument, err := instrumentService.GetInstrument(symbol)
+	if err != nil {
+		log.Printf("Error retrieving instrument %s: %v", symbol, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve instrument"})
+		return
+	}
+	if instrument == nil {
+		c.JSON(404, gin.H{"error": "Instrument not found"})
+		return
+	}
+
+	c.JSON(200, instrument)
+}
+
+// SaveInstrument creates or replaces the reference data of a symbol. Fields
+// missing from the request take the defaults of a new instrument.
+func SaveInstrument(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+	instrument := models.NewInstrument(symbol)
+	if err := c.ShouldBindJSON(&instrument); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	instrument.Symbol = symbol
+	if err := instrument.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	instrumentService := &models.InstrumentService{DB: db}
+	if err := instrumentService.SaveInstrument(&instrument); err != nil {
+		log.Printf("Error saving instrument %s: %v", symbol, err)
+		c.JSON(500, gin.H{"error": "Failed to save instrument"})
+		return
+	}
+
+	saved, err := instrumentService.GetInstrument(symbol)
+	if err != nil || saved == nil {
+		log.Printf("Error retrieving instrument %s: %v", symbol, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve instrument"})
+		return
+	}
+
+	c.JSON(200, saved)
+}
+
+// validateInstrument checks that a quantity of a symbol can be opened, writing
+// the response if not. It returns false once it has rejected the request.
+// Opening nothing is always allowed, so positions can be closed in instruments
+// that are no longer active.
+func validateInstrument(c *gin.Context, db *sql.DB, symbol string, quantity int) bool {
+	if quantity == 0 {
+		return true
+	}
+	instrumentService := &models.InstrumentService{DB: db}
+	instrument, err := instrumentService.GetInstrument(symbol)
+	if err != nil {
+		log.Printf("Error retrieving instrument %s: %v", symbol, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve instrument"})
+		return false
+	}
+	if instrument == nil {
+		c.JSON(422, gin.H{"error": "Unknown symbol: " + symbol})
+		return false
+	}
+	if err := instrument.ValidateQuantity(quantity); err != nil {
+		c.JSON(422, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// GetInterestSchedule retrieves the margin loan rate schedule in effect on date,
+// today by default
+func GetInterestSchedule(c *gin.Context) {
+	date, err := parseTimeParam(c.Query("date"), time.Now())
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid date, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	interestService := &models.InterestService{DB: db}
+	schedule, err := interestService.GetSchedule(date)
+	if err != nil {
+		log.Printf("Error retrieving interest schedule: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve interest schedule"})
+		return
+	}
+	if schedule == nil {
+		c.JSON(404, gin.H{"error": "No benchmark rate in effect"})
+		return
+	}
+
+	c.JSON(200, schedule)
+}
+
+// SetInterestBenchmark sets the benchmark rate from an effective date, today by
+// default
+func SetInterestBenchmark(c *gin.Context) {
+	var req struct {
+		Rate          *float64 `json:"rate"`
+		EffectiveDate string   `json:"effective_date"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Rate == nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if *req.Rate < 0 {
+		c.JSON(400, gin.H{"error": "Benchmark rate cannot be negative"})
+		return
+	}
+	effectiveDate, err := parseTimeParam(req.EffectiveDate, time.Now())
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid effective_date, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	effectiveDate = effectiveDate.UTC().Truncate(24 * time.Hour)
+
+	db := c.MustGet("db").(*sql.DB)
+	interestService := &models.InterestService{DB: db}
+	if err := interestService.SetBenchmarkRate(*req.Rate, effectiveDate); err != nil {
+		log.Printf("Error setting interest benchmark rate: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to set benchmark rate"})
+		return
+	}
+
+	c.JSON(200, gin.H{"rate": *req.Rate, "effective_date": effectiveDate})
+}
+
+// SetInterestTiers replaces the tiers of the margin loan rate schedule
+func SetInterestTiers(c *gin.Context) {
+	var req struct {
+		Tiers []models.InterestTier `json:"tiers"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := models.ValidateInterestTiers(req.Tiers); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	interestService := &models.InterestService{DB: db}
+	if err := interestService.SetTiers(req.Tiers); err != nil {
+		log.Printf("Error setting interest tiers: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to set interest tiers"})
+		return
+	}
+
+	schedule, err := interestService.GetSchedule(time.Now())
+	if err != nil {
+		log.Printf("Error retrieving interest schedule: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve interest schedule"})
+		return
+	}
+	if schedule == nil {
+		c.JSON(200, gin.H{"tiers": req.Tiers})
+		return
+	}
+
+	c.JSON(200, schedule)
+}
+
+// GetInterestHistory retrieves the interest accrued on a client's margin loan
+// in [from, to), split into what has been capitalized and what is still accrued
+func GetInterestHistory(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	to, err := parseTimeParam(c.Query("to"), time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	interestService := &models.InterestService{DB: db}
+	accruals, err := interestService.GetAccruals(clientID, from, to)
+	if err != nil {
+		log.Printf("Error retrieving interest for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve interest"})
+		return
+	}
+
+	var total, capitalized models.Decimal
+	for _, a := range accruals {
+		total += a.Interest
+		if a.Capitalized() {
+			capitalized += a.Interest
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"from":                 from,
+		"to":                   to,
+		"total_interest":       total,
+		"capitalized_interest": capitalized,
+		"accrued_interest":     total - capitalized,
+		"accruals":             accruals,
+	})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+)
+
+// GetLiquidationPlan suggests the orders that would cure a client's margin
+// shortfall. prefer overrides the configured preferences, e.g.
+// prefer=most_liquid,avoid_losses.
+func GetLiquidationPlan(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	cfg := c.MustGet("config").(*config.Config)
+	names := cfg.Risk.LiquidationPreferences
+	if prefer := c.Query("prefer"); prefer != "" {
+		names = strings.Split(prefer, ",")
+	}
+	prefs, err := models.ParseLiquidationPreferences(names)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	marginService, positions, quotes, err := loadMarginPortfolio(c, db, clientID)
+	if err != nil {
+		log.Printf("Error loading portfolio for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to plan liquidation"})
+		return
+	}
+
+	plan, err := marginService.PlanLiquidation(clientID, positions, quotes, prefs)
+	if !respondMarginError(c, clientID, err) {
+		return
+	}
+
+	c.JSON(200, plan)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// ListMaintenanceRules retrieves all maintenance margin rules
+func ListMaintenanceRules(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	ruleService := &models.MaintenanceRuleService{DB: db}
+
+	rules, err := ruleService.ListRules()
+	if err != nil {
+		log.Printf("Error retrieving maintenance rules: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve maintenance rules"})
+		return
+	}
+
+	c.JSON(200, rules)
+}
+
+// CreateMaintenanceRule creates a new maintenance margin rule
+func CreateMaintenanceRule(c *gin.Context) {
+	var rule models.MaintenanceRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	rule.Symbol = strings.ToUpper(rule.Symbol)
+	if err := rule.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	ruleService := &models.MaintenanceRuleService{DB: db}
+	if err := ruleService.CreateRule(&rule); err != nil {
+		log.Printf("Error creating maintenance rule: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to create maintenance rule"})
+		return
+	}
+
+	c.JSON(201, rule)
+}
+
+// UpdateMaintenanceRule replaces an existing maintenance margin rule
+func UpdateMaintenanceRule(c *gin.Context) {
+	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var rule models.MaintenanceRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	rule.ID = ruleID
+	rule.Symbol = strings.ToUpper(rule.Symbol)
+	if err := rule.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	ruleService := &models.MaintenanceRuleService{DB: db}
+	found, err := ruleService.UpdateRule(&rule)
+	if err != nil {
+		log.Printf("Error updating maintenance rule %d: %v", ruleID, err)
+		c.JSON(500, gin.H{"error": "Failed to update maintenance rule"})
+		return
+	}
+	if !found {
+		c.JSON(404, gin.H{"error": "Maintenance rule not found"})
+		return
+	}
+
+	c.JSON(200, rule)
+}
+
+// DeleteMaintenanceRule deletes a maintenance margin rule
+func DeleteMaintenanceRule(c *gin.Context) {
+	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	ruleService := &models.MaintenanceRuleService{DB: db}
+	found, err := ruleService.DeleteRule(ruleID)
+	if err != nil {
+		log.Printf("Error deleting maintenance rule %d: %v", ruleID, err)
+		c.JSON(500, gin.H{"error": "Failed to delete maintenance rule"})
+		return
+	}
+	if !found {
+		c.JSON(404, gin.H{"error": "Maintenance rule not found"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Maintenance rule deleted successfully"})
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/middleware"
+	"github.com/minirisk/models"
+)
+
+// marginCallActionRequest is the body of margin call state change requests
+type marginCallActionRequest struct {
+	Notes string `json:"notes"`
+}
+
+// resolveMarginCallRequest is the body of a margin call resolution request.
+// Resolution is "payment" (apply Amount), "met" or "liquidated".
+type resolveMarginCallRequest struct {
+	Resolution string         `json:"resolution"`
+	Amount     models.Decimal `json:"amount"`
+	Notes      string         `json:"notes"`
+}
+
+// ListMarginCalls retrieves margin calls, optionally filtered by client and status
+func ListMarginCalls(c *gin.Context) {
+	var filter models.MarginCallFilter
+	if clientIDStr := c.Query("clientId"); clientIDStr != "" {
+		clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid client ID"})
+			return
+		}
+		filter.ClientID = clientID
+	}
+	if claims := middleware.GetClaims(c); claims != nil && claims.ClientID != nil {
+		// Client users only see their own calls
+		filter.ClientID = *claims.ClientID
+	}
+	filter.Status = models.MarginCallStatus(c.Query("status"))
+	filter.OpenOnly = c.Query("open") == "true"
+
+	db := c.MustGet("db").(*sql.DB)
+	marginCallService := &models.MarginCallService{DB: db}
+
+	calls, err := marginCallService.ListMarginCalls(filter)
+	if err != nil {
+		log.Printf("Error retrieving margin calls: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve margin calls"})
+		return
+	}
+
+	c.JSON(200, calls)
+}
+
+// GetMarginCall retrieves a margin call with its state history and the latest
+// liquidation plan stored for it
+func GetMarginCall(c *gin.Context) {
+	callID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid margin call ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	marginCallService := &models.MarginCallService{DB: db}
+
+	call, err := marginCallService.GetMarginCall(callID)
+	if err != nil {
+		log.Printf("Error retrieving margin call %d: %v", callID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve margin call"})
+		return
+	}
+	if call == nil || !middleware.CanAccessClient(c, call.ClientID) {
+		c.JSON(404, gin.H{"error": "Margin call not found"})
+		return
+	}
+
+	events, err := marginCallService.GetEvents(callID)
+	if err != nil {
+		log.Printf("Error retrieving events for margin call %d: %v", callID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve margin call"})
+		return
+	}
+
+	planService := &models.LiquidationPlanService{DB: db}
+	plan, err := planService.GetLatestPlan(callID)
+	if err != nil {
+		log.Printf("Error retrieving liquidation plan for margin call %d: %v", callID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve margin call"})
+		return
+	}
+
+	c.JSON(200, gin.H{"margin_call": call, "events": events, "liquidation_plan": plan})
+}
+
+// AcknowledgeMarginCall records a client's acknowledgement of a margin call
+func AcknowledgeMarginCall(c *gin.Context) {
+	var req marginCallActionRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	updateMarginCall(c, func(mcs *models.MarginCallService, id int64) (*models.MarginCall, error) {
+		return mcs.Acknowledge(id, req.Notes)
+	})
+}
+
+// EscalateMarginCall escalates an unmet margin call
+func EscalateMarginCall(c *gin.Context) {
+	var req marginCallActionRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	updateMarginCall(c, func(mcs *models.MarginCallService, id int64) (*models.MarginCall, error) {
+		return mcs.Escalate(id, req.Notes)
+	})
+}
+
+// ResolveMarginCall applies a payment to a margin call or closes it as met or liquidated
+func ResolveMarginCall(c *gin.Context) {
+	var req resolveMarginCallRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	switch req.Resolution {
+	case "", "payment":
+		if req.Amount <= 0 {
+			c.JSON(400, gin.H{"error": "Payment amount must be positive"})
+			return
+		}
+		updateMarginCall(c, func(mcs *models.MarginCallService, id int64) (*models.MarginCall, error) {
+			return mcs.RecordPayment(id, req.Amount, req.Notes)
+		})
+	case "met":
+		updateMarginCall(c, func(mcs *models.MarginCallService, id int64) (*models.MarginCall, error) {
+			return mcs.Resolve(id, req.Notes)
+		})
+	case "liquidated":
+		updateMarginCall(c, func(mcs *models.MarginCallService, id int64) (*models.MarginCall, error) {
+			return mcs.Liquidate(id, req.Notes)
+		})
+	default:
+		c.JSON(400, gin.H{"error": "Invalid resolution, expected payment, met or liquidated"})
+	}
+}
+
+// updateMarginCall parses the margin call ID and applies a state change, mapping
+// missing calls and invalid transitions to 404 and 409
+func updateMarginCall(c *gin.Context, apply func(mcs *models.MarginCallService, id int64) (*models.MarginCall, error)) {
+	callID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid margin call ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	marginCallService := &models.MarginCallService{DB: db}
+
+	existing, err := marginCallService.GetMarginCall(callID)
+	if err != nil {
+		log.Printf("Error retrieving margin call %d: %v", callID, err)
+		c.JSON(500, gin.H{"error": "Failed to update margin call"})
+		return
+	}
+	if existing == nil || !middleware.CanAccessClient(c, existing.ClientID) {
+		c.JSON(404, gin.H{"error": "Margin call not found"})
+		return
+	}
+
+	call, err := apply(marginCallService, callID)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating margin call %d: %v", callID, err)
+		c.JSON(500, gin.H{"error": "Failed to update margin call"})
+		return
+	}
+	if call == nil {
+		c.JSON(404, gin.H{"error": "Margin call not found"})
+		return
+	}
+
+	c.JSON(200, call)
+}
+
+// bindOptionalJSON binds a JSON body if one was sent, writing a 400 response and
+// returning false if it is malformed
+func bindOptionalJSON(c *gin.Context, obj interface{}) bool {
+	if c.Request.ContentLength == 0 {
+		return true
+	}
+	if err := c.ShouldBindJSON(obj); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+)
+
+// ListNotificationChannels retrieves notification channels, optionally for one client
+func ListNotificationChannels(c *gin.Context) {
+	var clientID int64
+	if clientIDStr := c.Query("clientId"); clientIDStr != "" {
+		var err error
+		clientID, err = strconv.ParseInt(clientIDStr, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid client ID"})
+			return
+		}
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	channelService := &models.NotificationChannelService{DB: db}
+
+	channels, err := channelService.GetChannels(clientID)
+	if err != nil {
+		log.Printf("Error retrieving notification channels: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve notification channels"})
+		return
+	}
+
+	// Never return webhook signing secrets
+	for i := range channels {
+		channels[i].Secret = ""
+	}
+
+	c.JSON(200, channels)
+}
+
+// CreateNotificationChannel creates a client or global notification channel
+func CreateNotificationChannel(c *gin.Context) {
+	channel := models.NotificationChannel{Enabled: true}
+	if err := c.ShouldBindJSON(&channel); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := channel.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	cfg := c.MustGet("config").(*config.Config)
+	if err := channel.ValidateTarget(cfg.Notify); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	channelService := &models.NotificationChannelService{DB: db}
+	if err := channelService.CreateChannel(&channel); err != nil {
+		log.Printf("Error creating notification channel: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to create notification channel"})
+		return
+	}
+
+	channel.Secret = ""
+	c.JSON(201, channel)
+}
+
+// DeleteNotificationChannel deletes a notification channel
+func DeleteNotificationChannel(c *gin.Context) {
+	channelID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	channelService := &models.NotificationChannelService{DB: db}
+	if err := channelService.DeleteChannel(channelID); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete notification channel"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Notification channel deleted successfully"})
+}
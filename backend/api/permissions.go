@@ -0,0 +1,102 @@
+package api
+
+import "github.com/minirisk/models"
+
+// routePermissions maps each authenticated route to the permission it requires.
+// Routes missing from this table are denied by the RBAC middleware.
+var routePermissions = map[string]models.Permission{
+	// Market data
+	"GET /api/market-data/:symbol":         models.PermMarketDataRead,
+	"GET /api/market-data/:symbol/history": models.PermMarketDataRead,
+	"POST /api/market-data/":               models.PermMarketDataWrite,
+
+	// Instruments
+	"GET /api/instruments/":        models.PermMarketDataRead,
+	"GET /api/instruments/:symbol": models.PermMarketDataRead,
+	"PUT /api/instruments/:symbol": models.PermInstrumentsManage,
+
+	// Corporate actions
+	"GET /api/corporate-actions/":             models.PermMarketDataRead,
+	"GET /api/corporate-actions/:id":          models.PermMarketDataRead,
+	"POST /api/corporate-actions/":            models.PermCorporateActionsManage,
+	"POST /api/corporate-actions/:id/preview": models.PermCorporateActionsManage,
+	"POST /api/corporate-actions/:id/apply":   models.PermCorporateActionsManage,
+	"DELETE /api/corporate-actions/:id":       models.PermCorporateActionsManage,
+
+	// Positions
+	"GET /api/positions/:clientId": models.PermPositionsRead,
+	"POST /api/positions/":         models.PermPositionsWrite,
+	"PUT /api/positions/:id":       models.PermPositionsWrite,
+	"DELETE /api/positions/:id":    models.PermPositionsWrite,
+
+	// Trades
+	"GET /api/trades/:clientId":          models.PermTradesRead,
+	"GET /api/trades/:clientId/lots":     models.PermTradesRead,
+	"GET /api/trades/:clientId/realized": models.PermTradesRead,
+	"POST /api/trades/":                  models.PermTradesWrite,
+
+	// Margin
+	"GET /api/margin/status/:clientId":           models.PermMarginRead,
+	"POST /api/margin/what-if/:clientId":         models.PermMarginRead,
+	"GET /api/margin/liquidation-plan/:clientId": models.PermMarginRead,
+	"POST /api/margin/":                          models.PermMarginWrite,
+	"GET /api/margin/calls":                      models.PermMarginCallsRead,
+	"GET /api/margin/calls/:id":                  models.PermMarginCallsRead,
+	"POST /api/margin/calls/:id/acknowledge":     models.PermMarginCallsAcknowledge,
+	"POST /api/margin/calls/:id/escalate":        models.PermMarginCallsManage,
+	"POST /api/margin/calls/:id/resolve":         models.PermMarginCallsManage,
+	"GET /api/margin/rules":                      models.PermMarginRead,
+	"POST /api/margin/rules":                     models.PermMarginRulesManage,
+	"PUT /api/margin/rules/:id":                  models.PermMarginRulesManage,
+	"DELETE /api/margin/rules/:id":               models.PermMarginRulesManage,
+
+	// FX rates
+	"GET /api/fx/rates":       models.PermMarketDataRead,
+	"PUT /api/fx/rates/:pair": models.PermMarketDataWrite,
+
+	// Stock borrow
+	"GET /api/borrow/rates":          models.PermMarketDataRead,
+	"PUT /api/borrow/rates/:symbol":  models.PermBorrowManage,
+	"GET /api/borrow/fees/:clientId": models.PermMarginRead,
+
+	// Margin loan interest
+	"GET /api/interest/schedule":          models.PermMarginRead,
+	"PUT /api/interest/benchmark":         models.PermInterestManage,
+	"PUT /api/interest/tiers":             models.PermInterestManage,
+	"GET /api/interest/history/:clientId": models.PermMarginRead,
+
+	// Cash ledger
+	"GET /api/cash/:clientId":    models.PermCashRead,
+	"POST /api/cash/deposits":    models.PermCashWrite,
+	"POST /api/cash/withdrawals": models.PermCashWrite,
+
+	// P&L
+	"GET /api/pnl/:clientId":         models.PermPnLRead,
+	"GET /api/pnl/:clientId/history": models.PermPnLRead,
+
+	// Risk
+	"GET /api/risk/var/:clientId":  models.PermRiskRead,
+	"GET /api/risk/concentrations": models.PermFirmRiskRead,
+
+	// Stress testing
+	"GET /api/stress/scenarios":          models.PermStressRead,
+	"POST /api/stress/scenarios":         models.PermStressManage,
+	"GET /api/stress/scenarios/:id":      models.PermStressRead,
+	"DELETE /api/stress/scenarios/:id":   models.PermStressManage,
+	"POST /api/stress/scenarios/:id/run": models.PermStressManage,
+	"GET /api/stress/scenarios/:id/runs": models.PermStressRead,
+
+	// Notifications
+	"GET /api/notifications/channels":        models.PermNotificationsManage,
+	"POST /api/notifications/channels":       models.PermNotificationsManage,
+	"DELETE /api/notifications/channels/:id": models.PermNotificationsManage,
+
+	// Administration
+	"GET /api/admin/users":              models.PermUsersManage,
+	"POST /api/admin/users":             models.PermUsersManage,
+	"GET /api/admin/users/:id":          models.PermUsersManage,
+	"PUT /api/admin/users/:id/role":     models.PermUsersManage,
+	"PUT /api/admin/users/:id/status":   models.PermUsersManage,
+	"PUT /api/admin/users/:id/password": models.PermUsersManage,
+	"DELETE /api/admin/users/:id":       models.PermUsersManage,
+}
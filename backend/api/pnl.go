@@ -0,0 +1,71 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// GetClientPnL calculates a client's unrealized, day-over-day and realized P&L
+func GetClientPnL(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	pricing, err := pricingPolicy(c)
+	if err != nil {
+		log.Printf("Error loading pricing policy: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to calculate P&L"})
+		return
+	}
+
+	pnlService := &models.PnLService{DB: db, Pricing: pricing}
+	report, err := pnlService.CalculatePnL(clientID)
+	if err != nil {
+		log.Printf("Error calculating P&L for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to calculate P&L"})
+		return
+	}
+
+	c.JSON(200, report)
+}
+
+// GetPnLHistory retrieves a client's daily P&L snapshots in [from, to)
+func GetPnLHistory(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	to, err := parseTimeParam(c.Query("to"), time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	pnlService := &models.PnLService{DB: db}
+	snapshots, err := pnlService.GetSnapshots(clientID, from, to)
+	if err != nil {
+		log.Printf("Error retrieving P&L snapshots for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve P&L history"})
+		return
+	}
+
+	c.JSON(200, snapshots)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// maxHistoryBars caps how many bars a single history request may span
+const maxHistoryBars = 5000
+
+// GetMarketDataHistory retrieves OHLC bars for a symbol over a time range
+func GetMarketDataHistory(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	interval, err := models.ParseBarInterval(c.DefaultQuery("interval", string(models.BarInterval1d)))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid interval, expected 1m, 1h or 1d"})
+		return
+	}
+
+	to, err := parseTimeParam(c.Query("to"), time.Now())
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to time"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from time"})
+		return
+	}
+	if !from.Before(to) {
+		c.JSON(400, gin.H{"error": "from must be before to"})
+		return
+	}
+	if to.Sub(from) > maxHistoryBars*interval.Duration() {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("Time range too large for %s interval", interval)})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	priceHistoryService := &models.PriceHistoryService{DB: db}
+
+	bars, err := priceHistoryService.GetBars(symbol, from, to, interval)
+	if err != nil {
+		log.Printf("Error retrieving price history for %s: %v", symbol, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve price history"})
+		return
+	}
+
+	c.JSON(200, bars)
+}
+
+// parseTimeParam parses an RFC3339 timestamp or a YYYY-MM-DD date, returning
+// defaultValue when the parameter is empty
+func parseTimeParam(value string, defaultValue time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+)
+
+// GetClientVaR calculates historical-simulation VaR and Expected Shortfall for a client
+func GetClientVaR(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	cfg := c.MustGet("config").(*config.Config)
+	params := models.VaRParams{
+		LookbackDays:     cfg.Risk.VaRLookbackDays,
+		ConfidenceLevels: cfg.Risk.VaRConfidenceLevels,
+	}
+	if lookback := c.Query("lookback"); lookback != "" {
+		days, err := strconv.Atoi(lookback)
+		if err != nil || days < 2 {
+			c.JSON(400, gin.H{"error": "Invalid lookback, expected at least 2 days"})
+			return
+		}
+		params.LookbackDays = days
+	}
+	if confidence := c.Query("confidence"); confidence != "" {
+		params.ConfidenceLevels = nil
+		for _, item := range strings.Split(confidence, ",") {
+			level, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil || level <= 0 || level >= 1 {
+				c.JSON(400, gin.H{"error": "Invalid confidence level, expected values in (0, 1)"})
+				return
+			}
+			params.ConfidenceLevels = append(params.ConfidenceLevels, level)
+		}
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+
+	// Get positions
+	positionService := &models.PositionService{DB: db}
+	positions, err := positionService.GetPositionsByClientID(clientID)
+	if err != nil {
+		log.Printf("Error retrieving positions for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve positions"})
+		return
+	}
+
+	// Get current prices for positions
+	var symbols []string
+	for _, position := range positions {
+		symbols = append(symbols, position.Symbol)
+	}
+
+	marketDataService := &models.MarketDataService{DB: db}
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, cfg.Pricing.MaxPriceAge)
+	if err != nil {
+		log.Printf("Error retrieving market data for symbols %v: %v", symbols, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve market data"})
+		return
+	}
+
+	// Calculate VaR
+	riskService := &models.RiskService{DB: db}
+	report, err := riskService.CalculateVaR(clientID, positions, quotes, params)
+	if errors.Is(err, models.ErrInsufficientHistory) {
+		c.JSON(422, gin.H{"error": "Insufficient price history to calculate VaR", "scenarios": report.Scenarios})
+		return
+	}
+	if err != nil {
+		log.Printf("Error calculating VaR for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to calculate VaR"})
+		return
+	}
+
+	c.JSON(200, report)
+}
+
+// GetConcentrationReport lists the largest symbol and sector concentrations
+// across all clients along with the firm's combined exposures
+func GetConcentrationReport(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	pricing, err := pricingPolicy(c)
+	if err != nil {
+		log.Printf("Error loading pricing policy: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to build concentration report"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	concentrationService := &models.ConcentrationService{
+		DB:            db,
+		Pricing:       pricing,
+		Concentration: concentrationPolicy(c),
+	}
+	report, err := concentrationService.BuildReport(limit)
+	if err != nil {
+		log.Printf("Error building concentration report: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to build concentration report"})
+		return
+	}
+
+	c.JSON(200, report)
+}
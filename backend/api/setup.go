@@ -2,24 +2,61 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"log" // Import the log package
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/minirisk/config"
+	"github.com/minirisk/middleware"
 	"github.com/minirisk/models"
 )
 
 // SetupRoutes configures all API routes
-func SetupRoutes(router *gin.Engine) {
+func SetupRoutes(router *gin.Engine, cfg *config.Config) {
+	// Authentication endpoints
+	authGroup := router.Group("/api/auth")
+	{
+		authGroup.POST("/login", Login)
+	}
+
+	// All other endpoints require a valid token and the permission listed in
+	// routePermissions, and client users may only access their own client's data
+	apiGroup := router.Group("/api",
+		middleware.AuthMiddleware(cfg.Security.JWTSecret),
+		middleware.RBACMiddleware(routePermissions),
+		middleware.ClientScopeMiddleware(),
+	)
+
 	// Market data endpoints
-	marketDataGroup := router.Group("/api/market-data")
+	marketDataGroup := apiGroup.Group("/market-data")
 	{
 		marketDataGroup.GET("/:symbol", GetMarketData)
+		marketDataGroup.GET("/:symbol/history", GetMarketDataHistory)
 		marketDataGroup.POST("/", UpdateMarketData)
 	}
 
+	// Instrument master endpoints
+	instrumentGroup := apiGroup.Group("/instruments")
+	{
+		instrumentGroup.GET("/", ListInstruments)
+		instrumentGroup.GET("/:symbol", GetInstrument)
+		instrumentGroup.PUT("/:symbol", SaveInstrument)
+	}
+
+	// Corporate action endpoints
+	corporateActionGroup := apiGroup.Group("/corporate-actions")
+	{
+		corporateActionGroup.GET("/", ListCorporateActions)
+		corporateActionGroup.GET("/:id", GetCorporateAction)
+		corporateActionGroup.POST("/", CreateCorporateAction)
+		corporateActionGroup.POST("/:id/preview", PreviewCorporateAction)
+		corporateActionGroup.POST("/:id/apply", ApplyCorporateAction)
+		corporateActionGroup.DELETE("/:id", CancelCorporateAction)
+	}
+
 	// Position endpoints
-	positionGroup := router.Group("/api/positions")
+	positionGroup := apiGroup.Group("/positions")
 	{
 		positionGroup.GET("/:clientId", GetPositions)
 		positionGroup.POST("/", CreatePosition)
@@ -27,11 +64,108 @@ func SetupRoutes(router *gin.Engine) {
 		positionGroup.DELETE("/:id", DeletePosition)
 	}
 
+	// Trade endpoints
+	tradeGroup := apiGroup.Group("/trades")
+	{
+		tradeGroup.GET("/:clientId", GetTrades)
+		tradeGroup.GET("/:clientId/lots", GetTaxLots)
+		tradeGroup.GET("/:clientId/realized", GetRealizedLots)
+		tradeGroup.POST("/", CreateTrade)
+	}
+
 	// Margin endpoints
-	marginGroup := router.Group("/api/margin")
+	marginGroup := apiGroup.Group("/margin")
 	{
 		marginGroup.GET("/status/:clientId", GetMarginStatus)
+		marginGroup.POST("/what-if/:clientId", EvaluateWhatIf)
+		marginGroup.GET("/liquidation-plan/:clientId", GetLiquidationPlan)
 		marginGroup.POST("/", UpdateMargin)
+		marginGroup.GET("/calls", ListMarginCalls)
+		marginGroup.GET("/calls/:id", GetMarginCall)
+		marginGroup.POST("/calls/:id/acknowledge", AcknowledgeMarginCall)
+		marginGroup.POST("/calls/:id/escalate", EscalateMarginCall)
+		marginGroup.POST("/calls/:id/resolve", ResolveMarginCall)
+		marginGroup.GET("/rules", ListMaintenanceRules)
+		marginGroup.POST("/rules", CreateMaintenanceRule)
+		marginGroup.PUT("/rules/:id", UpdateMaintenanceRule)
+		marginGroup.DELETE("/rules/:id", DeleteMaintenanceRule)
+	}
+
+	// FX rate endpoints
+	fxGroup := apiGroup.Group("/fx")
+	{
+		fxGroup.GET("/rates", ListFXRates)
+		fxGroup.PUT("/rates/:pair", SetFXRate)
+	}
+
+	// Stock borrow endpoints
+	borrowGroup := apiGroup.Group("/borrow")
+	{
+		borrowGroup.GET("/rates", ListBorrowRates)
+		borrowGroup.PUT("/rates/:symbol", SetBorrowRate)
+		borrowGroup.GET("/fees/:clientId", GetBorrowFees)
+	}
+
+	// Margin loan interest endpoints
+	interestGroup := apiGroup.Group("/interest")
+	{
+		interestGroup.GET("/schedule", GetInterestSchedule)
+		interestGroup.PUT("/benchmark", SetInterestBenchmark)
+		interestGroup.PUT("/tiers", SetInterestTiers)
+		interestGroup.GET("/history/:clientId", GetInterestHistory)
+	}
+
+	// Cash ledger endpoints
+	cashGroup := apiGroup.Group("/cash")
+	{
+		cashGroup.GET("/:clientId", GetCashLedger)
+		cashGroup.POST("/deposits", CreateDeposit)
+		cashGroup.POST("/withdrawals", CreateWithdrawal)
+	}
+
+	// P&L endpoints
+	pnlGroup := apiGroup.Group("/pnl")
+	{
+		pnlGroup.GET("/:clientId", GetClientPnL)
+		pnlGroup.GET("/:clientId/history", GetPnLHistory)
+	}
+
+	// Risk endpoints
+	riskGroup := apiGroup.Group("/risk")
+	{
+		riskGroup.GET("/var/:clientId", GetClientVaR)
+		riskGroup.GET("/concentrations", GetConcentrationReport)
+	}
+
+	// Stress testing endpoints
+	stressGroup := apiGroup.Group("/stress")
+	{
+		stressGroup.GET("/scenarios", ListStressScenarios)
+		stressGroup.POST("/scenarios", CreateStressScenario)
+		stressGroup.GET("/scenarios/:id", GetStressScenario)
+		stressGroup.DELETE("/scenarios/:id", DeleteStressScenario)
+		stressGroup.POST("/scenarios/:id/run", RunStressScenario)
+		stressGroup.GET("/scenarios/:id/runs", GetStressRuns)
+	}
+
+	// Notification endpoints
+	notificationGroup := apiGroup.Group("/notifications")
+	{
+		notificationGroup.GET("/channels", ListNotificationChannels)
+		notificationGroup.POST("/channels", CreateNotificationChannel)
+		notificationGroup.DELETE("/channels/:id", DeleteNotificationChannel)
+	}
+
+	// Administration endpoints
+	adminGroup := apiGroup.Group("/admin")
+	{
+		adminGroup.GET("/users", ListUsers)
+		adminGroup.POST("/users", CreateUser)
+		adminGroup.GET("/users/:id", GetUser)
+		adminGroup.PUT("/users/:id/role", UpdateUserRole)
+		adminGroup.PUT("/users/:id/status", UpdateUserStatus)
+		adminGroup.PUT("/users/:id/password", UpdateUserPassword)
+		adminGroup.DELETE("/users/:id", DeleteUser)
 	}
 }
 
@@ -61,6 +195,12 @@ func UpdateMarketData(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if marketData.Currency != "" {
+		if err := models.ValidateCurrency(marketData.Currency); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	db := c.MustGet("db").(*sql.DB)
 	marketDataService := &models.MarketDataService{DB: db}
@@ -94,43 +234,138 @@ func GetPositions(c *gin.Context) {
 	c.JSON(200, positions)
 }
 
-// CreatePosition creates a new position
+// CreatePosition opens a position by booking a buy trade at the cost basis, so
+// that it is recorded in the trade ledger. The symbol must be an active
+// instrument and the quantity a whole number of its lots. With initial margin
+// enforcement the buy is rejected if it would breach initial margin.
 func CreatePosition(c *gin.Context) {
 	var position models.Position
 	if err := c.ShouldBindJSON(&position); err != nil {
 		c.JSON(400, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if !middleware.CanAccessClient(c, position.ClientID) {
+		c.JSON(403, gin.H{"error": "Access to this client is not allowed"})
+		return
+	}
+
+	trade := models.Trade{
+		ClientID: position.ClientID,
+		Symbol:   position.Symbol,
+		Side:     models.TradeBuy,
+		Quantity: position.Quantity,
+		Price:    position.CostBasis,
+	}
+	if err := trade.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	db := c.MustGet("db").(*sql.DB)
-	positionService := &models.PositionService{DB: db}
-	if err := positionService.CreatePosition(&position); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create position"})
+	if !validateInstrument(c, db, trade.Symbol, trade.Quantity) {
+		return
+	}
+	allowed := enforceInitialMargin(c, trade.ClientID, func() (*models.InitialMarginCheck, error) {
+		marginService, positions, quotes, err := loadMarginPortfolio(c, db, trade.ClientID, trade.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		buy := []models.HypotheticalTrade{{Symbol: trade.Symbol, Side: trade.Side, Quantity: trade.Quantity, Price: trade.Price}}
+		return marginService.EvaluateTrades(trade.ClientID, positions, quotes, buy)
+	})
+	if !allowed {
+		return
+	}
+
+	created, err := configuredTradeService(c, db).RecordTrade(&trade)
+	if !respondTradeError(c, trade.ClientID, err) {
 		return
 	}
 
-	c.JSON(201, position)
+	c.JSON(201, created)
 }
 
-// UpdatePosition updates an existing position
+// UpdatePosition changes the quantity of an existing position by booking the
+// difference as a buy or sell trade at cost_basis, so that the position stays
+// in step with the trade ledger and its tax lots. The quantity it opens must be
+// in an active instrument and whole lots. With initial margin enforcement the
+// trade is rejected if it would breach initial margin.
 func UpdatePosition(c *gin.Context) {
+	positionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid position ID"})
+		return
+	}
 	var position models.Position
 	if err := c.ShouldBindJSON(&position); err != nil {
 		c.JSON(400, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if !middleware.CanAccessClient(c, position.ClientID) {
+		c.JSON(403, gin.H{"error": "Access to this client is not allowed"})
+		return
+	}
 
 	db := c.MustGet("db").(*sql.DB)
 	positionService := &models.PositionService{DB: db}
-	if err := positionService.UpdatePosition(&position); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update position"})
+	current, err := positionService.GetPosition(positionID, position.ClientID)
+	if err != nil {
+		log.Printf("Error retrieving position %d: %v", positionID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve position"})
+		return
+	}
+	if current == nil {
+		c.JSON(404, gin.H{"error": "Position not found"})
+		return
+	}
+
+	delta := position.Quantity - current.Quantity
+	if delta == 0 {
+		c.JSON(400, gin.H{"error": "Quantity is unchanged; the cost basis follows the trade ledger"})
+		return
+	}
+	trade := models.Trade{
+		ClientID: current.ClientID,
+		Symbol:   current.Symbol,
+		Side:     models.TradeBuy,
+		Quantity: delta,
+		Price:    position.CostBasis,
+	}
+	if delta < 0 {
+		trade.Side = models.TradeSell
+		trade.Quantity = -delta
+	}
+	if err := trade.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !validateInstrument(c, db, trade.Symbol, models.OpeningQuantity(current.Quantity, trade.Side, trade.Quantity)) {
+		return
+	}
+
+	allowed := enforceInitialMargin(c, trade.ClientID, func() (*models.InitialMarginCheck, error) {
+		marginService, positions, quotes, err := loadMarginPortfolio(c, db, trade.ClientID, trade.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		change := []models.HypotheticalTrade{{Symbol: trade.Symbol, Side: trade.Side, Quantity: trade.Quantity, Price: trade.Price}}
+		return marginService.EvaluateTrades(trade.ClientID, positions, quotes, change)
+	})
+	if !allowed {
+		return
+	}
+
+	updated, err := configuredTradeService(c, db).RecordTrade(&trade)
+	if !respondTradeError(c, trade.ClientID, err) {
 		return
 	}
 
-	c.JSON(200, position)
+	c.JSON(200, gin.H{"trade": trade, "position": updated})
 }
 
-// DeletePosition deletes a position
+// DeletePosition closes a position by booking a sell of a long position, or a
+// buy covering a short one, for its full quantity. The trade is priced at the
+// price query parameter, or the latest market price if it is left out.
 func DeletePosition(c *gin.Context) {
 	positionIDStr := c.Param("id")
 	clientIDStr := c.Query("clientId")
@@ -146,15 +381,63 @@ func DeletePosition(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid client ID"})
 		return
 	}
+	if !middleware.CanAccessClient(c, clientID) {
+		c.JSON(403, gin.H{"error": "Access to this client is not allowed"})
+		return
+	}
 
 	db := c.MustGet("db").(*sql.DB)
 	positionService := &models.PositionService{DB: db}
-	if err := positionService.DeletePosition(positionID, clientID); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete position"})
+	position, err := positionService.GetPosition(positionID, clientID)
+	if err != nil {
+		log.Printf("Error retrieving position %d: %v", positionID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve position"})
+		return
+	}
+	if position == nil {
+		c.JSON(404, gin.H{"error": "Position not found"})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Position deleted successfully"})
+	trade := models.Trade{
+		ClientID: position.ClientID,
+		Symbol:   position.Symbol,
+		Side:     models.TradeSell,
+		Quantity: position.Quantity,
+	}
+	if position.Quantity < 0 {
+		trade.Side = models.TradeBuy
+		trade.Quantity = -position.Quantity
+	}
+	if priceStr := c.Query("price"); priceStr != "" {
+		if trade.Price, err = models.ParseDecimal(priceStr); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid price"})
+			return
+		}
+	} else {
+		marketDataService := &models.MarketDataService{DB: db}
+		marketData, err := marketDataService.GetCurrentPrice(position.Symbol)
+		if err != nil {
+			log.Printf("Error retrieving market data for %s: %v", position.Symbol, err)
+			c.JSON(500, gin.H{"error": "Failed to retrieve market data"})
+			return
+		}
+		if marketData == nil {
+			c.JSON(422, gin.H{"error": "No market price for " + position.Symbol + "; pass price to close the position"})
+			return
+		}
+		trade.Price = models.NewDecimal(marketData.CurrentPrice)
+	}
+	if err := trade.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := configuredTradeService(c, db).RecordTrade(&trade); !respondTradeError(c, trade.ClientID, err) {
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Position closed successfully", "trade": trade})
 }
 
 // GetMarginStatus retrieves the current margin status for a client
@@ -182,8 +465,15 @@ func GetMarginStatus(c *gin.Context) {
 		symbols = append(symbols, position.Symbol)
 	}
 
+	pricing, err := pricingPolicy(c)
+	if err != nil {
+		log.Printf("Error loading pricing policy: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to calculate margin status"})
+		return
+	}
+
 	marketDataService := &models.MarketDataService{DB: db}
-	marketPrices, err := marketDataService.GetMarketDataForSymbols(symbols)
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, pricing.MaxPriceAge())
 	if err != nil {
 		log.Printf("Error retrieving market data for symbols %v: %v", symbols, err) // Log the specific error
 		c.JSON(500, gin.H{"error": "Failed to retrieve market data"})
@@ -191,8 +481,13 @@ func GetMarginStatus(c *gin.Context) {
 	}
 
 	// Calculate margin status
-	marginService := &models.MarginService{DB: db}
-	marginStatus, err := marginService.CalculateMarginStatus(clientID, positions, marketPrices)
+	marginService := &models.MarginService{DB: db, Pricing: pricing, Concentration: concentrationPolicy(c)}
+	marginStatus, err := marginService.CalculateMarginStatus(clientID, positions, quotes)
+	var staleErr *models.StalePriceError
+	if errors.As(err, &staleErr) {
+		c.JSON(503, gin.H{"error": "Market data is stale", "symbols": staleErr.Symbols})
+		return
+	}
 	if err != nil {
 		log.Printf("Error calculating margin status for client %d: %v", clientID, err) // Log the specific error
 		c.JSON(500, gin.H{"error": "Failed to calculate margin status"})
@@ -202,14 +497,46 @@ func GetMarginStatus(c *gin.Context) {
 	c.JSON(200, marginStatus)
 }
 
-// UpdateMargin updates margin data for a client
+// marginRequest is the body of a margin account update. The loan amount is not
+// accepted: it follows the cash ledger.
+type marginRequest struct {
+	ClientID               int64           `json:"client_id"`
+	BaseCurrency           string          `json:"base_currency"`
+	InitialMargin          float64         `json:"initial_margin"`
+	MaintenanceMargin      float64         `json:"maintenance_margin"`
+	ShortMaintenanceMargin float64         `json:"short_maintenance_margin"`
+	LoanAmount             *models.Decimal `json:"loan_amount"`
+}
+
+// UpdateMargin opens a client's margin account or updates its rates
 func UpdateMargin(c *gin.Context) {
-	var margin models.Margin
-	if err := c.ShouldBindJSON(&margin); err != nil {
+	var req marginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if !middleware.CanAccessClient(c, req.ClientID) {
+		c.JSON(403, gin.H{"error": "Access to this client is not allowed"})
+		return
+	}
+	if req.LoanAmount != nil {
+		c.JSON(400, gin.H{"error": "loan_amount follows the cash ledger; use deposits and withdrawals to change it"})
+		return
+	}
+	if req.BaseCurrency != "" {
+		if err := models.ValidateCurrency(req.BaseCurrency); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
+	margin := models.Margin{
+		ClientID:               req.ClientID,
+		BaseCurrency:           req.BaseCurrency,
+		InitialMargin:          req.InitialMargin,
+		MaintenanceMargin:      req.MaintenanceMargin,
+		ShortMaintenanceMargin: req.ShortMaintenanceMargin,
+	}
 	db := c.MustGet("db").(*sql.DB)
 	marginService := &models.MarginService{DB: db}
 	if err := marginService.UpdateMargin(&margin); err != nil {
@@ -219,3 +546,15 @@ func UpdateMargin(c *gin.Context) {
 
 	c.JSON(200, gin.H{"message": "Margin data updated successfully"})
 }
+
+// pricingPolicy builds the margin pricing policy from the configuration in the request context
+func pricingPolicy(c *gin.Context) (models.PricingPolicy, error) {
+	cfg := c.MustGet("config").(*config.Config)
+	return models.NewPricingPolicy(cfg.Pricing)
+}
+
+// concentrationPolicy builds the concentration add-on policy from the configuration in the request context
+func concentrationPolicy(c *gin.Context) models.ConcentrationPolicy {
+	cfg := c.MustGet("config").(*config.Config)
+	return models.NewConcentrationPolicy(cfg.Risk)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+)
+
+// ListStressScenarios retrieves all stress scenarios
+func ListStressScenarios(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	stressService := &models.StressService{DB: db}
+
+	scenarios, err := stressService.ListScenarios()
+	if err != nil {
+		log.Printf("Error retrieving stress scenarios: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve stress scenarios"})
+		return
+	}
+
+	c.JSON(200, scenarios)
+}
+
+// GetStressScenario retrieves a single stress scenario
+func GetStressScenario(c *gin.Context) {
+	scenarioID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid scenario ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	stressService := &models.StressService{DB: db}
+
+	scenario, err := stressService.GetScenario(scenarioID)
+	if err != nil {
+		log.Printf("Error retrieving stress scenario %d: %v", scenarioID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve stress scenario"})
+		return
+	}
+	if scenario == nil {
+		c.JSON(404, gin.H{"error": "Stress scenario not found"})
+		return
+	}
+
+	c.JSON(200, scenario)
+}
+
+// CreateStressScenario creates a new stress scenario
+func CreateStressScenario(c *gin.Context) {
+	var scenario models.StressScenario
+	if err := c.ShouldBindJSON(&scenario); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := scenario.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	stressService := &models.StressService{DB: db}
+	if err := stressService.CreateScenario(&scenario); err != nil {
+		log.Printf("Error creating stress scenario: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to create stress scenario"})
+		return
+	}
+
+	c.JSON(201, scenario)
+}
+
+// DeleteStressScenario deletes a stress scenario
+func DeleteStressScenario(c *gin.Context) {
+	scenarioID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid scenario ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	stressService := &models.StressService{DB: db}
+	if err := stressService.DeleteScenario(scenarioID); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete stress scenario"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Stress scenario deleted successfully"})
+}
+
+// RunStressScenario runs a stress scenario against all clients and records the run
+func RunStressScenario(c *gin.Context) {
+	scenarioID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid scenario ID"})
+		return
+	}
+
+	pricing, err := pricingPolicy(c)
+	if err != nil {
+		log.Printf("Error loading pricing policy: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to run stress scenario"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	stressService := &models.StressService{DB: db, Pricing: pricing, Concentration: concentrationPolicy(c)}
+
+	scenario, err := stressService.GetScenario(scenarioID)
+	if err != nil {
+		log.Printf("Error retrieving stress scenario %d: %v", scenarioID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve stress scenario"})
+		return
+	}
+	if scenario == nil {
+		c.JSON(404, gin.H{"error": "Stress scenario not found"})
+		return
+	}
+
+	run, err := stressService.RunScenario(scenario)
+	if err != nil {
+		log.Printf("Error running stress scenario %d: %v", scenarioID, err)
+		c.JSON(500, gin.H{"error": "Failed to run stress scenario"})
+		return
+	}
+	if err := stressService.SaveRun(run); err != nil {
+		log.Printf("Error saving stress run for scenario %d: %v", scenarioID, err)
+		c.JSON(500, gin.H{"error": "Failed to save stress run"})
+		return
+	}
+
+	c.JSON(200, run)
+}
+
+// GetStressRuns retrieves the most recent runs of a stress scenario
+func GetStressRuns(c *gin.Context) {
+	scenarioID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid scenario ID"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		c.JSON(400, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	stressService := &models.StressService{DB: db}
+
+	runs, err := stressService.GetRuns(scenarioID, limit)
+	if err != nil {
+		log.Printf("Error retrieving stress runs for scenario %d: %v", scenarioID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve stress runs"})
+		return
+	}
+
+	c.JSON(200, runs)
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/config"
+	"github.com/minirisk/middleware"
+	"github.com/minirisk/models"
+)
+
+// tradeRequest is the body of a trade booking. Dates accept YYYY-MM-DD or RFC3339.
+type tradeRequest struct {
+	ClientID       int64                 `json:"client_id"`
+	Symbol         string                `json:"symbol"`
+	Side           models.TradeSide      `json:"side"`
+	Quantity       int                   `json:"quantity"`
+	Price          models.Decimal        `json:"price"`
+	Fees           models.Decimal        `json:"fees"`
+	TradeDate      string                `json:"trade_date"`
+	SettlementDate string                `json:"settlement_date"`
+	ReliefMethod   string                `json:"relief_method"`
+	Lots           []models.LotSelection `json:"lots"`
+}
+
+// GetTrades retrieves the trade ledger for a client
+func GetTrades(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	tradeService := &models.TradeService{DB: db}
+	trades, err := tradeService.GetTradesByClientID(clientID, c.Query("symbol"))
+	if err != nil {
+		log.Printf("Error retrieving trades for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve trades"})
+		return
+	}
+
+	c.JSON(200, trades)
+}
+
+// CreateTrade books a trade and applies it to the client's position. Sells
+// beyond the quantity held open a short position when short selling is allowed.
+// The quantity the trade opens must be in an active instrument and whole lots.
+// With initial margin enforcement the trade is rejected if it would breach
+// initial margin.
+func CreateTrade(c *gin.Context) {
+	var req tradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if !middleware.CanAccessClient(c, req.ClientID) {
+		c.JSON(403, gin.H{"error": "Access to this client is not allowed"})
+		return
+	}
+
+	trade := models.Trade{
+		ClientID: req.ClientID,
+		Symbol:   req.Symbol,
+		Side:     req.Side,
+		Quantity: req.Quantity,
+		Price:    req.Price,
+		Fees:     req.Fees,
+		Lots:     req.Lots,
+	}
+	var err error
+	if trade.TradeDate, err = parseTimeParam(req.TradeDate, time.Time{}); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid trade_date, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	if trade.SettlementDate, err = parseTimeParam(req.SettlementDate, time.Time{}); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid settlement_date, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	if req.ReliefMethod != "" {
+		if trade.ReliefMethod, err = models.ParseReliefMethod(req.ReliefMethod); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := trade.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	positionService := &models.PositionService{DB: db}
+	held, err := positionService.GetPositionBySymbol(trade.ClientID, trade.Symbol)
+	if err != nil {
+		log.Printf("Error retrieving %s position for client %d: %v", trade.Symbol, trade.ClientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve position"})
+		return
+	}
+	var heldQuantity int
+	if held != nil {
+		heldQuantity = held.Quantity
+	}
+	if !validateInstrument(c, db, trade.Symbol, models.OpeningQuantity(heldQuantity, trade.Side, trade.Quantity)) {
+		return
+	}
+	allowed := enforceInitialMargin(c, trade.ClientID, func() (*models.InitialMarginCheck, error) {
+		marginService, positions, quotes, err := loadMarginPortfolio(c, db, trade.ClientID, trade.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		booked := []models.HypotheticalTrade{{Symbol: trade.Symbol, Side: trade.Side, Quantity: trade.Quantity, Price: trade.Price}}
+		return marginService.EvaluateTrades(trade.ClientID, positions, quotes, booked)
+	})
+	if !allowed {
+		return
+	}
+
+	position, err := configuredTradeService(c, db).RecordTrade(&trade)
+	if !respondTradeError(c, trade.ClientID, err) {
+		return
+	}
+
+	c.JSON(201, gin.H{"trade": trade, "position": position})
+}
+
+// respondTradeError writes the response for a trade that could not be booked.
+// It returns true if err is nil and the request should continue.
+func respondTradeError(c *gin.Context, clientID int64, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrInsufficientQuantity):
+		c.JSON(422, gin.H{"error": "Sell quantity exceeds the position held and short selling is disabled"})
+	case errors.Is(err, models.ErrInvalidLotSelection):
+		c.JSON(422, gin.H{"error": "Selected lots are not open or do not hold the requested quantity"})
+	case errors.Is(err, models.ErrNoMarginAccount), errors.Is(err, models.ErrNoFXRate):
+		c.JSON(422, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error recording trade for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to record trade"})
+	}
+	return false
+}
+
+// configuredTradeService returns a TradeService using the configured relief
+// method and short selling policy
+func configuredTradeService(c *gin.Context, db *sql.DB) *models.TradeService {
+	cfg := c.MustGet("config").(*config.Config)
+	return &models.TradeService{
+		DB:           db,
+		ReliefMethod: models.ReliefMethod(cfg.Trading.LotReliefMethod),
+		AllowShort:   cfg.Trading.AllowShortSelling,
+	}
+}
+
+// GetTaxLots retrieves a client's tax lots. open=false includes closed lots.
+func GetTaxLots(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	openOnly := c.DefaultQuery("open", "true") != "false"
+
+	db := c.MustGet("db").(*sql.DB)
+	taxLotService := &models.TaxLotService{DB: db}
+	lots, err := taxLotService.GetLots(clientID, c.Query("symbol"), openOnly)
+	if err != nil {
+		log.Printf("Error retrieving tax lots for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve tax lots"})
+		return
+	}
+
+	c.JSON(200, lots)
+}
+
+// GetRealizedLots retrieves the lots a client closed in [from, to) with the
+// P&L realized on each
+func GetRealizedLots(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	to, err := parseTimeParam(c.Query("to"), time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.AddDate(-1, 0, 0))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from, expected YYYY-MM-DD or RFC3339"})
+		return
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	taxLotService := &models.TaxLotService{DB: db}
+	reliefs, err := taxLotService.GetReliefs(clientID, c.Query("symbol"), from, to)
+	if err != nil {
+		log.Printf("Error retrieving realized lots for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to retrieve realized lots"})
+		return
+	}
+
+	var total models.Decimal
+	for _, r := range reliefs {
+		total += r.RealizedPnL
+	}
+
+	c.JSON(200, gin.H{"from": from, "to": to, "realized_pnl": total, "lots": reliefs})
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+)
+
+// whatIfRequest is the body of a pre-trade margin check
+type whatIfRequest struct {
+	Trades []models.HypotheticalTrade `json:"trades"`
+}
+
+// EvaluateWhatIf applies hypothetical trades to a client's current positions
+// and reports whether the result would still meet initial margin
+func EvaluateWhatIf(c *gin.Context) {
+	clientIDStr := c.Param("clientId")
+	clientID, err := strconv.ParseInt(clientIDStr, 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	var req whatIfRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if len(req.Trades) == 0 {
+		c.JSON(400, gin.H{"error": "At least one trade is required"})
+		return
+	}
+	var symbols []string
+	for i := range req.Trades {
+		if err := req.Trades[i].Validate(); err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("trade %d: %v", i+1, err)})
+			return
+		}
+		symbols = append(symbols, req.Trades[i].Symbol)
+	}
+
+	db := c.MustGet("db").(*sql.DB)
+	marginService, positions, quotes, err := loadMarginPortfolio(c, db, clientID, symbols...)
+	if err != nil {
+		log.Printf("Error loading portfolio for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to evaluate trades"})
+		return
+	}
+
+	// Trades apply in turn, so each opens quantity against the position the
+	// trades before it leave
+	held := make(map[string]int)
+	for _, position := range positions {
+		held[position.Symbol] = position.Quantity
+	}
+	for _, trade := range req.Trades {
+		if !validateInstrument(c, db, trade.Symbol, models.OpeningQuantity(held[trade.Symbol], trade.Side, trade.Quantity)) {
+			return
+		}
+		if trade.Side == models.TradeSell {
+			held[trade.Symbol] -= trade.Quantity
+		} else {
+			held[trade.Symbol] += trade.Quantity
+		}
+	}
+
+	check, err := marginService.EvaluateTrades(clientID, positions, quotes, req.Trades)
+	if !respondMarginError(c, clientID, err) {
+		return
+	}
+
+	c.JSON(200, gin.H{"trades": req.Trades, "check": check})
+}
+
+// loadMarginPortfolio loads a client's positions and quotes for their symbols
+// and any others given, along with a margin service configured from the request
+// context
+func loadMarginPortfolio(c *gin.Context, db *sql.DB, clientID int64, symbols ...string) (*models.MarginService, []models.Position, map[string]models.PriceQuote, error) {
+	pricing, err := pricingPolicy(c)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load pricing policy: %v", err)
+	}
+
+	positionService := &models.PositionService{DB: db}
+	positions, err := positionService.GetPositionsByClientID(clientID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+	for _, position := range positions {
+		symbols = append(symbols, position.Symbol)
+	}
+
+	marketDataService := &models.MarketDataService{DB: db}
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, pricing.MaxPriceAge())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get market prices: %v", err)
+	}
+
+	marginService := &models.MarginService{DB: db, Pricing: pricing, Concentration: concentrationPolicy(c)}
+	return marginService, positions, quotes, nil
+}
+
+// respondMarginError writes the response for a failed margin calculation. It
+// returns true if err is nil and the request should continue.
+func respondMarginError(c *gin.Context, clientID int64, err error) bool {
+	var staleErr *models.StalePriceError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &staleErr):
+		c.JSON(503, gin.H{"error": "Market data is stale", "symbols": staleErr.Symbols})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(404, gin.H{"error": "Margin data not found"})
+	case errors.Is(err, models.ErrNoTradePrice), errors.Is(err, models.ErrNoFXRate):
+		c.JSON(422, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error calculating margin for client %d: %v", clientID, err)
+		c.JSON(500, gin.H{"error": "Failed to calculate margin"})
+	}
+	return false
+}
+
+// enforceInitialMargin runs check when initial margin enforcement is enabled.
+// It returns false once it has rejected the request because the change would
+// breach initial margin or could not be checked. Clients without a margin
+// account are not checked.
+func enforceInitialMargin(c *gin.Context, clientID int64, check func() (*models.InitialMarginCheck, error)) bool {
+	cfg := c.MustGet("config").(*config.Config)
+	if !cfg.Trading.EnforceInitialMargin {
+		return true
+	}
+
+	result, err := check()
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	if !respondMarginError(c, clientID, err) {
+		return false
+	}
+	if !result.Pass {
+		c.JSON(422, gin.H{"error": "Change would breach initial margin", "check": result})
+		return false
+	}
+	return true
+}
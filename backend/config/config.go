@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,10 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Market   MarketConfig
+	Pricing  PricingConfig
+	Risk     RiskConfig
+	Trading  TradingConfig
+	Notify   NotificationConfig
 	Security SecurityConfig
 	CORS     CORSConfig
 }
@@ -33,9 +38,113 @@ type DatabaseConfig struct {
 
 // MarketConfig holds market data-related configuration
 type MarketConfig struct {
+	Provider       string
 	APIKey         string
 	APIURL         string
+	QuoteFile      string
 	UpdateInterval time.Duration
+	Concurrency    int
+	BatchSize      int
+	RequestTimeout time.Duration
+
+	// FX rates are fetched from a provider of their own, configured like the
+	// quote provider. No FX provider leaves rates to be set through the API.
+	FXProvider  string
+	FXAPIKey    string
+	FXAPIURL    string
+	FXQuoteFile string
+	// FXMaxMove is the largest relative change from the stored rate that a
+	// fetched FX rate may make
+	FXMaxMove float64
+}
+
+// FXSource returns the configuration of the FX rate provider in the form the
+// quote provider takes
+func (m MarketConfig) FXSource() MarketConfig {
+	fx := m
+	fx.Provider = m.FXProvider
+	fx.APIKey = m.FXAPIKey
+	fx.APIURL = m.FXAPIURL
+	fx.QuoteFile = m.FXQuoteFile
+	return fx
+}
+
+// PricingConfig holds configuration for valuing positions with stale prices
+type PricingConfig struct {
+	MaxPriceAge       time.Duration
+	StalePricePolicy  string
+	StalePriceHaircut float64
+
+	// FXHaircut is charged on positions quoted in a currency other than the
+	// account's base currency
+	FXHaircut float64
+}
+
+// RiskConfig holds configuration for risk analytics
+type RiskConfig struct {
+	VaRLookbackDays     int
+	VaRConfidenceLevels []float64
+	MarginCheckInterval time.Duration
+	MarginCallDuePeriod time.Duration
+	StressTestInterval  time.Duration
+	PnLSnapshotInterval time.Duration
+
+	// Concentration add-on thresholds as a share of gross market value; zero
+	// disables the add-on for that type
+	ConcentrationSymbolThreshold float64
+	ConcentrationSectorThreshold float64
+	ConcentrationAddOnRate       float64
+
+	// Liquidation plan preferences in order of priority, and whether to plan a
+	// liquidation when a margin call escalates
+	LiquidationPreferences      []string
+	LiquidationPlanOnEscalation bool
+}
+
+// TradingConfig holds configuration for booking trades and short positions
+type TradingConfig struct {
+	LotReliefMethod   string
+	AllowShortSelling bool
+	DefaultBorrowRate float64
+	BorrowFeeInterval time.Duration
+
+	// InterestAccrualInterval is how often margin loan interest is accrued and
+	// capitalized; each loan is charged at most once a day
+	InterestAccrualInterval time.Duration
+
+	// CorporateActionInterval is how often corporate actions that have gone ex
+	// are applied and dividends that have fallen due are paid
+	CorporateActionInterval time.Duration
+
+	// EnforceInitialMargin rejects position changes that would breach initial margin
+	EnforceInitialMargin bool
+}
+
+// NotificationConfig holds configuration for margin call alert delivery.
+// Email, webhook and file sinks configured here receive alerts for every client.
+type NotificationConfig struct {
+	SMTPHost       string
+	SMTPPort       string
+	SMTPUsername   string
+	SMTPPassword   string
+	SMTPFrom       string
+	EmailTo        []string
+	WebhookURL     string
+	WebhookSecret  string
+	WebhookRetries int
+	FilePath       string
+
+	// Channels stored through the API may only post to webhooks with one of
+	// WebhookAllowedSchemes on one of WebhookAllowedHosts, where "*.example.com"
+	// matches any subdomain, and may only write files inside FileDir. Webhook
+	// and file channels are refused when these are empty.
+	WebhookAllowedHosts   []string
+	WebhookAllowedSchemes []string
+	FileDir               string
+
+	// Workers is the number of alerts delivered at once; alerts are queued so
+	// slow or retried deliveries do not hold up margin monitoring
+	Workers int
 }
 
 // SecurityConfig holds security-related configuration
@@ -64,9 +173,69 @@ func LoadConfig() (*Config, error) {
 			Name:     getEnv("DB_NAME", "minirisk"),
 		},
 		Market: MarketConfig{
+			Provider:       getEnv("MARKET_DATA_PROVIDER", "rest"),
 			APIKey:         getEnv("MARKET_DATA_API_KEY", ""),
 			APIURL:         getEnv("MARKET_DATA_API_URL", ""),
+			QuoteFile:      getEnv("MARKET_DATA_QUOTE_FILE", ""),
 			UpdateInterval: getEnvDuration("MARKET_DATA_UPDATE_INTERVAL", 60*time.Second),
+			Concurrency:    getEnvInt("MARKET_DATA_CONCURRENCY", 8),
+			BatchSize:      getEnvInt("MARKET_DATA_BATCH_SIZE", 0),
+			RequestTimeout: getEnvDuration("MARKET_DATA_REQUEST_TIMEOUT", 10*time.Second),
+			FXProvider:     getEnv("FX_PROVIDER", ""),
+			FXAPIKey:       getEnv("FX_API_KEY", ""),
+			FXAPIURL:       getEnv("FX_API_URL", ""),
+			FXQuoteFile:    getEnv("FX_QUOTE_FILE", ""),
+			FXMaxMove:      getEnvFloat("FX_MAX_MOVE", 0.2),
+		},
+		Pricing: PricingConfig{
+			MaxPriceAge:       getEnvDuration("PRICE_MAX_AGE", 5*time.Minute),
+			StalePricePolicy:  getEnv("STALE_PRICE_POLICY", "last_price"),
+			StalePriceHaircut: getEnvFloat("STALE_PRICE_HAIRCUT", 0.10),
+			FXHaircut:         getEnvFloat("FX_HAIRCUT", 0),
+		},
+		Risk: RiskConfig{
+			VaRLookbackDays:     getEnvInt("VAR_LOOKBACK_DAYS", 250),
+			VaRConfidenceLevels: getEnvFloatSlice("VAR_CONFIDENCE_LEVELS", []float64{0.95, 0.99}),
+			MarginCheckInterval: getEnvDuration("MARGIN_CHECK_INTERVAL", time.Minute),
+			MarginCallDuePeriod: getEnvDuration("MARGIN_CALL_DUE_PERIOD", 72*time.Hour),
+			StressTestInterval:  getEnvDuration("STRESS_TEST_INTERVAL", time.Hour),
+			PnLSnapshotInterval: getEnvDuration("PNL_SNAPSHOT_INTERVAL", time.Hour),
+
+			ConcentrationSymbolThreshold: getEnvFloat("CONCENTRATION_SYMBOL_THRESHOLD", 0.25),
+			ConcentrationSectorThreshold: getEnvFloat("CONCENTRATION_SECTOR_THRESHOLD", 0.40),
+			ConcentrationAddOnRate:       getEnvFloat("CONCENTRATION_ADDON_RATE", 0.10),
+
+			LiquidationPreferences:      getEnvSlice("LIQUIDATION_PREFERENCES", []string{"avoid_losses", "largest_requirement", "most_liquid"}),
+			LiquidationPlanOnEscalation: getEnvBool("LIQUIDATION_PLAN_ON_ESCALATION", false),
+		},
+		Trading: TradingConfig{
+			LotReliefMethod:   getEnv("LOT_RELIEF_METHOD", "fifo"),
+			AllowShortSelling: getEnvBool("ALLOW_SHORT_SELLING", false),
+			DefaultBorrowRate: getEnvFloat("DEFAULT_BORROW_RATE", 0.005),
+			BorrowFeeInterval: getEnvDuration("BORROW_FEE_ACCRUAL_INTERVAL", time.Hour),
+
+			InterestAccrualInterval: getEnvDuration("INTEREST_ACCRUAL_INTERVAL", time.Hour),
+			CorporateActionInterval: getEnvDuration("CORPORATE_ACTION_INTERVAL", time.Hour),
+
+			EnforceInitialMargin: getEnvBool("ENFORCE_INITIAL_MARGIN", false),
+		},
+		Notify: NotificationConfig{
+			SMTPHost:       getEnv("SMTP_HOST", ""),
+			SMTPPort:       getEnv("SMTP_PORT", "25"),
+			SMTPUsername:   getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
+			SMTPFrom:       getEnv("SMTP_FROM", "alerts@minirisk.local"),
+			EmailTo:        getEnvSlice("NOTIFY_EMAIL_TO", nil),
+			WebhookURL:     getEnv("NOTIFY_WEBHOOK_URL", ""),
+			WebhookSecret:  getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+			WebhookRetries: getEnvInt("NOTIFY_WEBHOOK_RETRIES", 3),
+			FilePath:       getEnv("NOTIFY_FILE_PATH", ""),
+
+			WebhookAllowedHosts:   getEnvSlice("NOTIFY_WEBHOOK_ALLOWED_HOSTS", nil),
+			WebhookAllowedSchemes: getEnvSlice("NOTIFY_WEBHOOK_ALLOWED_SCHEMES", []string{"https"}),
+			FileDir:               getEnv("NOTIFY_FILE_DIR", ""),
+
+			Workers: getEnvInt("NOTIFY_WORKERS", 2),
 		},
 		Security: SecurityConfig{
 			JWTSecret:     getEnv("JWT_SECRET", ""),
@@ -90,11 +259,88 @@ func validateConfig(config *Config) error {
 	if config.Database.Password == "" {
 		return fmt.Errorf("database password is required")
 	}
-	if config.Market.APIKey == "" {
-		return fmt.Errorf("market data API key is required")
+	switch config.Market.Provider {
+	case "rest":
+		if config.Market.APIKey == "" {
+			return fmt.Errorf("market data API key is required")
+		}
+		if config.Market.APIURL == "" {
+			return fmt.Errorf("market data API URL is required")
+		}
+	case "file":
+		if config.Market.QuoteFile == "" {
+			return fmt.Errorf("market data quote file is required")
+		}
+	case "fake":
+	default:
+		return fmt.Errorf("unknown market data provider: %s", config.Market.Provider)
+	}
+	switch config.Market.FXProvider {
+	case "rest":
+		if config.Market.FXAPIKey == "" {
+			return fmt.Errorf("FX API key is required")
+		}
+		if config.Market.FXAPIURL == "" {
+			return fmt.Errorf("FX API URL is required")
+		}
+	case "file":
+		if config.Market.FXQuoteFile == "" {
+			return fmt.Errorf("FX quote file is required")
+		}
+	case "", "fake":
+	default:
+		return fmt.Errorf("unknown FX provider: %s", config.Market.FXProvider)
+	}
+	if config.Market.FXMaxMove <= 0 {
+		return fmt.Errorf("FX max move must be positive")
+	}
+	switch config.Pricing.StalePricePolicy {
+	case "last_price", "haircut", "fail":
+	default:
+		return fmt.Errorf("unknown stale price policy: %s", config.Pricing.StalePricePolicy)
+	}
+	if config.Pricing.StalePriceHaircut < 0 || config.Pricing.StalePriceHaircut >= 1 {
+		return fmt.Errorf("stale price haircut must be in [0, 1)")
 	}
-	if config.Market.APIURL == "" {
-		return fmt.Errorf("market data API URL is required")
+	if config.Pricing.FXHaircut < 0 || config.Pricing.FXHaircut >= 1 {
+		return fmt.Errorf("FX haircut must be in [0, 1)")
+	}
+	if config.Risk.VaRLookbackDays < 2 {
+		return fmt.Errorf("VaR lookback must be at least 2 days")
+	}
+	for _, level := range config.Risk.VaRConfidenceLevels {
+		if level <= 0 || level >= 1 {
+			return fmt.Errorf("VaR confidence levels must be in (0, 1)")
+		}
+	}
+	for _, threshold := range []float64{config.Risk.ConcentrationSymbolThreshold, config.Risk.ConcentrationSectorThreshold} {
+		if threshold < 0 || threshold >= 1 {
+			return fmt.Errorf("concentration thresholds must be in [0, 1)")
+		}
+	}
+	if config.Risk.ConcentrationAddOnRate < 0 || config.Risk.ConcentrationAddOnRate > 1 {
+		return fmt.Errorf("concentration add-on rate must be in [0, 1]")
+	}
+	for _, pref := range config.Risk.LiquidationPreferences {
+		switch pref {
+		case "most_liquid", "largest_requirement", "avoid_losses":
+		default:
+			return fmt.Errorf("unknown liquidation preference: %s", pref)
+		}
+	}
+	switch config.Trading.LotReliefMethod {
+	case "fifo", "lifo", "average_cost":
+	default:
+		return fmt.Errorf("unknown default lot relief method: %s", config.Trading.LotReliefMethod)
+	}
+	if config.Trading.DefaultBorrowRate < 0 {
+		return fmt.Errorf("default borrow rate cannot be negative")
+	}
+	if len(config.Notify.EmailTo) > 0 && config.Notify.SMTPHost == "" {
+		return fmt.Errorf("SMTP host is required for email notifications")
+	}
+	if config.Notify.Workers < 1 {
+		return fmt.Errorf("notification workers must be at least 1")
 	}
 	if config.Security.JWTSecret == "" {
 		return fmt.Errorf("JWT secret is required")
@@ -153,6 +399,50 @@ func getEnvInt(key string, defaultValue int) int {
 	return result
 }
 
+// getEnvBool gets an environment variable as a boolean with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+// getEnvFloat gets an environment variable as a float with a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+// getEnvFloatSlice gets an environment variable as a comma-separated list of floats
+// with a default value
+func getEnvFloatSlice(key string, defaultValue []float64) []float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var result []float64
+	for _, item := range splitAndTrim(value, ",") {
+		f, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return defaultValue
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
 // splitAndTrim splits a string by a separator and trims spaces from each part
 func splitAndTrim(s, sep string) []string {
 	var result []string
@@ -164,10 +454,10 @@ func splitAndTrim(s, sep string) []string {
 
 // split splits a string by a separator
 func split(s, sep string) []string {
-	return []string{s} // Simplified for this example
+	return strings.Split(s, sep)
 }
 
 // trim trims spaces from a string
 func trim(s string) string {
-	return s // Simplified for this example
+	return strings.TrimSpace(s)
 }
@@ -8,6 +8,8 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/minirisk/api"
 	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+	"github.com/minirisk/services"
 )
 
 func main() {
@@ -16,6 +18,12 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	// Load application configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// Initialize database connection
 	db, err := config.InitDB()
 	if err != nil {
@@ -23,12 +31,61 @@ func main() {
 	}
 	defer db.Close()
 
+	// Start background market data updates
+	marketDataUpdater, err := services.NewMarketDataUpdater(db, cfg.Market)
+	if err != nil {
+		log.Fatalf("Failed to create market data updater: %v", err)
+	}
+	marketDataUpdater.Start()
+
+	// Start margin monitoring, scheduled stress tests, P&L snapshots and
+	// borrow fee and interest accruals
+	pricing, err := models.NewPricingPolicy(cfg.Pricing)
+	if err != nil {
+		log.Fatalf("Invalid pricing policy: %v", err)
+	}
+	concentration := models.NewConcentrationPolicy(cfg.Risk)
+	liquidationPreferences, err := models.ParseLiquidationPreferences(cfg.Risk.LiquidationPreferences)
+	if err != nil {
+		log.Fatalf("Invalid liquidation preferences: %v", err)
+	}
+
+	marginAlertService := services.NewMarginAlertService(db)
+	marginAlertService.Pricing = pricing
+	marginAlertService.Concentration = concentration
+	marginAlertService.DuePeriod = cfg.Risk.MarginCallDuePeriod
+	marginAlertService.Notifications = services.NewNotificationDispatcher(db, cfg.Notify)
+	marginAlertService.Notifications.StartDelivery(cfg.Notify.Workers)
+	marginAlertService.PlanOnEscalation = cfg.Risk.LiquidationPlanOnEscalation
+	marginAlertService.LiquidationPreferences = liquidationPreferences
+	marginAlertService.StartMarginMonitoring(cfg.Risk.MarginCheckInterval)
+
+	stressTestService := services.NewStressTestService(db)
+	stressTestService.Pricing = pricing
+	stressTestService.Concentration = concentration
+	stressTestService.StartStressTesting(cfg.Risk.StressTestInterval)
+
+	pnlSnapshotService := services.NewPnLSnapshotService(db)
+	pnlSnapshotService.Pricing = pricing
+	pnlSnapshotService.StartSnapshots(cfg.Risk.PnLSnapshotInterval)
+
+	borrowFeeService := services.NewBorrowFeeService(db)
+	borrowFeeService.DefaultRate = cfg.Trading.DefaultBorrowRate
+	borrowFeeService.StartAccruals(cfg.Trading.BorrowFeeInterval)
+
+	interestAccrualService := services.NewInterestAccrualService(db)
+	interestAccrualService.StartAccruals(cfg.Trading.InterestAccrualInterval)
+
+	corporateActionProcessor := services.NewCorporateActionProcessor(db)
+	corporateActionProcessor.StartProcessing(cfg.Trading.CorporateActionInterval)
+
 	// Initialize Gin router
 	router := gin.Default()
 
-	// Middleware to inject DB connection into context
+	// Middleware to inject DB connection and configuration into context
 	router.Use(func(c *gin.Context) {
 		c.Set("db", db) // Add db connection to context
+		c.Set("config", cfg)
 		c.Next()
 	})
 
@@ -45,7 +102,7 @@ func main() {
 	})
 
 	// Initialize API routes
-	api.SetupRoutes(router)
+	api.SetupRoutes(router, cfg)
 
 	// Start server
 	port := os.Getenv("PORT")
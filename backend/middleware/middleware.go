@@ -1,10 +1,15 @@
 package middleware
 
 import (
+	"database/sql"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
 	"github.com/minirisk/utils"
 )
 
@@ -80,23 +85,130 @@ func ErrorHandlerMiddleware(logger *utils.Logger) gin.HandlerFunc {
 	}
 }
 
-// AuthMiddleware creates a middleware that handles authentication
+// AuthMiddleware creates a middleware that validates the bearer JWT and stores
+// its claims in the context. The user is reloaded on every request, so tokens
+// of users who have since been deactivated or deleted are rejected, and the
+// claims carry the user's current role and client rather than those the token
+// was issued with.
 func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get token from header
-		token := c.GetHeader("Authorization")
-		if token == "" {
+		header := c.GetHeader("Authorization")
+		if header == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
 			c.Abort()
 			return
 		}
 
-		// TODO: Implement JWT validation
-		// For now, we'll just pass through
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must use the Bearer scheme"})
+			c.Abort()
+			return
+		}
+
+		claims, err := utils.ParseJWT(jwtSecret, token)
+		if err == utils.ErrExpiredToken {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token has expired"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
+			c.Abort()
+			return
+		}
+
+		db := c.MustGet("db").(*sql.DB)
+		userService := &models.UserService{DB: db}
+		user, err := userService.GetUserByID(claims.UserID)
+		if err != nil {
+			log.Printf("Error retrieving user %d: %v", claims.UserID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			c.Abort()
+			return
+		}
+		if user == nil || !user.Active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled"})
+			c.Abort()
+			return
+		}
+		claims.Role = string(user.Role)
+		claims.ClientID = user.ClientID
+
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// RBACMiddleware creates a middleware that checks the authenticated user's role
+// against the permission required by the matched route. permissions maps
+// "METHOD /route/:param" to the required permission; routes missing from the
+// table are denied.
+func RBACMiddleware(permissions map[string]models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+			c.Abort()
+			return
+		}
+
+		required, ok := permissions[c.Request.Method+" "+c.FullPath()]
+		if !ok || !models.Role(claims.Role).Can(required) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ClientScopeMiddleware creates a middleware that stops client users from
+// accessing another client's data through a clientId path or query parameter
+func ClientScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, value := range []string{c.Param("clientId"), c.Query("clientId")} {
+			if value == "" {
+				continue
+			}
+			clientID, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				// Let the handler report the malformed ID
+				continue
+			}
+			if !CanAccessClient(c, clientID) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access to this client is not allowed"})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
 
+// GetClaims returns the JWT claims of the authenticated user, or nil
+func GetClaims(c *gin.Context) *utils.Claims {
+	value, ok := c.Get("claims")
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*utils.Claims)
+	return claims
+}
+
+// CanAccessClient reports whether the authenticated user may access a client's
+// data. Staff users may access every client; client users only their own.
+func CanAccessClient(c *gin.Context, clientID int64) bool {
+	claims := GetClaims(c)
+	if claims == nil {
+		return false
+	}
+	return claims.ClientID == nil || *claims.ClientID == clientID
+}
+
 // RateLimitMiddleware creates a middleware that limits request rate
 func RateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
 	// Simple in-memory rate limiter
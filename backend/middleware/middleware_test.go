@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minirisk/models"
+	"github.com/minirisk/utils"
+)
+
+const testSecret = "test-secret"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testToken returns a token for user 7 expiring after ttl, signed with secret
+func testToken(t *testing.T, secret string, ttl time.Duration) string {
+	t.Helper()
+	now := time.Now()
+	token, err := utils.GenerateJWT(secret, utils.Claims{Subject: "alice", UserID: 7, Role: "client", IssuedAt: now.Unix(), ExpiresAt: now.Add(ttl).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthMiddlewareRejectsBadTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{name: "missing", header: ""},
+		{name: "not bearer", header: "Basic " + testToken(t, testSecret, time.Hour)},
+		{name: "bad signature", header: "Bearer " + testToken(t, "other-secret", time.Hour)},
+		{name: "expired", header: "Bearer " + testToken(t, testSecret, -time.Minute)},
+		{name: "malformed", header: "Bearer not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The tokens are rejected before the user is looked up, so no
+			// database is needed
+			router := gin.New()
+			router.GET("/positions", AuthMiddleware(testSecret), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/positions", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestScopeMiddleware(t *testing.T) {
+	own := int64(42)
+	clientUser := &utils.Claims{UserID: 7, Role: string(models.RoleClient), ClientID: &own}
+	riskOfficer := &utils.Claims{UserID: 8, Role: string(models.RoleRiskOfficer)}
+	permissions := map[string]models.Permission{
+		"GET /positions/:clientId":  models.PermPositionsRead,
+		"POST /positions/:clientId": models.PermPositionsWrite,
+		"GET /positions":            models.PermPositionsRead,
+	}
+
+	tests := []struct {
+		name   string
+		claims *utils.Claims
+		method string
+		path   string
+		want   int
+	}{
+		{name: "client reads own positions", claims: clientUser, method: http.MethodGet, path: "/positions/42", want: http.StatusOK},
+		{name: "client reads another client's positions", claims: clientUser, method: http.MethodGet, path: "/positions/43", want: http.StatusForbidden},
+		{name: "client queries another client", claims: clientUser, method: http.MethodGet, path: "/positions?clientId=43", want: http.StatusForbidden},
+		{name: "client without the permission", claims: clientUser, method: http.MethodPost, path: "/positions/42", want: http.StatusForbidden},
+		{name: "staff reads any client", claims: riskOfficer, method: http.MethodGet, path: "/positions/43", want: http.StatusOK},
+		{name: "no claims", claims: nil, method: http.MethodGet, path: "/positions/42", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set("claims", tt.claims)
+				}
+			}, RBACMiddleware(permissions), ClientScopeMiddleware())
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			router.GET("/positions/:clientId", ok)
+			router.POST("/positions/:clientId", ok)
+			router.GET("/positions", ok)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BorrowFeeDayCount is the day count convention for borrow fee accruals
+const BorrowFeeDayCount = 360
+
+// BorrowRate is the annual fee for borrowing a symbol to sell short
+type BorrowRate struct {
+	Symbol     string    `json:"symbol"`
+	AnnualRate float64   `json:"annual_rate"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BorrowFeeAccrual is one day's borrow fee on a short position. Price and Fee
+// are in the currency the symbol is quoted in.
+type BorrowFeeAccrual struct {
+	ID          int64     `json:"id"`
+	ClientID    int64     `json:"client_id"`
+	Symbol      string    `json:"symbol"`
+	AccrualDate time.Time `json:"accrual_date"`
+	Quantity    int       `json:"quantity"`
+	Price       Decimal   `json:"price"`
+	AnnualRate  float64   `json:"annual_rate"`
+	Fee         Decimal   `json:"fee"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DailyBorrowFee returns one day's fee for borrowing shares at price
+func DailyBorrowFee(shares int, price Decimal, annualRate float64) Decimal {
+	return price.MulInt(shares).Mul(annualRate / BorrowFeeDayCount)
+}
+
+// BorrowService handles borrow rates and the accrual of borrow fees on short
+// positions
+type BorrowService struct {
+	DB *sql.DB
+	// DefaultRate applies to symbols without a borrow rate
+	DefaultRate float64
+}
+
+// GetRates retrieves all borrow rates
+func (bs *BorrowService) GetRates() ([]BorrowRate, error) {
+	rows, err := bs.DB.Query("SELECT symbol, annual_rate, updated_at FROM borrow_rates ORDER BY symbol")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []BorrowRate{}
+	for rows.Next() {
+		var r BorrowRate
+		if err := rows.Scan(&r.Symbol, &r.AnnualRate, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+
+	return rates, rows.Err()
+}
+
+// SetRate creates or updates the borrow rate of a symbol
+func (bs *BorrowService) SetRate(symbol string, annualRate float64) error {
+	if annualRate < 0 {
+		return fmt.Errorf("borrow rate cannot be negative")
+	}
+
+	_, err := bs.DB.Exec(`
+		INSERT INTO borrow_rates (symbol, annual_rate, updated_at)
+		VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE annual_rate = VALUES(annual_rate), updated_at = NOW()
+	`, symbol, annualRate)
+	return err
+}
+
+// GetShortClients returns the clients with a margin account holding a short
+// position
+func (bs *BorrowService) GetShortClients() ([]int64, error) {
+	rows, err := bs.DB.Query(`
+		SELECT DISTINCT p.client_id
+		FROM positions p
+		JOIN margins m ON m.client_id = p.client_id
+		WHERE p.quantity < 0
+		ORDER BY p.client_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []int64
+	for rows.Next() {
+		var clientID int64
+		if err := rows.Scan(&clientID); err != nil {
+			return nil, err
+		}
+		clients = append(clients, clientID)
+	}
+
+	return clients, rows.Err()
+}
+
+// shortAccrual is a client's short position in a symbol and the days it has
+// yet to be charged borrow fees for
+type shortAccrual struct {
+	symbol       string
+	quantity     int
+	annualRate   float64
+	lastAccrual  sql.NullTime
+	oldestLot    sql.NullTime
+	baseCurrency string
+}
+
+// AccrueClientFees charges the borrow fee on each of a client's short positions
+// for every day from the one after it was last charged, or from the day its
+// oldest open short lot was opened, through date, posting each day's fee to
+// the client's cash ledger in their base currency. A day's short quantity is
+// that of the open short lots opened by then. Days already charged are
+// skipped, so it is safe to run repeatedly; positions without a price or FX
+// rate are left for a later run, which catches up on the days missed. It
+// returns the number of accruals made.
+func (bs *BorrowService) AccrueClientFees(clientID int64, date time.Time) (int, error) {
+	rows, err := bs.DB.Query(`
+		SELECT p.symbol, -p.quantity, r.annual_rate, m.base_currency,
+			(SELECT MAX(a.accrual_date) FROM borrow_fee_accruals a
+				WHERE a.client_id = p.client_id AND a.symbol = p.symbol),
+			(SELECT MIN(l.open_date) FROM tax_lots l
+				WHERE l.client_id = p.client_id AND l.symbol = p.symbol AND l.side = ? AND l.remaining_quantity > 0)
+		FROM positions p
+		JOIN margins m ON m.client_id = p.client_id
+		LEFT JOIN borrow_rates r ON r.symbol = p.symbol
+		WHERE p.client_id = ? AND p.quantity < 0
+	`, LotShort, clientID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get short positions: %v", err)
+	}
+
+	var shorts []shortAccrual
+	var symbols []string
+	for rows.Next() {
+		sa := shortAccrual{annualRate: bs.DefaultRate}
+		var rate sql.NullFloat64
+		if err := rows.Scan(&sa.symbol, &sa.quantity, &rate, &sa.baseCurrency, &sa.lastAccrual, &sa.oldestLot); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if rate.Valid {
+			sa.annualRate = rate.Float64
+		}
+		shorts = append(shorts, sa)
+		symbols = appendUnique(symbols, sa.symbol)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Fees accrue on the last known price however old it is
+	marketDataService := &MarketDataService{DB: bs.DB}
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, DefaultMaxPriceAge)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get market prices: %v", err)
+	}
+	fxService := &FXService{DB: bs.DB}
+	rates, err := fxService.GetRates()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get FX rates: %v", err)
+	}
+	taxLotService := &TaxLotService{DB: bs.DB}
+
+	var count int
+	for _, sa := range shorts {
+		quote, ok := quotes[sa.symbol]
+		if !ok {
+			continue
+		}
+		fxRate, _, ok := rates.Convert(quote.Currency, sa.baseCurrency)
+		if !ok {
+			continue
+		}
+		lots, err := taxLotService.GetLots(clientID, sa.symbol, true)
+		if err != nil {
+			return count, fmt.Errorf("failed to get tax lots of %s: %v", sa.symbol, err)
+		}
+
+		for day := sa.firstDay(date); !day.After(date); day = day.AddDate(0, 0, 1) {
+			a := &BorrowFeeAccrual{
+				ClientID:    clientID,
+				Symbol:      sa.symbol,
+				AccrualDate: day,
+				Quantity:    shortQuantityOn(lots, day, sa.quantity),
+				Price:       quote.Price,
+				AnnualRate:  sa.annualRate,
+			}
+			if a.Quantity == 0 {
+				continue
+			}
+			a.Fee = DailyBorrowFee(a.Quantity, a.Price, a.AnnualRate)
+
+			charged, err := bs.chargeAccrual(a, fxRate)
+			if err != nil {
+				return count, fmt.Errorf("failed to accrue borrow fee on %s for %s: %v", a.Symbol, day.Format("2006-01-02"), err)
+			}
+			if charged {
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// firstDay returns the first day a short position has yet to be charged for:
+// the day after its last accrual, unless its oldest open lot was opened after
+// that. A position with neither is charged from date.
+func (sa shortAccrual) firstDay(date time.Time) time.Time {
+	first := date
+	if sa.oldestLot.Valid {
+		first = sa.oldestLot.Time
+	}
+	if sa.lastAccrual.Valid && (!sa.oldestLot.Valid || !sa.lastAccrual.Time.Before(sa.oldestLot.Time)) {
+		first = sa.lastAccrual.Time.AddDate(0, 0, 1)
+	}
+	return time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// shortQuantityOn returns the shares short on day: the remaining quantity of
+// the open short lots opened by then, or held if there are no lots
+func shortQuantityOn(lots []TaxLot, day time.Time, held int) int {
+	var quantity int
+	var short bool
+	for _, lot := range lots {
+		if lot.Side != LotShort {
+			continue
+		}
+		short = true
+		if !lot.OpenDate.After(day) {
+			quantity += lot.RemainingQuantity
+		}
+	}
+	if !short || quantity > held {
+		return held
+	}
+	return quantity
+}
+
+// chargeAccrual records an accrual and posts its fee, converted at fxRate, to
+// the client's cash ledger, unless the position has already been charged for
+// the day
+func (bs *BorrowService) chargeAccrual(a *BorrowFeeAccrual, fxRate float64) (bool, error) {
+	tx, err := bs.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT IGNORE INTO borrow_fee_accruals (client_id, symbol, accrual_date, quantity, price, annual_rate, fee, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NOW())
+	`, a.ClientID, a.Symbol, a.AccrualDate, a.Quantity, a.Price, a.AnnualRate, a.Fee)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if inserted == 0 {
+		return false, nil
+	}
+
+	fee := &CashTransaction{
+		ClientID:      a.ClientID,
+		Type:          CashBorrowFee,
+		Amount:        -a.Fee.Mul(fxRate),
+		Description:   fmt.Sprintf("Borrow fee on %d %s at %.4f%%", a.Quantity, a.Symbol, a.AnnualRate*100),
+		EffectiveDate: a.AccrualDate,
+	}
+	if err := postCash(tx, fee); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetAccruals retrieves a client's borrow fee accruals in [from, to), oldest first
+func (bs *BorrowService) GetAccruals(clientID int64, from, to time.Time) ([]BorrowFeeAccrual, error) {
+	query := `
+		SELECT id, client_id, symbol, accrual_date, quantity, price, annual_rate, fee, created_at
+		FROM borrow_fee_accruals
+		WHERE client_id = ? AND accrual_date >= ? AND accrual_date < ?
+		ORDER BY accrual_date, symbol
+	`
+
+	rows, err := bs.DB.Query(query, clientID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accruals := []BorrowFeeAccrual{}
+	for rows.Next() {
+		var a BorrowFeeAccrual
+		err := rows.Scan(
+			&a.ID,
+			&a.ClientID,
+			&a.Symbol,
+			&a.AccrualDate,
+			&a.Quantity,
+			&a.Price,
+			&a.AnnualRate,
+			&a.Fee,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		accruals = append(accruals, a)
+	}
+
+	return accruals, rows.Err()
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestShortAccrualFirstDay(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 3, n, 0, 0, 0, 0, time.UTC) }
+	valid := func(d time.Time) sql.NullTime { return sql.NullTime{Time: d, Valid: true} }
+	today := day(20)
+
+	tests := []struct {
+		name        string
+		lastAccrual sql.NullTime
+		oldestLot   sql.NullTime
+		want        time.Time
+	}{
+		{name: "never charged starts at the oldest lot", oldestLot: valid(day(5)), want: day(5)},
+		{name: "resumes after the last accrual", lastAccrual: valid(day(12)), oldestLot: valid(day(5)), want: day(13)},
+		{name: "a short reopened after the last accrual starts at its lot", lastAccrual: valid(day(3)), oldestLot: valid(day(10)), want: day(10)},
+		{name: "no lots resumes after the last accrual", lastAccrual: valid(day(17)), want: day(18)},
+		{name: "no history charges today", want: today},
+		{name: "charged today has nothing left", lastAccrual: valid(today), oldestLot: valid(day(5)), want: day(21)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sa := shortAccrual{lastAccrual: tt.lastAccrual, oldestLot: tt.oldestLot}
+			if got := sa.firstDay(today); !got.Equal(tt.want) {
+				t.Errorf("firstDay = %s, want %s", got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestShortQuantityOn(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 3, n, 0, 0, 0, 0, time.UTC) }
+	lots := []TaxLot{
+		{Side: LotShort, OpenDate: day(5), RemainingQuantity: 100},
+		{Side: LotShort, OpenDate: day(10), RemainingQuantity: 50},
+		{Side: LotLong, OpenDate: day(1), RemainingQuantity: 999},
+	}
+
+	tests := []struct {
+		day  time.Time
+		held int
+		want int
+	}{
+		{day: day(4), held: 150, want: 0},
+		{day: day(5), held: 150, want: 100},
+		{day: day(10), held: 150, want: 150},
+		{day: day(12), held: 120, want: 120},
+	}
+	for _, tt := range tests {
+		if got := shortQuantityOn(lots, tt.day, tt.held); got != tt.want {
+			t.Errorf("shortQuantityOn(%s, %d) = %d, want %d", tt.day.Format("2006-01-02"), tt.held, got, tt.want)
+		}
+	}
+
+	if got := shortQuantityOn(nil, day(1), 75); got != 75 {
+		t.Errorf("without lots shortQuantityOn = %d, want the quantity held", got)
+	}
+}
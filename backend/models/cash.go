@@ -0,0 +1,237 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoMarginAccount is returned when cash is posted for a client without a
+// margin account
+var ErrNoMarginAccount = errors.New("client has no margin account")
+
+// ErrWithdrawalMarginCall is returned when a withdrawal would put the account
+// in margin call
+var ErrWithdrawalMarginCall = errors.New("withdrawal would put the account in margin call")
+
+// CashTransactionType is the kind of movement recorded in the cash ledger
+type CashTransactionType string
+
+// Cash transaction types
+const (
+	CashDeposit    CashTransactionType = "deposit"
+	CashWithdrawal CashTransactionType = "withdrawal"
+	CashTrade      CashTransactionType = "trade"
+	CashInterest   CashTransactionType = "interest"
+	CashFee        CashTransactionType = "fee"
+	CashBorrowFee  CashTransactionType = "borrow_fee"
+	CashAdjustment CashTransactionType = "adjustment"
+	CashDividend   CashTransactionType = "dividend"
+	// CashCorporateAction is cash in lieu of fractional shares from a split
+	CashCorporateAction CashTransactionType = "corporate_action"
+)
+
+// CashTransaction is an entry in a client's cash ledger. Amount is positive
+// for cash paid into the account and negative for cash paid out. The loan
+// amount is the negative of the ledger's running balance, and LoanBalance is
+// the loan amount after the entry; a negative loan amount is a credit balance.
+// Amounts are posted in the account's base currency, rounded to its minor unit.
+type CashTransaction struct {
+	ID            int64               `json:"id"`
+	ClientID      int64               `json:"client_id"`
+	Type          CashTransactionType `json:"type"`
+	Amount        Decimal             `json:"amount"`
+	LoanBalance   Decimal             `json:"loan_balance"`
+	TradeID       *int64              `json:"trade_id,omitempty"`
+	Description   string              `json:"description"`
+	EffectiveDate time.Time           `json:"effective_date"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
+
+// postCash appends an entry to a client's cash ledger and applies it to their
+// loan amount. Deposits and withdrawals also move the SMA, since the cash is
+// available to trade or has been taken out.
+func postCash(tx *sql.Tx, ct *CashTransaction) error {
+	var loan Decimal
+	var currency string
+	err := tx.QueryRow("SELECT loan_amount, base_currency FROM margins WHERE client_id = ? FOR UPDATE", ct.ClientID).Scan(&loan, &currency)
+	if err == sql.ErrNoRows {
+		return ErrNoMarginAccount
+	}
+	if err != nil {
+		return err
+	}
+
+	ct.Amount = ct.Amount.Round(currency)
+	ct.LoanBalance = loan - ct.Amount
+	if ct.EffectiveDate.IsZero() {
+		ct.EffectiveDate = time.Now().Truncate(24 * time.Hour)
+	}
+	var smaChange Decimal
+	if ct.Type == CashDeposit || ct.Type == CashWithdrawal {
+		smaChange = ct.Amount
+	}
+
+	_, err = tx.Exec(`
+		UPDATE margins
+		SET loan_amount = ?, sma = sma + ?, updated_at = NOW()
+		WHERE client_id = ?
+	`, ct.LoanBalance, smaChange, ct.ClientID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO cash_transactions (client_id, transaction_type, amount, loan_balance, trade_id, description, effective_date, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NOW())
+	`, ct.ClientID, ct.Type, ct.Amount, ct.LoanBalance, ct.TradeID, ct.Description, ct.EffectiveDate)
+	if err != nil {
+		return err
+	}
+
+	ct.ID, err = result.LastInsertId()
+	return err
+}
+
+// CashService handles a client's cash ledger
+type CashService struct {
+	DB            *sql.DB
+	Pricing       PricingPolicy
+	Concentration ConcentrationPolicy
+}
+
+// Post appends an entry to a client's cash ledger in its own transaction
+func (cs *CashService) Post(ct *CashTransaction) error {
+	tx, err := cs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := postCash(tx, ct); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Deposit pays cash into a client's account
+func (cs *CashService) Deposit(clientID int64, amount Decimal, description string) (*CashTransaction, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("deposit amount must be positive")
+	}
+
+	ct := &CashTransaction{ClientID: clientID, Type: CashDeposit, Amount: amount, Description: description}
+	if err := cs.Post(ct); err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+// Withdraw pays cash out of a client's account. The withdrawal is rejected
+// with ErrWithdrawalMarginCall if the margin status after it would be in margin
+// call; that status is returned either way. The margin account is locked while
+// the status is checked and the withdrawal posted, so that concurrent cash
+// movements are checked one after the other.
+func (cs *CashService) Withdraw(clientID int64, amount Decimal, description string) (*CashTransaction, *MarginStatus, error) {
+	if amount <= 0 {
+		return nil, nil, fmt.Errorf("withdrawal amount must be positive")
+	}
+
+	tx, err := cs.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	margin, err := lockMargin(tx, clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if margin == nil {
+		return nil, nil, ErrNoMarginAccount
+	}
+
+	positionService := &PositionService{DB: cs.DB}
+	positions, err := positionService.GetPositionsByClientID(clientID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+
+	var symbols []string
+	for _, position := range positions {
+		symbols = append(symbols, position.Symbol)
+	}
+
+	marketDataService := &MarketDataService{DB: cs.DB}
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, cs.Pricing.MaxPriceAge())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get market prices: %v", err)
+	}
+
+	marginService := &MarginService{DB: cs.DB, Pricing: cs.Pricing, Concentration: cs.Concentration}
+	amount = amount.Round(margin.BaseCurrency)
+	projected := *margin
+	projected.LoanAmount += amount
+	projected.SMA -= amount
+	status, err := marginService.calculateStatus(&projected, positions, quotes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status.MarginCall {
+		return nil, status, ErrWithdrawalMarginCall
+	}
+
+	ct := &CashTransaction{ClientID: clientID, Type: CashWithdrawal, Amount: -amount, Description: description}
+	if err := postCash(tx, ct); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return ct, status, nil
+}
+
+// GetTransactions retrieves the cash ledger entries posted for a client in
+// [from, to), oldest first. Entries are ordered as posted, so the loan balances
+// are the history of the loan amount.
+func (cs *CashService) GetTransactions(clientID int64, from, to time.Time) ([]CashTransaction, error) {
+	query := `
+		SELECT id, client_id, transaction_type, amount, loan_balance, trade_id, description, effective_date, created_at
+		FROM cash_transactions
+		WHERE client_id = ? AND created_at >= ? AND created_at < ?
+		ORDER BY id
+	`
+
+	rows, err := cs.DB.Query(query, clientID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []CashTransaction{}
+	for rows.Next() {
+		var ct CashTransaction
+		var tradeID sql.NullInt64
+		err := rows.Scan(
+			&ct.ID,
+			&ct.ClientID,
+			&ct.Type,
+			&ct.Amount,
+			&ct.LoanBalance,
+			&tradeID,
+			&ct.Description,
+			&ct.EffectiveDate,
+			&ct.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if tradeID.Valid {
+			ct.TradeID = &tradeID.Int64
+		}
+		transactions = append(transactions, ct)
+	}
+
+	return transactions, rows.Err()
+}
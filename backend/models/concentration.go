@@ -0,0 +1,258 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/minirisk/config"
+)
+
+// ConcentrationType identifies what an exposure is grouped by
+type ConcentrationType string
+
+// Concentration types
+const (
+	ConcentrationSymbol ConcentrationType = "symbol"
+	ConcentrationSector ConcentrationType = "sector"
+)
+
+// ConcentrationPolicy configures the concentration add-on. A symbol or sector
+// making up more than its threshold of a portfolio's gross market value is
+// charged AddOnRate on the market value above the threshold. A zero threshold
+// disables the add-on for that type.
+type ConcentrationPolicy struct {
+	SymbolThreshold float64
+	SectorThreshold float64
+	AddOnRate       float64
+}
+
+// NewConcentrationPolicy builds a ConcentrationPolicy from the risk configuration
+func NewConcentrationPolicy(cfg config.RiskConfig) ConcentrationPolicy {
+	return ConcentrationPolicy{
+		SymbolThreshold: cfg.ConcentrationSymbolThreshold,
+		SectorThreshold: cfg.ConcentrationSectorThreshold,
+		AddOnRate:       cfg.ConcentrationAddOnRate,
+	}
+}
+
+// threshold returns the threshold for a concentration type
+func (cp ConcentrationPolicy) threshold(t ConcentrationType) float64 {
+	if t == ConcentrationSector {
+		return cp.SectorThreshold
+	}
+	return cp.SymbolThreshold
+}
+
+// Concentration is a portfolio's exposure to one symbol or sector. Share is
+// the exposure's part of the gross market value of the portfolio.
+// CoveredByRule is set in margin status when a concentration maintenance rule
+// set the symbol's requirement instead of the add-on.
+type Concentration struct {
+	Type          ConcentrationType `json:"type"`
+	Name          string            `json:"name"`
+	MarketValue   float64           `json:"market_value"`
+	Share         float64           `json:"share"`
+	Threshold     float64           `json:"threshold"`
+	AddOn         float64           `json:"add_on"`
+	CoveredByRule bool              `json:"covered_by_rule,omitempty"`
+}
+
+// Exceeds reports whether the exposure is over its threshold
+func (c Concentration) Exceeds() bool {
+	return c.Threshold > 0 && c.Share > c.Threshold
+}
+
+// Concentrations groups the absolute market value of each symbol by symbol and
+// by sector and charges the add-on on those over their threshold. Symbols
+// without a sector are only grouped by symbol. The result is ordered by share,
+// largest first.
+func (cp ConcentrationPolicy) Concentrations(values map[string]float64, sectors map[string]string) []Concentration {
+	var grossValue float64
+	sectorValues := make(map[string]float64)
+	for symbol, value := range values {
+		grossValue += value
+		if sector, ok := sectors[symbol]; ok {
+			sectorValues[sector] += value
+		}
+	}
+
+	concentrations := []Concentration{}
+	if grossValue <= 0 {
+		return concentrations
+	}
+
+	add := func(t ConcentrationType, name string, value float64) {
+		c := Concentration{
+			Type:        t,
+			Name:        name,
+			MarketValue: value,
+			Share:       value / grossValue,
+			Threshold:   cp.threshold(t),
+		}
+		if c.Exceeds() {
+			c.AddOn = (value - c.Threshold*grossValue) * cp.AddOnRate
+		}
+		concentrations = append(concentrations, c)
+	}
+	for symbol, value := range values {
+		add(ConcentrationSymbol, symbol, value)
+	}
+	for sector, value := range sectorValues {
+		add(ConcentrationSector, sector, value)
+	}
+
+	sort.Slice(concentrations, func(i, j int) bool {
+		if concentrations[i].Share != concentrations[j].Share {
+			return concentrations[i].Share > concentrations[j].Share
+		}
+		if concentrations[i].Type != concentrations[j].Type {
+			return concentrations[i].Type < concentrations[j].Type
+		}
+		return concentrations[i].Name < concentrations[j].Name
+	})
+	return concentrations
+}
+
+// ClientConcentration is one client's exposure in the firm-wide report
+type ClientConcentration struct {
+	ClientID int64 `json:"client_id"`
+	Concentration
+}
+
+// FirmExposure is the firm's combined exposure to one symbol or sector across
+// all clients. Share is the exposure's part of the firm's gross market value.
+type FirmExposure struct {
+	Type        ConcentrationType `json:"type"`
+	Name        string            `json:"name"`
+	MarketValue float64           `json:"market_value"`
+	Share       float64           `json:"share"`
+	ClientCount int               `json:"client_count"`
+}
+
+// ConcentrationReport lists the largest client concentrations and firm-wide
+// exposures, valued in DefaultCurrency. Positions without a price or FX rate
+// are left out.
+type ConcentrationReport struct {
+	AsOf            time.Time             `json:"as_of"`
+	ClientCount     int                   `json:"client_count"`
+	ExceedingCount  int                   `json:"exceeding_count"`
+	Concentrations  []ClientConcentration `json:"concentrations"`
+	FirmExposures   []FirmExposure        `json:"firm_exposures"`
+	UnpricedSymbols []string              `json:"unpriced_symbols"`
+}
+
+// ConcentrationService reports concentrations across all clients
+type ConcentrationService struct {
+	DB            *sql.DB
+	Pricing       PricingPolicy
+	Concentration ConcentrationPolicy
+}
+
+// BuildReport works out every client's concentrations and the firm's combined
+// exposures, keeping the limit largest of each. A limit of zero or less keeps
+// them all.
+func (cs *ConcentrationService) BuildReport(limit int) (*ConcentrationReport, error) {
+	marginService := &MarginService{DB: cs.DB}
+	clientIDs, err := marginService.GetClientIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clients: %v", err)
+	}
+
+	report := &ConcentrationReport{
+		AsOf:            time.Now(),
+		ClientCount:     len(clientIDs),
+		Concentrations:  []ClientConcentration{},
+		FirmExposures:   []FirmExposure{},
+		UnpricedSymbols: []string{},
+	}
+
+	positionService := &PositionService{DB: cs.DB}
+	clientPositions := make(map[int64][]Position)
+	var symbols []string
+	for _, clientID := range clientIDs {
+		positions, err := positionService.GetPositionsByClientID(clientID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get positions for client %d: %v", clientID, err)
+		}
+		clientPositions[clientID] = positions
+		for _, position := range positions {
+			symbols = appendUnique(symbols, position.Symbol)
+		}
+	}
+
+	marketDataService := &MarketDataService{DB: cs.DB}
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, cs.Pricing.MaxPriceAge())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market prices: %v", err)
+	}
+
+	instrumentService := &InstrumentService{DB: cs.DB}
+	sectors, err := instrumentService.GetSectors(symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sectors: %v", err)
+	}
+
+	fxService := &FXService{DB: cs.DB}
+	rates, err := fxService.GetRates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FX rates: %v", err)
+	}
+
+	// Value each client's positions and combine them into firm exposures
+	firmSymbols := make(map[string]float64)
+	holders := map[ConcentrationType]map[string]int{
+		ConcentrationSymbol: make(map[string]int),
+		ConcentrationSector: make(map[string]int),
+	}
+	for _, clientID := range clientIDs {
+		values := make(map[string]float64)
+		clientSectors := make(map[string]bool)
+		for _, position := range clientPositions[clientID] {
+			price, _, ok := cs.Pricing.priceIn(DefaultCurrency, position, quotes, rates)
+			if !ok {
+				report.UnpricedSymbols = appendUnique(report.UnpricedSymbols, position.Symbol)
+				continue
+			}
+			value := price.MulInt(position.Quantity).Abs().Float64()
+			values[position.Symbol] += value
+			firmSymbols[position.Symbol] += value
+			holders[ConcentrationSymbol][position.Symbol]++
+			if sector, ok := sectors[position.Symbol]; ok && !clientSectors[sector] {
+				clientSectors[sector] = true
+				holders[ConcentrationSector][sector]++
+			}
+		}
+
+		for _, c := range cs.Concentration.Concentrations(values, sectors) {
+			if c.Exceeds() {
+				report.ExceedingCount++
+			}
+			report.Concentrations = append(report.Concentrations, ClientConcentration{ClientID: clientID, Concentration: c})
+		}
+	}
+
+	// Firm exposures are not charged, so they are grouped under the zero policy
+	exposures := ConcentrationPolicy{}.Concentrations(firmSymbols, sectors)
+	for _, c := range exposures {
+		report.FirmExposures = append(report.FirmExposures, FirmExposure{
+			Type:        c.Type,
+			Name:        c.Name,
+			MarketValue: c.MarketValue,
+			Share:       c.Share,
+			ClientCount: holders[c.Type][c.Name],
+		})
+	}
+
+	sort.SliceStable(report.Concentrations, func(i, j int) bool {
+		return report.Concentrations[i].Share > report.Concentrations[j].Share
+	})
+	if limit > 0 && len(report.Concentrations) > limit {
+		report.Concentrations = report.Concentrations[:limit]
+	}
+	if limit > 0 && len(report.FirmExposures) > limit {
+		report.FirmExposures = report.FirmExposures[:limit]
+	}
+	return report, nil
+}
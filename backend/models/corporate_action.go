@@ -0,0 +1,961 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrActionNotPending is returned when a corporate action that has already been
+// applied or cancelled is applied, previewed or cancelled
+var ErrActionNotPending = errors.New("corporate action is not pending")
+
+// ErrActionNotDue is returned when a corporate action is applied before its ex-date
+var ErrActionNotDue = errors.New("corporate action is not due until its ex-date")
+
+// ErrTradesSinceExDate is returned when a split is applied after trades in its
+// symbol have been booked on or after the ex-date, since they are already in
+// split shares
+var ErrTradesSinceExDate = errors.New("trades have been booked in the symbol since the ex-date")
+
+// CorporateActionType is the kind of corporate action
+type CorporateActionType string
+
+// Corporate action types
+const (
+	// ActionSplit gives RatioNew shares for every RatioOld held
+	ActionSplit CorporateActionType = "split"
+	// ActionReverseSplit gives RatioNew shares for every RatioOld held, fewer
+	// than before
+	ActionReverseSplit CorporateActionType = "reverse_split"
+	// ActionCashDividend pays AmountPerShare on the shares held on the ex-date,
+	// on the pay date
+	ActionCashDividend CorporateActionType = "cash_dividend"
+	// ActionSymbolChange renames Symbol to NewSymbol
+	ActionSymbolChange CorporateActionType = "symbol_change"
+)
+
+// CorporateActionStatus is where a corporate action is in its processing
+type CorporateActionStatus string
+
+// Corporate action statuses
+const (
+	ActionPending   CorporateActionStatus = "pending"
+	ActionApplied   CorporateActionStatus = "applied"
+	ActionPaid      CorporateActionStatus = "paid"
+	ActionCancelled CorporateActionStatus = "cancelled"
+)
+
+// CorporateAction is an event that changes the positions held in a symbol. It is
+// applied on its ex-date; cash dividends are then paid on their pay date.
+// AmountPerShare is in the symbol's currency. LastError and FailedAt record the
+// last attempt to apply or pay the action that failed, until one succeeds.
+type CorporateAction struct {
+	ID             int64                 `json:"id"`
+	Symbol         string                `json:"symbol"`
+	Type           CorporateActionType   `json:"type"`
+	ExDate         time.Time             `json:"ex_date"`
+	PayDate        *time.Time            `json:"pay_date,omitempty"`
+	RatioNew       int                   `json:"ratio_new,omitempty"`
+	RatioOld       int                   `json:"ratio_old,omitempty"`
+	AmountPerShare float64               `json:"amount_per_share,omitempty"`
+	NewSymbol      string                `json:"new_symbol,omitempty"`
+	Status         CorporateActionStatus `json:"status"`
+	Description    string                `json:"description"`
+	LastError      string                `json:"last_error,omitempty"`
+	AppliedAt      *time.Time            `json:"applied_at,omitempty"`
+	PaidAt         *time.Time            `json:"paid_at,omitempty"`
+	FailedAt       *time.Time            `json:"failed_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// Validate checks that the action is well formed for its type
+func (a *CorporateAction) Validate() error {
+	if a.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if a.ExDate.IsZero() {
+		return fmt.Errorf("ex-date is required")
+	}
+
+	switch a.Type {
+	case ActionSplit:
+		if a.RatioOld <= 0 || a.RatioNew <= a.RatioOld {
+			return fmt.Errorf("split requires ratio_new above ratio_old, e.g. 4 for 1")
+		}
+	case ActionReverseSplit:
+		if a.RatioNew <= 0 || a.RatioNew >= a.RatioOld {
+			return fmt.Errorf("reverse split requires ratio_new below ratio_old, e.g. 1 for 10")
+		}
+	case ActionCashDividend:
+		if a.AmountPerShare <= 0 {
+			return fmt.Errorf("dividend amount per share must be positive")
+		}
+		if a.PayDate == nil || a.PayDate.Before(a.ExDate) {
+			return fmt.Errorf("dividend requires a pay date on or after the ex-date")
+		}
+	case ActionSymbolChange:
+		if a.NewSymbol == "" || len(a.NewSymbol) > MaxSymbolLength {
+			return fmt.Errorf("new symbol must be 1 to %d characters", MaxSymbolLength)
+		}
+		if a.NewSymbol == a.Symbol {
+			return fmt.Errorf("new symbol must differ from the symbol")
+		}
+	default:
+		return fmt.Errorf("unknown corporate action type: %s", a.Type)
+	}
+	return nil
+}
+
+// IsSplit reports whether the action changes the number of shares held
+func (a *CorporateAction) IsSplit() bool {
+	return a.Type == ActionSplit || a.Type == ActionReverseSplit
+}
+
+// CorporateActionAdjustment is the change an action made to one client's
+// position. CashAmount is the cash in lieu of fractional shares of a split, or
+// the dividend on the position, in the symbol's currency; it is negative for
+// short positions. CashTransactionID is the cash ledger entry that posted it,
+// and is nil for clients without a margin account, who have no cash ledger to
+// post to. Reliefs are the lots relieved for fractional shares when a split is
+// applied or previewed.
+type CorporateActionAdjustment struct {
+	ID                int64       `json:"id,omitempty"`
+	ActionID          int64       `json:"action_id"`
+	ClientID          int64       `json:"client_id"`
+	Symbol            string      `json:"symbol"`
+	QuantityBefore    int         `json:"quantity_before"`
+	QuantityAfter     int         `json:"quantity_after"`
+	CostBasisBefore   Decimal     `json:"cost_basis_before"`
+	CostBasisAfter    Decimal     `json:"cost_basis_after"`
+	CashAmount        Decimal     `json:"cash_amount"`
+	CashTransactionID *int64      `json:"cash_transaction_id,omitempty"`
+	Reliefs           []LotRelief `json:"reliefs,omitempty"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
+// CorporateActionResult is what applying or paying an action changed, or would
+// change when DryRun is set. PriceRowsUpdated counts the stored prices restated
+// by a split or moved to the new symbol of a symbol change.
+type CorporateActionResult struct {
+	Action           *CorporateAction            `json:"action"`
+	DryRun           bool                        `json:"dry_run"`
+	Adjustments      []CorporateActionAdjustment `json:"adjustments"`
+	PriceRowsUpdated int64                       `json:"price_rows_updated"`
+}
+
+// CorporateActionFilter narrows a corporate action listing; zero values match
+// everything
+type CorporateActionFilter struct {
+	Symbol string
+	Status CorporateActionStatus
+}
+
+// CorporateActionService handles corporate actions and applies them to
+// positions, tax lots, stored prices and the cash ledger
+type CorporateActionService struct {
+	DB *sql.DB
+}
+
+// corporateActionColumns is the column list scanned by scanCorporateAction
+const corporateActionColumns = `
+	id, symbol, action_type, ex_date, pay_date, ratio_new, ratio_old, amount_per_share,
+	new_symbol, status, description, last_error, applied_at, paid_at, failed_at, created_at, updated_at
+`
+
+// scanCorporateAction scans an action selected with corporateActionColumns
+func scanCorporateAction(row rowScanner) (*CorporateAction, error) {
+	var a CorporateAction
+	var payDate, appliedAt, paidAt, failedAt sql.NullTime
+	var ratioNew, ratioOld sql.NullInt64
+	var amountPerShare sql.NullFloat64
+	var newSymbol, lastError sql.NullString
+	err := row.Scan(
+		&a.ID,
+		&a.Symbol,
+		&a.Type,
+		&a.ExDate,
+		&payDate,
+		&ratioNew,
+		&ratioOld,
+		&amountPerShare,
+		&newSymbol,
+		&a.Status,
+		&a.Description,
+		&lastError,
+		&appliedAt,
+		&paidAt,
+		&failedAt,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	a.PayDate = nullTimePtr(payDate)
+	a.RatioNew = int(ratioNew.Int64)
+	a.RatioOld = int(ratioOld.Int64)
+	a.AmountPerShare = amountPerShare.Float64
+	a.NewSymbol = newSymbol.String
+	a.LastError = lastError.String
+	a.AppliedAt = nullTimePtr(appliedAt)
+	a.PaidAt = nullTimePtr(paidAt)
+	a.FailedAt = nullTimePtr(failedAt)
+	return &a, nil
+}
+
+// CreateAction records a pending corporate action
+func (cas *CorporateActionService) CreateAction(a *CorporateAction) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+
+	var ratioNew, ratioOld sql.NullInt64
+	if a.IsSplit() {
+		ratioNew = sql.NullInt64{Int64: int64(a.RatioNew), Valid: true}
+		ratioOld = sql.NullInt64{Int64: int64(a.RatioOld), Valid: true}
+	}
+	var amountPerShare sql.NullFloat64
+	if a.Type == ActionCashDividend {
+		amountPerShare = sql.NullFloat64{Float64: a.AmountPerShare, Valid: true}
+	}
+
+	a.Status = ActionPending
+	result, err := cas.DB.Exec(`
+		INSERT INTO corporate_actions (symbol, action_type, ex_date, pay_date, ratio_new, ratio_old, amount_per_share,
+			new_symbol, status, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+	`, a.Symbol, a.Type, a.ExDate, a.PayDate, ratioNew, ratioOld, amountPerShare,
+		nullString(a.NewSymbol), a.Status, a.Description)
+	if err != nil {
+		return err
+	}
+
+	a.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetAction retrieves a corporate action by ID
+func (cas *CorporateActionService) GetAction(id int64) (*CorporateAction, error) {
+	query := `SELECT ` + corporateActionColumns + ` FROM corporate_actions WHERE id = ?`
+
+	a, err := scanCorporateAction(cas.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// ListActions retrieves the corporate actions matching the filter, latest
+// ex-date first
+func (cas *CorporateActionService) ListActions(filter CorporateActionFilter) ([]CorporateAction, error) {
+	query := `SELECT ` + corporateActionColumns + ` FROM corporate_actions WHERE 1 = 1`
+	var args []interface{}
+	if filter.Symbol != "" {
+		query += " AND (symbol = ? OR new_symbol = ?)"
+		args = append(args, filter.Symbol, filter.Symbol)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	query += " ORDER BY ex_date DESC, id DESC"
+
+	rows, err := cas.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []CorporateAction{}
+	for rows.Next() {
+		a, err := scanCorporateAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, *a)
+	}
+
+	return actions, rows.Err()
+}
+
+// GetAdjustments retrieves the position adjustments made by an action
+func (cas *CorporateActionService) GetAdjustments(actionID int64) ([]CorporateActionAdjustment, error) {
+	rows, err := cas.DB.Query(`
+		SELECT id, action_id, client_id, symbol, quantity_before, quantity_after, cost_basis_before,
+			cost_basis_after, cash_amount, cash_transaction_id, created_at
+		FROM corporate_action_adjustments
+		WHERE action_id = ?
+		ORDER BY client_id
+	`, actionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := []CorporateActionAdjustment{}
+	for rows.Next() {
+		var adj CorporateActionAdjustment
+		var cashTransactionID sql.NullInt64
+		err := rows.Scan(
+			&adj.ID,
+			&adj.ActionID,
+			&adj.ClientID,
+			&adj.Symbol,
+			&adj.QuantityBefore,
+			&adj.QuantityAfter,
+			&adj.CostBasisBefore,
+			&adj.CostBasisAfter,
+			&adj.CashAmount,
+			&cashTransactionID,
+			&adj.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if cashTransactionID.Valid {
+			adj.CashTransactionID = &cashTransactionID.Int64
+		}
+		adjustments = append(adjustments, adj)
+	}
+
+	return adjustments, rows.Err()
+}
+
+// CancelAction cancels a pending corporate action. It returns false if there is
+// no action with the ID.
+func (cas *CorporateActionService) CancelAction(id int64) (bool, error) {
+	a, err := cas.GetAction(id)
+	if err != nil || a == nil {
+		return false, err
+	}
+	if a.Status != ActionPending {
+		return true, ErrActionNotPending
+	}
+
+	_, err = cas.DB.Exec(`
+		UPDATE corporate_actions
+		SET status = ?, updated_at = NOW()
+		WHERE id = ? AND status = ?
+	`, ActionCancelled, id, ActionPending)
+	return true, err
+}
+
+// Preview works out what applying a pending action now would change, without
+// changing anything
+func (cas *CorporateActionService) Preview(id int64) (*CorporateActionResult, error) {
+	return cas.apply(id, time.Time{}, true)
+}
+
+// Apply applies a pending action whose ex-date has been reached
+func (cas *CorporateActionService) Apply(id int64) (*CorporateActionResult, error) {
+	return cas.apply(id, time.Now().UTC().Truncate(24*time.Hour), false)
+}
+
+// apply applies a pending action in a transaction, checking that its ex-date
+// is not after asOf, and rolls the transaction back for a dry run. It returns
+// nil if there is no action with the ID.
+func (cas *CorporateActionService) apply(id int64, asOf time.Time, dryRun bool) (*CorporateActionResult, error) {
+	tx, err := cas.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + corporateActionColumns + ` FROM corporate_actions WHERE id = ? FOR UPDATE`
+	a, err := scanCorporateAction(tx.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != ActionPending {
+		return nil, ErrActionNotPending
+	}
+	if !dryRun && a.ExDate.After(asOf) {
+		return nil, ErrActionNotDue
+	}
+
+	result := &CorporateActionResult{Action: a, DryRun: dryRun, Adjustments: []CorporateActionAdjustment{}}
+	switch a.Type {
+	case ActionSplit, ActionReverseSplit:
+		err = applySplit(tx, a, result)
+	case ActionCashDividend:
+		err = applyDividend(tx, a, result)
+	case ActionSymbolChange:
+		err = applySymbolChange(tx, a, result)
+	default:
+		err = fmt.Errorf("unknown corporate action type: %s", a.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range result.Adjustments {
+		if err := insertAdjustment(tx, &result.Adjustments[i]); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(`
+		UPDATE corporate_actions
+		SET status = ?, applied_at = NOW(), last_error = NULL, failed_at = NULL, updated_at = NOW()
+		WHERE id = ?
+	`, ActionApplied, a.ID); err != nil {
+		return nil, err
+	}
+	a.Status = ActionApplied
+
+	if dryRun {
+		// Nothing was saved, so drop the IDs assigned in the transaction
+		a.Status = ActionPending
+		for i := range result.Adjustments {
+			adj := &result.Adjustments[i]
+			adj.ID = 0
+			adj.CashTransactionID = nil
+			for j := range adj.Reliefs {
+				adj.Reliefs[j].ID = 0
+			}
+		}
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	a.AppliedAt = &now
+	a.LastError, a.FailedAt = "", nil
+	return result, nil
+}
+
+// applySplit restates the positions and open tax lots in a split symbol and its
+// prices before the ex-date. Fractional shares are paid out as cash in lieu at
+// the latest restated price: to holders of long positions, and by holders of
+// short positions. The lots the fractions came from are relieved against the
+// cash, realizing the P&L on their cost. It refuses to apply once trades have
+// been booked in the symbol on or after the ex-date.
+func applySplit(tx *sql.Tx, a *CorporateAction, result *CorporateActionResult) error {
+	var trades int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM trades WHERE symbol = ? AND trade_date >= ?", a.Symbol, a.ExDate).Scan(&trades); err != nil {
+		return fmt.Errorf("failed to check trades since the ex-date: %v", err)
+	}
+	if trades > 0 {
+		return fmt.Errorf("%w: %d in %s", ErrTradesSinceExDate, trades, a.Symbol)
+	}
+
+	restate := func(query string) error {
+		res, err := tx.Exec(query, a.RatioOld, a.RatioNew, a.Symbol, a.ExDate)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		result.PriceRowsUpdated += n
+		return err
+	}
+	if err := restate("UPDATE market_data SET current_price = current_price * ? / ? WHERE symbol = ? AND timestamp < ?"); err != nil {
+		return fmt.Errorf("failed to restate market data: %v", err)
+	}
+	if err := restate("UPDATE price_history SET price = price * ? / ? WHERE symbol = ? AND timestamp < ?"); err != nil {
+		return fmt.Errorf("failed to restate price history: %v", err)
+	}
+
+	positions, err := lockSymbolPositions(tx, a.Symbol)
+	if err != nil {
+		return err
+	}
+
+	var price *float64
+	for _, position := range positions {
+		shares := abs(position.Quantity) * a.RatioNew
+		adj := CorporateActionAdjustment{
+			ActionID:        a.ID,
+			ClientID:        position.ClientID,
+			Symbol:          a.Symbol,
+			QuantityBefore:  position.Quantity,
+			QuantityAfter:   shares / a.RatioOld,
+			CostBasisBefore: position.CostBasis,
+			CostBasisAfter:  position.CostBasis.Mul(float64(a.RatioOld) / float64(a.RatioNew)),
+		}
+		if position.Quantity < 0 {
+			adj.QuantityAfter = -adj.QuantityAfter
+		}
+
+		lots, err := lockOpenLots(tx, position.ClientID, a.Symbol)
+		if err != nil {
+			return err
+		}
+		fractions := splitLots(lots, a.RatioNew, a.RatioOld)
+		for _, lot := range lots {
+			if err := saveSplitLot(tx, &lot); err != nil {
+				return err
+			}
+		}
+		if len(lots) > 0 {
+			_, adj.CostBasisAfter = LotsCostBasis(lots)
+		}
+
+		position.Quantity = adj.QuantityAfter
+		position.CostBasis = adj.CostBasisAfter
+		if err := savePosition(tx, &position); err != nil {
+			return err
+		}
+
+		if fraction := shares % a.RatioOld; fraction != 0 {
+			if price == nil {
+				latest, err := latestPrice(tx, a.Symbol)
+				if err != nil {
+					return err
+				}
+				price = &latest
+			}
+			cash := float64(fraction) / float64(a.RatioOld) * *price
+			if adj.QuantityBefore < 0 {
+				cash = -cash
+			}
+			adj.CashAmount = NewDecimal(cash)
+			description := fmt.Sprintf("Cash in lieu of %.4f %s shares, %d-for-%d %s",
+				float64(fraction)/float64(a.RatioOld), a.Symbol, a.RatioNew, a.RatioOld, a.Type)
+			if err := postActionCash(tx, &adj, CashCorporateAction, description, a.ExDate); err != nil {
+				return err
+			}
+		}
+		adj.Reliefs = splitReliefs(a, lots, fractions, adj.CashAmount)
+		for i := range adj.Reliefs {
+			if err := insertLotRelief(tx, &adj.Reliefs[i]); err != nil {
+				return err
+			}
+		}
+		result.Adjustments = append(result.Adjustments, adj)
+	}
+	return nil
+}
+
+// splitFraction is part of a lot left over as a fraction of a share by a
+// split: units in ratioOld parts of a split share, and their cost
+type splitFraction struct {
+	lot   int
+	units int
+	cost  Decimal
+}
+
+// splitLots restates lots for a split and returns the fractional shares left over
+func splitLots(lots []TaxLot, ratioNew, ratioOld int) []splitFraction {
+	var carried []splitFraction
+	for i := range lots {
+		lot := &lots[i]
+		own := splitFraction{lot: i, units: lot.RemainingQuantity * ratioNew, cost: lot.CostPerShare.MulInt(lot.RemainingQuantity)}
+		units, cost := own.units, own.cost
+		for _, f := range carried {
+			units += f.units
+			cost += f.cost
+		}
+
+		lot.RemainingQuantity = units / ratioOld
+		lot.Quantity = max(lot.Quantity*ratioNew/ratioOld, lot.RemainingQuantity)
+		if lot.RemainingQuantity == 0 {
+			lot.CostPerShare = lot.CostPerShare.Mul(float64(ratioOld) / float64(ratioNew))
+			carried = append(carried, own)
+			continue
+		}
+
+		// The fraction left over takes what the whole shares do not of the cost
+		lot.CostPerShare = cost.Mul(float64(ratioOld) / float64(units))
+		carried = nil
+		if left := units % ratioOld; left > 0 {
+			carried = []splitFraction{{lot: i, units: left, cost: cost - lot.CostPerShare.MulInt(lot.RemainingQuantity)}}
+		}
+	}
+	return carried
+}
+
+// splitReliefs returns the zero-quantity reliefs of fractions against cash in lieu
+func splitReliefs(a *CorporateAction, lots []TaxLot, fractions []splitFraction, cash Decimal) []LotRelief {
+	var units int
+	for _, f := range fractions {
+		units += f.units
+	}
+
+	var reliefs []LotRelief
+	remaining := cash
+	for i, f := range fractions {
+		lot := lots[f.lot]
+		share := remaining
+		if i < len(fractions)-1 {
+			share = cash.Mul(float64(f.units) / float64(units))
+		}
+		remaining -= share
+
+		// Per share values are of a whole split share
+		shares := float64(f.units) / float64(a.RatioOld)
+		relief := LotRelief{
+			LotID:            lot.ID,
+			ActionID:         &a.ID,
+			ClientID:         lot.ClientID,
+			Symbol:           a.Symbol,
+			CostPerShare:     f.cost.Div(shares),
+			ProceedsPerShare: share.Div(shares),
+			RealizedPnL:      share - f.cost,
+			OpenDate:         lot.OpenDate,
+			CloseDate:        a.ExDate,
+		}
+		// A short position pays the cash in lieu, against the proceeds of the lot
+		if lot.Side == LotShort {
+			relief.CostPerShare, relief.ProceedsPerShare = (-share).Div(shares), f.cost.Div(shares)
+			relief.RealizedPnL = f.cost + share
+		}
+		reliefs = append(reliefs, relief)
+	}
+	return reliefs
+}
+
+// saveSplitLot writes a lot restated by a split, closing it if nothing of it
+// remains
+func saveSplitLot(tx *sql.Tx, lot *TaxLot) error {
+	_, err := tx.Exec(`
+		UPDATE tax_lots
+		SET quantity = ?, remaining_quantity = ?, cost_per_share = ?,
+			closed_at = CASE WHEN ? = 0 THEN NOW() ELSE closed_at END
+		WHERE id = ?
+	`, lot.Quantity, lot.RemainingQuantity, lot.CostPerShare, lot.RemainingQuantity, lot.ID)
+	return err
+}
+
+// applyDividend records the dividend due on each position held in the symbol
+// at the close before the ex-date, to be paid on the pay date, so that trades
+// booked on or after the ex-date do not change who is paid. Short positions
+// owe the dividend.
+func applyDividend(tx *sql.Tx, a *CorporateAction, result *CorporateActionResult) error {
+	holders, err := exDateHolders(tx, a.Symbol, a.ExDate)
+	if err != nil {
+		return err
+	}
+
+	for _, holder := range holders {
+		result.Adjustments = append(result.Adjustments, CorporateActionAdjustment{
+			ActionID:        a.ID,
+			ClientID:        holder.ClientID,
+			Symbol:          a.Symbol,
+			QuantityBefore:  holder.Quantity,
+			QuantityAfter:   holder.Quantity,
+			CostBasisBefore: holder.CostBasis,
+			CostBasisAfter:  holder.CostBasis,
+			CashAmount:      NewDecimal(a.AmountPerShare).MulInt(holder.Quantity),
+		})
+	}
+	return nil
+}
+
+// exDateHolders works out the positions in a symbol at the close before the
+// ex-date from the trade ledger: each client's current position, locked, less
+// the trades booked on or after the ex-date. Clients who have since closed
+// their position are included at what they held, with no cost basis; clients
+// who have only opened one since are left out. The positions have no ID.
+func exDateHolders(tx *sql.Tx, symbol string, exDate time.Time) ([]Position, error) {
+	if _, err := lockSymbolPositions(tx, symbol); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT client_id, SUM(quantity), MAX(cost_basis)
+		FROM (
+			SELECT client_id, quantity, cost_basis
+			FROM positions
+			WHERE symbol = ?
+			UNION ALL
+			SELECT client_id, IF(side = ?, -quantity, quantity), 0
+			FROM trades
+			WHERE symbol = ? AND trade_date >= ?
+		) held
+		GROUP BY client_id
+		HAVING SUM(quantity) <> 0
+		ORDER BY client_id
+	`, symbol, TradeBuy, symbol, exDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holders []Position
+	for rows.Next() {
+		p := Position{Symbol: symbol}
+		if err := rows.Scan(&p.ClientID, &p.Quantity, &p.CostBasis); err != nil {
+			return nil, err
+		}
+		holders = append(holders, p)
+	}
+
+	return holders, rows.Err()
+}
+
+// applySymbolChange moves the positions, open tax lots, prices, borrow rate,
+// maintenance rules and instrument of a symbol to its new symbol. Trades and
+// closed lots keep the symbol they were booked under. The old instrument is
+// kept as delisted.
+func applySymbolChange(tx *sql.Tx, a *CorporateAction, result *CorporateActionResult) error {
+	positions, err := lockSymbolPositions(tx, a.Symbol)
+	if err != nil {
+		return err
+	}
+	for _, position := range positions {
+		result.Adjustments = append(result.Adjustments, CorporateActionAdjustment{
+			ActionID:        a.ID,
+			ClientID:        position.ClientID,
+			Symbol:          a.NewSymbol,
+			QuantityBefore:  position.Quantity,
+			QuantityAfter:   position.Quantity,
+			CostBasisBefore: position.CostBasis,
+			CostBasisAfter:  position.CostBasis,
+		})
+	}
+
+	_, err = tx.Exec(`
+		INSERT IGNORE INTO instruments (symbol, name, asset_class, exchange, currency, sector, lot_size, marginable, status, created_at, updated_at)
+		SELECT ?, name, asset_class, exchange, currency, sector, lot_size, marginable, ?, NOW(), NOW()
+		FROM instruments
+		WHERE symbol = ?
+	`, a.NewSymbol, InstrumentActive, a.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to copy instrument: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE instruments SET status = ?, updated_at = NOW() WHERE symbol = ?", InstrumentDelisted, a.Symbol); err != nil {
+		return fmt.Errorf("failed to delist instrument: %v", err)
+	}
+
+	renames := []string{
+		"UPDATE positions SET symbol = ? WHERE symbol = ?",
+		"UPDATE tax_lots SET symbol = ? WHERE symbol = ? AND remaining_quantity > 0",
+		"UPDATE IGNORE borrow_rates SET symbol = ? WHERE symbol = ?",
+		"UPDATE maintenance_rules SET symbol = ? WHERE symbol = ?",
+	}
+	for _, query := range renames {
+		if _, err := tx.Exec(query, a.NewSymbol, a.Symbol); err != nil {
+			return fmt.Errorf("failed to rename %s: %v", a.Symbol, err)
+		}
+	}
+	for _, query := range []string{
+		"UPDATE market_data SET symbol = ? WHERE symbol = ?",
+		"UPDATE price_history SET symbol = ? WHERE symbol = ?",
+	} {
+		res, err := tx.Exec(query, a.NewSymbol, a.Symbol)
+		if err != nil {
+			return fmt.Errorf("failed to rename %s prices: %v", a.Symbol, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		result.PriceRowsUpdated += n
+	}
+	return nil
+}
+
+// Pay posts the dividends recorded by an applied cash dividend to each holder's
+// cash ledger, converted into their base currency, and marks it paid. The
+// dividends of holders without a margin account are left unposted. It returns
+// nil if there is no action with the ID.
+func (cas *CorporateActionService) Pay(id int64) (*CorporateActionResult, error) {
+	tx, err := cas.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + corporateActionColumns + ` FROM corporate_actions WHERE id = ? FOR UPDATE`
+	a, err := scanCorporateAction(tx.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.Type != ActionCashDividend || a.Status != ActionApplied {
+		return nil, fmt.Errorf("only applied cash dividends can be paid")
+	}
+
+	adjustments, err := cas.GetAdjustments(a.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range adjustments {
+		adj := &adjustments[i]
+		if adj.CashTransactionID != nil || adj.CashAmount == 0 {
+			continue
+		}
+		description := fmt.Sprintf("Dividend of %.4f per share on %d %s", a.AmountPerShare, adj.QuantityBefore, a.Symbol)
+		if adj.QuantityBefore < 0 {
+			description = fmt.Sprintf("Payment in lieu of dividend of %.4f per share on %d %s short", a.AmountPerShare, -adj.QuantityBefore, a.Symbol)
+		}
+		if err := postActionCash(tx, adj, CashDividend, description, *a.PayDate); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE corporate_action_adjustments SET cash_transaction_id = ? WHERE id = ?", adj.CashTransactionID, adj.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE corporate_actions
+		SET status = ?, paid_at = NOW(), last_error = NULL, failed_at = NULL, updated_at = NOW()
+		WHERE id = ?
+	`, ActionPaid, a.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	a.Status = ActionPaid
+	a.PaidAt = &now
+	a.LastError, a.FailedAt = "", nil
+	return &CorporateActionResult{Action: a, Adjustments: adjustments}, nil
+}
+
+// GetDueActionIDs retrieves the pending actions whose ex-date is on or before
+// date, oldest first
+func (cas *CorporateActionService) GetDueActionIDs(date time.Time) ([]int64, error) {
+	return cas.actionIDs(`
+		SELECT id FROM corporate_actions
+		WHERE status = ? AND ex_date <= ?
+		ORDER BY ex_date, id
+	`, ActionPending, date)
+}
+
+// GetPayableDividendIDs retrieves the applied cash dividends whose pay date is
+// on or before date, oldest first
+func (cas *CorporateActionService) GetPayableDividendIDs(date time.Time) ([]int64, error) {
+	return cas.actionIDs(`
+		SELECT id FROM corporate_actions
+		WHERE status = ? AND action_type = ? AND pay_date <= ?
+		ORDER BY pay_date, id
+	`, ActionApplied, ActionCashDividend, date)
+}
+
+// maxActionErrorLength is the length of the last_error column
+const maxActionErrorLength = 255
+
+// RecordFailure marks an action as having failed to apply or pay with cause.
+// The action keeps its status, so it is tried again.
+func (cas *CorporateActionService) RecordFailure(id int64, cause error) error {
+	message := cause.Error()
+	if len(message) > maxActionErrorLength {
+		message = message[:maxActionErrorLength]
+	}
+
+	_, err := cas.DB.Exec(`
+		UPDATE corporate_actions
+		SET last_error = ?, failed_at = NOW(), updated_at = NOW()
+		WHERE id = ?
+	`, message, id)
+	return err
+}
+
+// actionIDs runs a query selecting corporate action IDs
+func (cas *CorporateActionService) actionIDs(query string, args ...interface{}) ([]int64, error) {
+	rows, err := cas.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// lockSymbolPositions loads and locks every position in a symbol
+func lockSymbolPositions(tx *sql.Tx, symbol string) ([]Position, error) {
+	rows, err := tx.Query(`
+		SELECT id, client_id, symbol, quantity, cost_basis, created_at, updated_at
+		FROM positions
+		WHERE symbol = ?
+		ORDER BY client_id
+		FOR UPDATE
+	`, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []Position
+	for rows.Next() {
+		var p Position
+		if err := rows.Scan(&p.ID, &p.ClientID, &p.Symbol, &p.Quantity, &p.CostBasis, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		positions = append(positions, p)
+	}
+
+	return positions, rows.Err()
+}
+
+// latestPrice returns the latest stored price of a symbol
+func latestPrice(tx *sql.Tx, symbol string) (float64, error) {
+	var price float64
+	err := tx.QueryRow(`
+		SELECT current_price
+		FROM market_data
+		WHERE symbol = ?
+		ORDER BY timestamp DESC
+		LIMIT 1
+	`, symbol).Scan(&price)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no price for %s to pay cash in lieu at", symbol)
+	}
+	return price, err
+}
+
+// postActionCash posts an adjustment's cash amount to the client's cash ledger,
+// converted into their base currency. The cash of a client without a margin
+// account is left unposted, with no cash transaction.
+func postActionCash(tx *sql.Tx, adj *CorporateActionAdjustment, cashType CashTransactionType, description string, date time.Time) error {
+	currency, fxRate, err := settlementRate(tx, adj.ClientID, adj.Symbol)
+	if errors.Is(err, ErrNoMarginAccount) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fxRate != 1 {
+		description += fmt.Sprintf(" %s at %.6f", currency, fxRate)
+	}
+
+	ct := &CashTransaction{
+		ClientID:      adj.ClientID,
+		Type:          cashType,
+		Amount:        adj.CashAmount.Mul(fxRate),
+		Description:   description,
+		EffectiveDate: date,
+	}
+	if err := postCash(tx, ct); err != nil {
+		return err
+	}
+	adj.CashTransactionID = &ct.ID
+	return nil
+}
+
+// insertAdjustment records a position adjustment made by an action
+func insertAdjustment(tx *sql.Tx, adj *CorporateActionAdjustment) error {
+	result, err := tx.Exec(`
+		INSERT INTO corporate_action_adjustments (action_id, client_id, symbol, quantity_before, quantity_after,
+			cost_basis_before, cost_basis_after, cash_amount, cash_transaction_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`, adj.ActionID, adj.ClientID, adj.Symbol, adj.QuantityBefore, adj.QuantityAfter,
+		adj.CostBasisBefore, adj.CostBasisAfter, adj.CashAmount, adj.CashTransactionID)
+	if err != nil {
+		return err
+	}
+
+	adj.ID, err = result.LastInsertId()
+	return err
+}
@@ -0,0 +1,127 @@
+package models
+
+import "testing"
+
+func TestSplitLots(t *testing.T) {
+	tests := []struct {
+		name          string
+		lots          []TaxLot
+		ratioNew      int
+		ratioOld      int
+		wantRemaining []int
+		wantCost      []Decimal
+		wantFractions []splitFraction
+	}{
+		{
+			name: "forward split",
+			lots: []TaxLot{
+				{ID: 1, Quantity: 10, RemainingQuantity: 10, CostPerShare: NewDecimal(100)},
+				{ID: 2, Quantity: 5, RemainingQuantity: 5, CostPerShare: NewDecimal(80)},
+			},
+			ratioNew: 4, ratioOld: 1,
+			wantRemaining: []int{40, 20},
+			wantCost:      []Decimal{NewDecimal(25), NewDecimal(20)},
+		},
+		{
+			name: "reverse split carries fractions into the next lot",
+			lots: []TaxLot{
+				{ID: 1, Quantity: 15, RemainingQuantity: 15, CostPerShare: NewDecimal(10)},
+				{ID: 2, Quantity: 7, RemainingQuantity: 7, CostPerShare: NewDecimal(20)},
+			},
+			ratioNew: 1, ratioOld: 10,
+			wantRemaining: []int{1, 1},
+			wantCost:      []Decimal{NewDecimal(100), NewDecimal(158.3333)},
+			wantFractions: []splitFraction{{lot: 1, units: 2, cost: NewDecimal(31.6667)}},
+		},
+		{
+			name: "reverse split rounding a position to nothing",
+			lots: []TaxLot{
+				{ID: 1, Quantity: 5, RemainingQuantity: 5, CostPerShare: NewDecimal(10)},
+				{ID: 2, Quantity: 3, RemainingQuantity: 3, CostPerShare: NewDecimal(20)},
+			},
+			ratioNew: 1, ratioOld: 10,
+			wantRemaining: []int{0, 0},
+			wantCost:      []Decimal{NewDecimal(100), NewDecimal(200)},
+			wantFractions: []splitFraction{{lot: 0, units: 5, cost: NewDecimal(50)}, {lot: 1, units: 3, cost: NewDecimal(60)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var costBefore Decimal
+			for _, lot := range tt.lots {
+				costBefore += lot.CostPerShare.MulInt(lot.RemainingQuantity)
+			}
+
+			fractions := splitLots(tt.lots, tt.ratioNew, tt.ratioOld)
+
+			var costAfter Decimal
+			for i, lot := range tt.lots {
+				if lot.RemainingQuantity != tt.wantRemaining[i] || lot.CostPerShare != tt.wantCost[i] {
+					t.Errorf("lot %d = %d at %s, want %d at %s", lot.ID, lot.RemainingQuantity, lot.CostPerShare, tt.wantRemaining[i], tt.wantCost[i])
+				}
+				costAfter += lot.CostPerShare.MulInt(lot.RemainingQuantity)
+			}
+			if len(fractions) != len(tt.wantFractions) {
+				t.Fatalf("fractions = %+v, want %+v", fractions, tt.wantFractions)
+			}
+			for i, f := range fractions {
+				if f != tt.wantFractions[i] {
+					t.Errorf("fraction %d = %+v, want %+v", i, f, tt.wantFractions[i])
+				}
+				costAfter += f.cost
+			}
+			if costAfter != costBefore {
+				t.Errorf("cost after the split = %s, want %s", costAfter, costBefore)
+			}
+		})
+	}
+}
+
+func TestSplitReliefs(t *testing.T) {
+	a := &CorporateAction{ID: 3, Symbol: "XYZ", Type: ActionReverseSplit, RatioNew: 1, RatioOld: 10}
+	lots := []TaxLot{
+		{ID: 1, Side: LotLong, ClientID: 9},
+		{ID: 2, Side: LotLong, ClientID: 9},
+	}
+	fractions := []splitFraction{{lot: 0, units: 5, cost: NewDecimal(50)}, {lot: 1, units: 3, cost: NewDecimal(60)}}
+
+	reliefs := splitReliefs(a, lots, fractions, NewDecimal(160))
+	if len(reliefs) != 2 {
+		t.Fatalf("got %d reliefs, want 2", len(reliefs))
+	}
+	if r := reliefs[0]; r.LotID != 1 || r.Quantity != 0 || r.ProceedsPerShare != NewDecimal(200) || r.RealizedPnL != NewDecimal(50) {
+		t.Errorf("first relief = %+v, want no shares at 200 realizing 50", r)
+	}
+	if r := reliefs[1]; r.LotID != 2 || r.Quantity != 0 || r.CostPerShare != NewDecimal(200) || r.RealizedPnL != NewDecimal(0) {
+		t.Errorf("second relief = %+v, want no shares at cost 200 realizing 0", r)
+	}
+
+	lots[0].Side, lots[1].Side = LotShort, LotShort
+	var pnl Decimal
+	for _, r := range splitReliefs(a, lots, fractions, NewDecimal(-160)) {
+		pnl += r.RealizedPnL
+	}
+	if want := NewDecimal(110 - 160); pnl != want {
+		t.Errorf("short realized P&L = %s, want %s", pnl, want)
+	}
+}
+
+func TestSplitReliefsSmallFraction(t *testing.T) {
+	a := &CorporateAction{ID: 4, Symbol: "XYZ", Type: ActionReverseSplit, RatioNew: 1, RatioOld: 10}
+	lots := []TaxLot{{ID: 1, Side: LotLong, ClientID: 9, Quantity: 13, RemainingQuantity: 13, CostPerShare: NewDecimal(10)}}
+
+	// 13 shares become 1.3, leaving 0.3 of a share paid out at 90
+	fractions := splitLots(lots, a.RatioNew, a.RatioOld)
+	reliefs := splitReliefs(a, lots, fractions, NewDecimal(27))
+	if lots[0].RemainingQuantity != 1 || lots[0].CostPerShare != NewDecimal(100) {
+		t.Errorf("lot = %d at %s, want 1 at 100", lots[0].RemainingQuantity, lots[0].CostPerShare)
+	}
+	if len(reliefs) != 1 {
+		t.Fatalf("got %d reliefs, want 1", len(reliefs))
+	}
+	r := reliefs[0]
+	if r.Quantity != 0 || r.CostPerShare != NewDecimal(100) || r.ProceedsPerShare != NewDecimal(90) || r.RealizedPnL != NewDecimal(-3) {
+		t.Errorf("relief = %+v, want no shares at cost 100 and proceeds 90 realizing -3", r)
+	}
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Decimal is a fixed-point amount with DecimalPlaces decimal places, the
+// precision money is stored at in DECIMAL(20, 4) columns. Like time.Duration it
+// is an integer count of units, so amounts are added, subtracted and compared
+// exactly with the usual operators; multiplying or dividing by a rate or
+// price rounds the result half away from zero to the nearest unit.
+type Decimal int64
+
+// DecimalPlaces is the number of decimal places a Decimal holds
+const DecimalPlaces = 4
+
+// decimalUnit is the number of units in one
+const decimalUnit = 10000
+
+// NewDecimal returns the Decimal nearest to f
+func NewDecimal(f float64) Decimal {
+	return Decimal(math.Round(f * decimalUnit))
+}
+
+// ParseDecimal parses a decimal string such as "-1234.5678". Digits beyond
+// DecimalPlaces are rounded half away from zero.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("invalid decimal: empty string")
+	}
+
+	negative := false
+	digits := s
+	if digits[0] == '-' || digits[0] == '+' {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid decimal: %q", s)
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			// Fall back to float parsing for exponents
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid decimal: %q", s)
+			}
+			return NewDecimal(f), nil
+		}
+	}
+
+	roundUp := len(frac) > DecimalPlaces && frac[DecimalPlaces] >= '5'
+	if len(frac) > DecimalPlaces {
+		frac = frac[:DecimalPlaces]
+	}
+	frac += strings.Repeat("0", DecimalPlaces-len(frac))
+
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal: %q", s)
+	}
+	if roundUp {
+		units++
+	}
+	if negative {
+		units = -units
+	}
+	return Decimal(units), nil
+}
+
+// Float64 returns the amount as a float, for ratios and reporting
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalUnit
+}
+
+// MulInt returns the amount times n, exactly
+func (d Decimal) MulInt(n int) Decimal {
+	return d * Decimal(n)
+}
+
+// Mul returns the amount times f, rounded to the nearest unit
+func (d Decimal) Mul(f float64) Decimal {
+	return Decimal(math.Round(float64(d) * f))
+}
+
+// Div returns the amount divided by f, rounded to the nearest unit. Dividing
+// by zero returns zero.
+func (d Decimal) Div(f float64) Decimal {
+	if f == 0 {
+		return 0
+	}
+	return Decimal(math.Round(float64(d) / f))
+}
+
+// Ratio returns the amount as a fraction of o, or zero if o is zero
+func (d Decimal) Ratio(o Decimal) float64 {
+	if o == 0 {
+		return 0
+	}
+	return float64(d) / float64(o)
+}
+
+// Abs returns the absolute amount
+func (d Decimal) Abs() Decimal {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// MaxDecimal returns the larger of a and b
+func MaxDecimal(a, b Decimal) Decimal {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// MinDecimal returns the smaller of a and b
+func MinDecimal(a, b Decimal) Decimal {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// currencyPlaces lists the minor units of currencies that do not have two
+var currencyPlaces = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"CLP": 0,
+	"ISK": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"JOD": 3,
+}
+
+// CurrencyPlaces returns the number of decimal places amounts in a currency
+// are settled at: two unless the currency has other minor units
+func CurrencyPlaces(currency string) int {
+	if places, ok := currencyPlaces[currency]; ok {
+		return places
+	}
+	return 2
+}
+
+// Round rounds the amount half away from zero to the minor unit of a currency
+func (d Decimal) Round(currency string) Decimal {
+	step := Decimal(1)
+	for i := CurrencyPlaces(currency); i < DecimalPlaces; i++ {
+		step *= 10
+	}
+	if step == 1 {
+		return d
+	}
+	half := step / 2
+	if d < 0 {
+		return -((-d + half) / step * step)
+	}
+	return (d + half) / step * step
+}
+
+// Format rounds the amount to the minor unit of a currency and formats it with
+// that many decimal places, e.g. "1234.57 USD" or "1235 JPY"
+func (d Decimal) Format(currency string) string {
+	s := d.Round(currency).String()
+	if places := CurrencyPlaces(currency); places == 0 {
+		s = s[:len(s)-DecimalPlaces-1]
+	} else {
+		s = s[:len(s)-DecimalPlaces+places]
+	}
+	return s + " " + currency
+}
+
+// String formats the amount with DecimalPlaces decimal places
+func (d Decimal) String() string {
+	sign := ""
+	units := int64(d)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/decimalUnit, DecimalPlaces, units%decimalUnit)
+}
+
+// MarshalJSON encodes the amount as a JSON number without trailing zeros
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	s := strings.TrimRight(d.String(), "0")
+	return []byte(strings.TrimSuffix(s, ".")), nil
+}
+
+// UnmarshalJSON decodes the amount from a JSON number or string
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan reads the amount from a DECIMAL column
+func (d *Decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case []byte:
+		parsed, err := ParseDecimal(string(v))
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case string:
+		parsed, err := ParseDecimal(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case int64:
+		*d = Decimal(v * decimalUnit)
+		return nil
+	case float64:
+		*d = NewDecimal(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", value)
+	}
+}
+
+// Value writes the amount to a DECIMAL column as an exact string
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
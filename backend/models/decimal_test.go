@@ -0,0 +1,155 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Decimal
+		wantErr bool
+	}{
+		{in: "1234.5678", want: 12345678},
+		{in: "-1234.5678", want: -12345678},
+		{in: "+12.5", want: 125000},
+		{in: " 7 ", want: 70000},
+		{in: ".25", want: 2500},
+		{in: "-0.0001", want: -1},
+		{in: "1.23455", want: 12346},
+		{in: "1.23454", want: 12345},
+		{in: "-1.23455", want: -12346},
+		{in: "1e2", want: 1000000},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "12a", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want Decimal
+	}{
+		{name: "MulInt is exact", got: Decimal(12345).MulInt(3), want: 37035},
+		{name: "Mul rounds half away from zero", got: Decimal(5).Mul(0.5), want: 3},
+		{name: "Mul rounds negative half away from zero", got: Decimal(-5).Mul(0.5), want: -3},
+		{name: "Mul rounds down below half", got: Decimal(10000).Mul(1.00004), want: 10000},
+		{name: "Div rounds to the nearest unit", got: NewDecimal(10).Div(3), want: 33333},
+		{name: "Div rounds half away from zero", got: Decimal(-3).Div(2), want: -2},
+		{name: "Div by zero is zero", got: NewDecimal(10).Div(0), want: 0},
+		{name: "NewDecimal rounds to the nearest unit", got: NewDecimal(0.12345), want: 1235},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %d units, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDecimalRoundAndFormat(t *testing.T) {
+	tests := []struct {
+		amount     Decimal
+		currency   string
+		wantRound  Decimal
+		wantFormat string
+	}{
+		{amount: 12345678, currency: "USD", wantRound: 12345700, wantFormat: "1234.57 USD"},
+		{amount: 12345000, currency: "USD", wantRound: 12345000, wantFormat: "1234.50 USD"},
+		{amount: -12345, currency: "USD", wantRound: -12300, wantFormat: "-1.23 USD"},
+		{amount: -12350, currency: "EUR", wantRound: -12400, wantFormat: "-1.24 EUR"},
+		{amount: 12345000, currency: "JPY", wantRound: 12350000, wantFormat: "1235 JPY"},
+		{amount: 12344999, currency: "JPY", wantRound: 12340000, wantFormat: "1234 JPY"},
+		{amount: 12345678, currency: "KWD", wantRound: 12345680, wantFormat: "1234.568 KWD"},
+		{amount: 0, currency: "GBP", wantRound: 0, wantFormat: "0.00 GBP"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Round(tt.currency); got != tt.wantRound {
+			t.Errorf("%s.Round(%s) = %s, want %s", tt.amount, tt.currency, got, tt.wantRound)
+		}
+		if got := tt.amount.Format(tt.currency); got != tt.wantFormat {
+			t.Errorf("%s.Format(%s) = %q, want %q", tt.amount, tt.currency, got, tt.wantFormat)
+		}
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	for _, d := range []Decimal{0, 1, -1, 12345678, -12300000, 10000} {
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("Marshal(%s) returned error: %v", d, err)
+		}
+		var got Decimal
+		if err := json.Unmarshal(data, &got); err != nil || got != d {
+			t.Errorf("round trip of %s through %s = %s, %v", d, data, got, err)
+		}
+	}
+
+	if data, _ := json.Marshal(Decimal(12300000)); string(data) != "1230" {
+		t.Errorf("Marshal(1230.0000) = %s, want 1230", data)
+	}
+
+	var fromString Decimal
+	if err := json.Unmarshal([]byte(`"1250.50"`), &fromString); err != nil || fromString != 12505000 {
+		t.Errorf(`Unmarshal("1250.50") = %s, %v, want 1250.5000`, fromString, err)
+	}
+	fromNull := Decimal(7)
+	if err := json.Unmarshal([]byte(`null`), &fromNull); err != nil || fromNull != 7 {
+		t.Errorf("Unmarshal(null) = %s, %v, want it unchanged", fromNull, err)
+	}
+	var invalid Decimal
+	if err := json.Unmarshal([]byte(`"abc"`), &invalid); err == nil {
+		t.Error(`Unmarshal("abc") returned no error`)
+	}
+}
+
+func TestDecimalScanAndValue(t *testing.T) {
+	for _, d := range []Decimal{0, 1, -1, 12345678, -12300000} {
+		value, err := d.Value()
+		if err != nil {
+			t.Fatalf("Value(%s) returned error: %v", d, err)
+		}
+		var got Decimal
+		if err := got.Scan([]byte(value.(string))); err != nil || got != d {
+			t.Errorf("round trip of %s through %q = %s, %v", d, value, got, err)
+		}
+	}
+
+	tests := []struct {
+		value interface{}
+		want  Decimal
+	}{
+		{value: "12.3456", want: 123456},
+		{value: int64(-3), want: -30000},
+		{value: 1.5, want: 15000},
+		{value: nil, want: 0},
+	}
+	for _, tt := range tests {
+		got := Decimal(99)
+		if err := got.Scan(tt.value); err != nil || got != tt.want {
+			t.Errorf("Scan(%#v) = %s, %v, want %s", tt.value, got, err, tt.want)
+		}
+	}
+
+	var d Decimal
+	if err := d.Scan(true); err == nil {
+		t.Error("Scan(true) returned no error")
+	}
+}
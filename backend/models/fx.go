@@ -0,0 +1,189 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is the currency of accounts and symbols without one, and the
+// currency FX rates are fetched against
+const DefaultCurrency = "USD"
+
+// ErrNoFXRate is returned when an amount cannot be converted into an account's
+// base currency
+var ErrNoFXRate = errors.New("no FX rate")
+
+// FXRate is the latest rate of a currency pair: one unit of FromCurrency is
+// worth Rate units of ToCurrency
+type FXRate struct {
+	FromCurrency string        `json:"from_currency"`
+	ToCurrency   string        `json:"to_currency"`
+	Rate         float64       `json:"rate"`
+	Timestamp    time.Time     `json:"timestamp"`
+	Age          time.Duration `json:"-"`
+}
+
+// Pair returns the six-letter name of the currency pair, e.g. EURUSD
+func (r FXRate) Pair() string {
+	return r.FromCurrency + r.ToCurrency
+}
+
+// ParseCurrencyPair splits a six-letter currency pair such as EURUSD
+func ParseCurrencyPair(pair string) (string, string, error) {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+	if len(pair) != 6 {
+		return "", "", fmt.Errorf("currency pair must be six letters, e.g. EURUSD")
+	}
+	return pair[:3], pair[3:], nil
+}
+
+// FXRates holds the latest rate of each stored currency pair, by pair
+type FXRates map[string]FXRate
+
+// Convert returns the rate converting an amount in one currency into another
+// and the age of the rates used. Pairs without a rate are inverted or crossed
+// through DefaultCurrency. ok is false if there is no way to convert.
+func (r FXRates) Convert(from, to string) (rate float64, age time.Duration, ok bool) {
+	if from == "" {
+		from = DefaultCurrency
+	}
+	if to == "" {
+		to = DefaultCurrency
+	}
+	if from == to {
+		return 1, 0, true
+	}
+	if fx, found := r[from+to]; found && fx.Rate > 0 {
+		return fx.Rate, fx.Age, true
+	}
+	if fx, found := r[to+from]; found && fx.Rate > 0 {
+		return 1 / fx.Rate, fx.Age, true
+	}
+	if from == DefaultCurrency || to == DefaultCurrency {
+		return 0, 0, false
+	}
+
+	fromRate, fromAge, fromOK := r.Convert(from, DefaultCurrency)
+	toRate, toAge, toOK := r.Convert(DefaultCurrency, to)
+	if !fromOK || !toOK {
+		return 0, 0, false
+	}
+	if toAge > fromAge {
+		fromAge = toAge
+	}
+	return fromRate * toRate, fromAge, true
+}
+
+// queryer runs a query on a database or in a transaction
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getFXRates loads every stored FX rate
+func getFXRates(q queryer) (FXRates, error) {
+	rows, err := q.Query(`
+		SELECT from_currency, to_currency, rate, timestamp, TIMESTAMPDIFF(SECOND, timestamp, NOW())
+		FROM fx_rates
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make(FXRates)
+	for rows.Next() {
+		var r FXRate
+		var ageSeconds int64
+		if err := rows.Scan(&r.FromCurrency, &r.ToCurrency, &r.Rate, &r.Timestamp, &ageSeconds); err != nil {
+			return nil, err
+		}
+		if ageSeconds < 0 {
+			ageSeconds = 0
+		}
+		r.Age = time.Duration(ageSeconds) * time.Second
+		rates[r.Pair()] = r
+	}
+
+	return rates, rows.Err()
+}
+
+// FXService handles database operations for FX rates
+type FXService struct {
+	DB *sql.DB
+}
+
+// GetRates retrieves the latest rate of every currency pair
+func (fs *FXService) GetRates() (FXRates, error) {
+	return getFXRates(fs.DB)
+}
+
+// ListRates retrieves the latest rate of every currency pair, ordered by pair
+func (fs *FXService) ListRates() ([]FXRate, error) {
+	rates, err := fs.GetRates()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []FXRate{}
+	for _, r := range rates {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Pair() < list[j].Pair() })
+	return list, nil
+}
+
+// UpdateRate updates or inserts the rate of a currency pair
+func (fs *FXService) UpdateRate(from, to string, rate float64) error {
+	if rate <= 0 {
+		return fmt.Errorf("FX rate must be positive")
+	}
+
+	_, err := fs.DB.Exec(`
+		INSERT INTO fx_rates (from_currency, to_currency, rate, timestamp)
+		VALUES (?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE rate = VALUES(rate), timestamp = VALUES(timestamp)
+	`, from, to, rate)
+	return err
+}
+
+// GetCurrencies retrieves every currency symbols are quoted in or accounts are
+// held in, other than DefaultCurrency
+func (fs *FXService) GetCurrencies() ([]string, error) {
+	rows, err := fs.DB.Query(`
+		SELECT currency FROM market_data WHERE currency <> ?
+		UNION
+		SELECT base_currency FROM margins WHERE base_currency <> ?
+	`, DefaultCurrency, DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var currencies []string
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency)
+	}
+
+	return currencies, rows.Err()
+}
+
+// ValidateCurrency checks that a currency is a three-letter code
+func ValidateCurrency(currency string) error {
+	if len(currency) != 3 {
+		return fmt.Errorf("currency must be a three-letter code such as USD")
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return fmt.Errorf("currency must be a three-letter code such as USD")
+		}
+	}
+	return nil
+}
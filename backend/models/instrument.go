@@ -0,0 +1,257 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AssetClass is the kind of security an instrument is
+type AssetClass string
+
+// Asset classes
+const (
+	AssetEquity    AssetClass = "equity"
+	AssetETF       AssetClass = "etf"
+	AssetADR       AssetClass = "adr"
+	AssetPreferred AssetClass = "preferred"
+)
+
+// InstrumentStatus is whether an instrument can be traded
+type InstrumentStatus string
+
+// Instrument statuses
+const (
+	InstrumentActive   InstrumentStatus = "active"
+	InstrumentHalted   InstrumentStatus = "halted"
+	InstrumentDelisted InstrumentStatus = "delisted"
+)
+
+// MaxSymbolLength is the longest symbol the schema stores
+const MaxSymbolLength = 10
+
+// Instrument is the reference data of a symbol. Positions in an instrument that
+// is not Marginable have no loan value and are charged their full market value.
+type Instrument struct {
+	Symbol     string           `json:"symbol"`
+	Name       string           `json:"name"`
+	AssetClass AssetClass       `json:"asset_class"`
+	Exchange   string           `json:"exchange"`
+	Currency   string           `json:"currency"`
+	Sector     string           `json:"sector,omitempty"`
+	LotSize    int              `json:"lot_size"`
+	Marginable bool             `json:"marginable"`
+	Status     InstrumentStatus `json:"status"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// NewInstrument returns an active, marginable equity quoted in DefaultCurrency
+// and traded in single shares, the defaults of a new instrument
+func NewInstrument(symbol string) Instrument {
+	return Instrument{
+		Symbol:     symbol,
+		AssetClass: AssetEquity,
+		Currency:   DefaultCurrency,
+		LotSize:    1,
+		Marginable: true,
+		Status:     InstrumentActive,
+	}
+}
+
+// Validate checks that the instrument is well formed
+func (i *Instrument) Validate() error {
+	if i.Symbol == "" || len(i.Symbol) > MaxSymbolLength {
+		return fmt.Errorf("symbol must be 1 to %d characters", MaxSymbolLength)
+	}
+	if i.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch i.AssetClass {
+	case AssetEquity, AssetETF, AssetADR, AssetPreferred:
+	default:
+		return fmt.Errorf("unknown asset class: %s", i.AssetClass)
+	}
+	if err := ValidateCurrency(i.Currency); err != nil {
+		return err
+	}
+	if i.LotSize < 1 {
+		return fmt.Errorf("lot size must be at least 1")
+	}
+	switch i.Status {
+	case InstrumentActive, InstrumentHalted, InstrumentDelisted:
+	default:
+		return fmt.Errorf("unknown instrument status: %s", i.Status)
+	}
+	return nil
+}
+
+// ValidateQuantity checks that a quantity of the instrument can be opened: the
+// instrument is active and the quantity is a whole number of lots
+func (i *Instrument) ValidateQuantity(quantity int) error {
+	if i.Status != InstrumentActive {
+		return fmt.Errorf("%s is %s", i.Symbol, i.Status)
+	}
+	if quantity%i.LotSize != 0 {
+		return fmt.Errorf("%s trades in lots of %d", i.Symbol, i.LotSize)
+	}
+	return nil
+}
+
+// InstrumentFilter narrows an instrument listing; zero values match everything
+type InstrumentFilter struct {
+	AssetClass AssetClass
+	Sector     string
+	Status     InstrumentStatus
+}
+
+// InstrumentService handles database operations for the instrument master
+type InstrumentService struct {
+	DB *sql.DB
+}
+
+// instrumentColumns is the column list scanned by scanInstrument
+const instrumentColumns = `
+	symbol, name, asset_class, exchange, currency, sector, lot_size, marginable,
+	status, created_at, updated_at
+`
+
+// scanInstrument scans an instrument selected with instrumentColumns
+func scanInstrument(row rowScanner) (*Instrument, error) {
+	var i Instrument
+	var sector sql.NullString
+	err := row.Scan(
+		&i.Symbol,
+		&i.Name,
+		&i.AssetClass,
+		&i.Exchange,
+		&i.Currency,
+		&sector,
+		&i.LotSize,
+		&i.Marginable,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	i.Sector = sector.String
+	return &i, nil
+}
+
+// GetInstrument retrieves the instrument of a symbol
+func (is *InstrumentService) GetInstrument(symbol string) (*Instrument, error) {
+	query := `SELECT ` + instrumentColumns + ` FROM instruments WHERE symbol = ?`
+
+	instrument, err := scanInstrument(is.DB.QueryRow(query, symbol))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return instrument, err
+}
+
+// GetInstruments retrieves the instrument of each symbol. Symbols not in the
+// instrument master are omitted.
+func (is *InstrumentService) GetInstruments(symbols []string) (map[string]Instrument, error) {
+	instruments := make(map[string]Instrument)
+	if len(symbols) == 0 {
+		return instruments, nil
+	}
+
+	query := fmt.Sprintf(`SELECT `+instrumentColumns+` FROM instruments WHERE symbol IN (%s)`, inPlaceholders(len(symbols)))
+	rows, err := is.DB.Query(query, stringArgs(symbols)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		instrument, err := scanInstrument(rows)
+		if err != nil {
+			return nil, err
+		}
+		instruments[instrument.Symbol] = *instrument
+	}
+
+	return instruments, rows.Err()
+}
+
+// GetSectors retrieves the sector of each symbol. Unclassified symbols are omitted.
+func (is *InstrumentService) GetSectors(symbols []string) (map[string]string, error) {
+	instruments, err := is.GetInstruments(symbols)
+	if err != nil {
+		return nil, err
+	}
+	return instrumentSectors(instruments), nil
+}
+
+// instrumentSectors returns the sector of each classified instrument
+func instrumentSectors(instruments map[string]Instrument) map[string]string {
+	sectors := make(map[string]string)
+	for symbol, instrument := range instruments {
+		if instrument.Sector != "" {
+			sectors[symbol] = instrument.Sector
+		}
+	}
+	return sectors
+}
+
+// ListInstruments retrieves the instruments matching the filter, by symbol
+func (is *InstrumentService) ListInstruments(filter InstrumentFilter) ([]Instrument, error) {
+	query := `SELECT ` + instrumentColumns + ` FROM instruments WHERE 1 = 1`
+	var args []interface{}
+	if filter.AssetClass != "" {
+		query += " AND asset_class = ?"
+		args = append(args, filter.AssetClass)
+	}
+	if filter.Sector != "" {
+		query += " AND sector = ?"
+		args = append(args, filter.Sector)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	query += " ORDER BY symbol"
+
+	rows, err := is.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instruments := []Instrument{}
+	for rows.Next() {
+		instrument, err := scanInstrument(rows)
+		if err != nil {
+			return nil, err
+		}
+		instruments = append(instruments, *instrument)
+	}
+
+	return instruments, rows.Err()
+}
+
+// SaveInstrument updates or inserts the instrument of a symbol
+func (is *InstrumentService) SaveInstrument(i *Instrument) error {
+	if err := i.Validate(); err != nil {
+		return err
+	}
+
+	_, err := is.DB.Exec(`
+		INSERT INTO instruments (symbol, name, asset_class, exchange, currency, sector, lot_size, marginable, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE
+		name = VALUES(name),
+		asset_class = VALUES(asset_class),
+		exchange = VALUES(exchange),
+		currency = VALUES(currency),
+		sector = VALUES(sector),
+		lot_size = VALUES(lot_size),
+		marginable = VALUES(marginable),
+		status = VALUES(status),
+		updated_at = VALUES(updated_at)
+	`, i.Symbol, i.Name, i.AssetClass, i.Exchange, i.Currency, nullString(i.Sector), i.LotSize, i.Marginable, i.Status)
+	return err
+}
@@ -0,0 +1,479 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// InterestDayCount is the day count convention for margin loan interest
+const InterestDayCount = 360
+
+// InterestTier is a band of the loan balance charged the benchmark rate plus
+// Spread. A tier covers balances from MinBalance up to the next tier's
+// MinBalance; MaxBalance is nil for the last tier.
+type InterestTier struct {
+	MinBalance Decimal  `json:"min_balance"`
+	MaxBalance *Decimal `json:"max_balance,omitempty"`
+	Spread     float64  `json:"spread"`
+	AnnualRate float64  `json:"annual_rate"`
+}
+
+// InterestSchedule is the tiered rate schedule in effect on a date
+type InterestSchedule struct {
+	BenchmarkRate float64        `json:"benchmark_rate"`
+	EffectiveDate time.Time      `json:"effective_date"`
+	Tiers         []InterestTier `json:"tiers"`
+}
+
+// ValidateInterestTiers checks that tiers are a schedule starting at a zero
+// balance, and sorts them by balance
+func ValidateInterestTiers(tiers []InterestTier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("at least one tier is required")
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinBalance < tiers[j].MinBalance })
+	if tiers[0].MinBalance != 0 {
+		return fmt.Errorf("the first tier must start at a zero balance")
+	}
+	for i, tier := range tiers {
+		if tier.Spread < 0 {
+			return fmt.Errorf("spread cannot be negative")
+		}
+		if i > 0 && tier.MinBalance == tiers[i-1].MinBalance {
+			return fmt.Errorf("duplicate tier starting at %s", tier.MinBalance)
+		}
+	}
+	return nil
+}
+
+// DailyInterest returns one day's interest on a loan balance, charging each
+// band of the balance at its tier's rate, and the blended annual rate that
+// works out to. Credit balances are not paid interest.
+func (s *InterestSchedule) DailyInterest(balance Decimal) (Decimal, float64) {
+	if balance <= 0 {
+		return 0, 0
+	}
+
+	// The annual charge is summed over the bands and rounded once
+	var charge float64
+	for _, tier := range s.Tiers {
+		if balance <= tier.MinBalance {
+			break
+		}
+		band := balance - tier.MinBalance
+		if tier.MaxBalance != nil {
+			band = MinDecimal(band, *tier.MaxBalance-tier.MinBalance)
+		}
+		charge += band.Float64() * tier.AnnualRate
+	}
+	return NewDecimal(charge / InterestDayCount), charge / balance.Float64()
+}
+
+// InterestAccrual is one day's interest on a client's margin loan. Accruals are
+// added to the loan when they are capitalized at month end, by the cash ledger
+// entry CashTransactionID.
+type InterestAccrual struct {
+	ID                int64     `json:"id"`
+	ClientID          int64     `json:"client_id"`
+	AccrualDate       time.Time `json:"accrual_date"`
+	LoanBalance       Decimal   `json:"loan_balance"`
+	BenchmarkRate     float64   `json:"benchmark_rate"`
+	AnnualRate        float64   `json:"annual_rate"`
+	Interest          Decimal   `json:"interest"`
+	CashTransactionID *int64    `json:"cash_transaction_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Capitalized reports whether the accrual has been added to the loan
+func (a InterestAccrual) Capitalized() bool {
+	return a.CashTransactionID != nil
+}
+
+// InterestService handles the margin loan rate schedule and the accrual and
+// capitalization of interest
+type InterestService struct {
+	DB *sql.DB
+}
+
+// GetSchedule retrieves the schedule in effect on date: the latest benchmark
+// rate effective on or before it and the current tiers. It returns nil if no
+// benchmark rate is in effect.
+func (is *InterestService) GetSchedule(date time.Time) (*InterestSchedule, error) {
+	var schedule InterestSchedule
+	err := is.DB.QueryRow(`
+		SELECT rate, effective_date
+		FROM interest_benchmark_rates
+		WHERE effective_date <= ?
+		ORDER BY effective_date DESC
+		LIMIT 1
+	`, date).Scan(&schedule.BenchmarkRate, &schedule.EffectiveDate)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := is.DB.Query("SELECT min_balance, spread FROM interest_rate_tiers ORDER BY min_balance")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedule.Tiers = []InterestTier{}
+	for rows.Next() {
+		var tier InterestTier
+		if err := rows.Scan(&tier.MinBalance, &tier.Spread); err != nil {
+			return nil, err
+		}
+		tier.AnnualRate = schedule.BenchmarkRate + tier.Spread
+		if n := len(schedule.Tiers); n > 0 {
+			maxBalance := tier.MinBalance
+			schedule.Tiers[n-1].MaxBalance = &maxBalance
+		}
+		schedule.Tiers = append(schedule.Tiers, tier)
+	}
+
+	return &schedule, rows.Err()
+}
+
+// SetBenchmarkRate sets the benchmark rate from an effective date
+func (is *InterestService) SetBenchmarkRate(rate float64, effectiveDate time.Time) error {
+	if rate < 0 {
+		return fmt.Errorf("benchmark rate cannot be negative")
+	}
+
+	_, err := is.DB.Exec(`
+		INSERT INTO interest_benchmark_rates (effective_date, rate, updated_at)
+		VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE rate = VALUES(rate), updated_at = NOW()
+	`, effectiveDate, rate)
+	return err
+}
+
+// SetTiers replaces the tiers of the schedule
+func (is *InterestService) SetTiers(tiers []InterestTier) error {
+	if err := ValidateInterestTiers(tiers); err != nil {
+		return err
+	}
+
+	tx, err := is.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM interest_rate_tiers"); err != nil {
+		return err
+	}
+	for _, tier := range tiers {
+		_, err := tx.Exec(`
+			INSERT INTO interest_rate_tiers (min_balance, spread, updated_at)
+			VALUES (?, ?, NOW())
+		`, tier.MinBalance, tier.Spread)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// InterestRunResult is the number of accruals or interest charges made by a run
+// over every client, and the clients it failed for
+type InterestRunResult struct {
+	Count    int
+	Failures map[int64]error
+}
+
+// newInterestRunResult creates an empty InterestRunResult
+func newInterestRunResult() *InterestRunResult {
+	return &InterestRunResult{Failures: make(map[int64]error)}
+}
+
+// AccrueInterest records the daily interest on every margin loan for each day
+// from the one after its last accrual through date, or for date alone if it
+// has never accrued. Each day is charged on the loan at the end of that day:
+// the negative of the cash ledger balance of the entries effective by then.
+// Days already accrued are skipped, so it is safe to run repeatedly, and days
+// missed while it was not running are caught up. Nothing accrues on a day
+// without a benchmark rate and tiers. A client that fails is reported in the
+// result's Failures and the others still accrue; the error is reserved for
+// failing to list the loans.
+func (is *InterestService) AccrueInterest(date time.Time) (*InterestRunResult, error) {
+	rows, err := is.DB.Query(`
+		SELECT m.client_id, (SELECT MAX(a.accrual_date) FROM interest_accruals a WHERE a.client_id = m.client_id)
+		FROM margins m
+		ORDER BY m.client_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get margin accounts: %v", err)
+	}
+
+	firstDays := make(map[int64]time.Time)
+	var clientIDs []int64
+	for rows.Next() {
+		var clientID int64
+		var lastAccrual sql.NullTime
+		if err := rows.Scan(&clientID, &lastAccrual); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		firstDays[clientID] = date
+		if lastAccrual.Valid {
+			firstDays[clientID] = lastAccrual.Time.AddDate(0, 0, 1)
+		}
+		clientIDs = append(clientIDs, clientID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	schedules := make(map[time.Time]*InterestSchedule)
+	result := newInterestRunResult()
+	for _, clientID := range clientIDs {
+		first := firstDays[clientID]
+		if first.After(date) {
+			continue
+		}
+		count, err := is.accrueClient(clientID, first, date, schedules)
+		result.Count += count
+		if err != nil {
+			result.Failures[clientID] = err
+		}
+	}
+
+	return result, nil
+}
+
+// accrueClient records a client's daily interest from first through last,
+// looking up the schedule of each day in schedules and adding those it loads.
+// It returns the number of accruals made.
+func (is *InterestService) accrueClient(clientID int64, first, last time.Time, schedules map[time.Time]*InterestSchedule) (int, error) {
+	balances, err := is.dailyLoanBalances(clientID, first, last)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get loan balances: %v", err)
+	}
+
+	var count int
+	for i, balance := range balances {
+		if balance <= 0 {
+			continue
+		}
+		day := first.AddDate(0, 0, i)
+		schedule, ok := schedules[day]
+		if !ok {
+			if schedule, err = is.GetSchedule(day); err != nil {
+				return count, fmt.Errorf("failed to get interest schedule: %v", err)
+			}
+			schedules[day] = schedule
+		}
+		if schedule == nil || len(schedule.Tiers) == 0 {
+			continue
+		}
+
+		a := InterestAccrual{ClientID: clientID, AccrualDate: day, LoanBalance: balance, BenchmarkRate: schedule.BenchmarkRate}
+		a.Interest, a.AnnualRate = schedule.DailyInterest(a.LoanBalance)
+		result, err := is.DB.Exec(`
+			INSERT IGNORE INTO interest_accruals (client_id, accrual_date, loan_balance, benchmark_rate, annual_rate, interest, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, NOW())
+		`, a.ClientID, a.AccrualDate, a.LoanBalance, a.BenchmarkRate, a.AnnualRate, a.Interest)
+		if err != nil {
+			return count, fmt.Errorf("failed to accrue interest for %s: %v", day.Format("2006-01-02"), err)
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return count, err
+		}
+		count += int(inserted)
+	}
+	return count, nil
+}
+
+// dailyLoanBalances returns a client's loan at the end of each day from first
+// through last, from the cash ledger entries effective by then
+func (is *InterestService) dailyLoanBalances(clientID int64, first, last time.Time) ([]Decimal, error) {
+	rows, err := is.DB.Query(`
+		SELECT effective_date, SUM(amount)
+		FROM cash_transactions
+		WHERE client_id = ? AND effective_date <= ?
+		GROUP BY effective_date
+		ORDER BY effective_date
+	`, clientID, last)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []ledgerMovement
+	for rows.Next() {
+		var m ledgerMovement
+		if err := rows.Scan(&m.date, &m.amount); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return loanBalances(movements, first, last), nil
+}
+
+// ledgerMovement is the net cash ledger amount effective on one day
+type ledgerMovement struct {
+	date   time.Time
+	amount Decimal
+}
+
+// loanBalances returns the loan at the end of each day from first through last
+// given the net ledger movements of each day, oldest first
+func loanBalances(movements []ledgerMovement, first, last time.Time) []Decimal {
+	var balances []Decimal
+	var ledger Decimal
+	next := 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		for next < len(movements) && !movements[next].date.After(day) {
+			ledger += movements[next].amount
+			next++
+		}
+		balances = append(balances, -ledger)
+	}
+	return balances
+}
+
+// Capitalize adds the interest accrued before a date that has not been
+// capitalized yet to each client's loan, posting one interest entry per client
+// to the cash ledger. It is run with the first of the month to capitalize the
+// months before. A client that fails is reported in the result's Failures
+// and the others are still charged; Count is the number of clients charged.
+func (is *InterestService) Capitalize(before time.Time) (*InterestRunResult, error) {
+	rows, err := is.DB.Query(`
+		SELECT DISTINCT client_id
+		FROM interest_accruals
+		WHERE cash_transaction_id IS NULL AND accrual_date < ?
+	`, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uncapitalized interest: %v", err)
+	}
+
+	var clientIDs []int64
+	for rows.Next() {
+		var clientID int64
+		if err := rows.Scan(&clientID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		clientIDs = append(clientIDs, clientID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := newInterestRunResult()
+	for _, clientID := range clientIDs {
+		charged, err := is.capitalizeClient(clientID, before)
+		if err != nil {
+			result.Failures[clientID] = err
+			continue
+		}
+		if charged {
+			result.Count++
+		}
+	}
+
+	return result, nil
+}
+
+// capitalizeClient posts a client's uncapitalized interest accrued before a
+// date to their cash ledger and marks the accruals capitalized
+func (is *InterestService) capitalizeClient(clientID int64, before time.Time) (bool, error) {
+	tx, err := is.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var total Decimal
+	var first, last sql.NullTime
+	err = tx.QueryRow(`
+		SELECT SUM(interest), MIN(accrual_date), MAX(accrual_date)
+		FROM interest_accruals
+		WHERE client_id = ? AND cash_transaction_id IS NULL AND accrual_date < ?
+		FOR UPDATE
+	`, clientID, before).Scan(&total, &first, &last)
+	if err != nil {
+		return false, err
+	}
+	if !first.Valid {
+		return false, nil
+	}
+
+	interest := &CashTransaction{
+		ClientID:      clientID,
+		Type:          CashInterest,
+		Amount:        -total,
+		Description:   fmt.Sprintf("Margin interest %s to %s", first.Time.Format("2006-01-02"), last.Time.Format("2006-01-02")),
+		EffectiveDate: before.AddDate(0, 0, -1),
+	}
+	if err := postCash(tx, interest); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE interest_accruals
+		SET cash_transaction_id = ?
+		WHERE client_id = ? AND cash_transaction_id IS NULL AND accrual_date < ?
+	`, interest.ID, clientID, before)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetAccruals retrieves a client's interest accruals in [from, to), oldest first
+func (is *InterestService) GetAccruals(clientID int64, from, to time.Time) ([]InterestAccrual, error) {
+	query := `
+		SELECT id, client_id, accrual_date, loan_balance, benchmark_rate, annual_rate, interest, cash_transaction_id, created_at
+		FROM interest_accruals
+		WHERE client_id = ? AND accrual_date >= ? AND accrual_date < ?
+		ORDER BY accrual_date
+	`
+
+	rows, err := is.DB.Query(query, clientID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accruals := []InterestAccrual{}
+	for rows.Next() {
+		var a InterestAccrual
+		var cashTransactionID sql.NullInt64
+		err := rows.Scan(
+			&a.ID,
+			&a.ClientID,
+			&a.AccrualDate,
+			&a.LoanBalance,
+			&a.BenchmarkRate,
+			&a.AnnualRate,
+			&a.Interest,
+			&cashTransactionID,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if cashTransactionID.Valid {
+			a.CashTransactionID = &cashTransactionID.Int64
+		}
+		accruals = append(accruals, a)
+	}
+
+	return accruals, rows.Err()
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoanBalances(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 5, n, 0, 0, 0, 0, time.UTC) }
+	movements := []ledgerMovement{
+		{date: day(1), amount: NewDecimal(-1000)},
+		{date: day(3), amount: NewDecimal(-500)},
+		{date: day(6), amount: NewDecimal(2000)},
+		{date: day(9), amount: NewDecimal(-700)},
+	}
+
+	tests := []struct {
+		name        string
+		first, last time.Time
+		want        []float64
+	}{
+		{name: "includes movements before the first day", first: day(4), last: day(7), want: []float64{1500, 1500, -500, -500}},
+		{name: "each day takes the movements effective by then", first: day(1), last: day(3), want: []float64{1000, 1000, 1500}},
+		{name: "ignores movements after the last day", first: day(8), last: day(8), want: []float64{-500}},
+		{name: "nothing before the first movement", first: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), last: day(1), want: []float64{0, 1000}},
+		{name: "first after last", first: day(5), last: day(4), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := loanBalances(movements, tt.first, tt.last)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Float64() != tt.want[i] {
+					t.Errorf("day %d balance = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
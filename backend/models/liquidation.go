@@ -0,0 +1,334 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LiquidationPreference orders the positions a liquidation plan reduces
+type LiquidationPreference string
+
+// Liquidation preferences
+const (
+	// LiquidateMostLiquid prefers positions with the most recent price
+	LiquidateMostLiquid LiquidationPreference = "most_liquid"
+	// LiquidateLargestRequirement prefers positions with the largest
+	// maintenance requirement
+	LiquidateLargestRequirement LiquidationPreference = "largest_requirement"
+	// LiquidateAvoidLosses prefers positions that can be closed at a gain
+	LiquidateAvoidLosses LiquidationPreference = "avoid_losses"
+)
+
+// DefaultLiquidationPreferences is used when no preferences are given
+var DefaultLiquidationPreferences = []LiquidationPreference{LiquidateAvoidLosses, LiquidateLargestRequirement, LiquidateMostLiquid}
+
+// ParseLiquidationPreferences validates preference names, in order of
+// priority. No names gives DefaultLiquidationPreferences.
+func ParseLiquidationPreferences(names []string) ([]LiquidationPreference, error) {
+	var prefs []LiquidationPreference
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		switch pref := LiquidationPreference(strings.TrimSpace(name)); pref {
+		case LiquidateMostLiquid, LiquidateLargestRequirement, LiquidateAvoidLosses:
+			prefs = append(prefs, pref)
+		default:
+			return nil, fmt.Errorf("unknown liquidation preference: %s", name)
+		}
+	}
+	if len(prefs) == 0 {
+		return DefaultLiquidationPreferences, nil
+	}
+	return prefs, nil
+}
+
+// LiquidationOrder is a suggested order reducing one position: a sell for a
+// long position and a buy to cover for a short one. RealizedPnL is estimated
+// against the position's cost basis. Price, MarketValue and RealizedPnL are in
+// the symbol's Currency and RequirementReleased in the account's base currency.
+type LiquidationOrder struct {
+	Symbol              string    `json:"symbol"`
+	Side                TradeSide `json:"side"`
+	Quantity            int       `json:"quantity"`
+	Price               Decimal   `json:"price"`
+	Currency            string    `json:"currency"`
+	MarketValue         Decimal   `json:"market_value"`
+	RequirementReleased Decimal   `json:"requirement_released"`
+	RealizedPnL         Decimal   `json:"realized_pnl"`
+}
+
+// LiquidationPlan is a set of orders that cures a client's margin shortfall.
+// Cured is false if liquidating every priced position would not cure it.
+type LiquidationPlan struct {
+	ID           int64                   `json:"id,omitempty"`
+	ClientID     int64                   `json:"client_id"`
+	MarginCallID *int64                  `json:"margin_call_id,omitempty"`
+	Preferences  []LiquidationPreference `json:"preferences"`
+	Shortfall    Decimal                 `json:"shortfall"`
+	Orders       []LiquidationOrder      `json:"orders"`
+	Projected    *MarginStatus           `json:"projected,omitempty"`
+	Cured        bool                    `json:"cured"`
+	CreatedAt    time.Time               `json:"created_at"`
+}
+
+// maxLiquidationSteps bounds the number of times a plan is re-projected
+const maxLiquidationSteps = 100
+
+// liquidationCandidate is a position a plan may reduce
+type liquidationCandidate struct {
+	Position
+	Price       Decimal
+	Currency    string
+	Age         time.Duration
+	Requirement Decimal
+}
+
+// closingSide returns the side of a trade closing the candidate
+func (lc liquidationCandidate) closingSide() TradeSide {
+	if lc.Quantity < 0 {
+		return TradeBuy
+	}
+	return TradeSell
+}
+
+// gain returns the P&L of closing shares of the candidate at its price
+func (lc liquidationCandidate) gain(shares int) Decimal {
+	if lc.Quantity < 0 {
+		return (lc.CostBasis - lc.Price).MulInt(shares)
+	}
+	return (lc.Price - lc.CostBasis).MulInt(shares)
+}
+
+// PlanLiquidation works out the orders that cure a client's margin shortfall,
+// reducing positions in order of prefs. Each step sizes an order from the
+// position's requirement per share and then recalculates the margin status of
+// the projected positions, so that changes to the concentration add-on are
+// taken into account. Orders are valued at the prices the margin status uses.
+func (ms *MarginService) PlanLiquidation(clientID int64, positions []Position, quotes map[string]PriceQuote, prefs []LiquidationPreference) (*LiquidationPlan, error) {
+	margin, err := ms.GetMarginByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if margin == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	status, err := ms.calculateStatus(margin, positions, quotes)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &LiquidationPlan{
+		ClientID:    clientID,
+		Preferences: prefs,
+		Shortfall:   MaxDecimal(0, status.MarginShortfall),
+		Orders:      []LiquidationOrder{},
+		Projected:   status,
+		Cured:       !status.MarginCall,
+		CreatedAt:   time.Now(),
+	}
+	if plan.Cured {
+		return plan, nil
+	}
+
+	candidates := liquidationCandidates(positions, quotes, status, ms.Pricing)
+	sortLiquidationCandidates(candidates, prefs)
+	fxService := &FXService{DB: ms.DB}
+	rates, err := fxService.GetRates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FX rates: %v", err)
+	}
+
+	sold := make(map[string]int)
+	next := 0
+	for step := 0; step < maxLiquidationSteps && next < len(candidates); step++ {
+		candidate := &candidates[next]
+		perShare := candidate.Requirement.Div(float64(abs(candidate.Quantity)))
+		shares := min(abs(candidate.Quantity)-sold[candidate.Symbol], int(math.Ceil(plan.Projected.MarginShortfall.Ratio(perShare))))
+		sold[candidate.Symbol] += shares
+		if sold[candidate.Symbol] == abs(candidate.Quantity) {
+			next++
+		}
+
+		projection, err := ProjectTrades(positions, liquidationTrades(candidates, sold), quotes, rates, margin.BaseCurrency)
+		if err != nil {
+			return nil, err
+		}
+		projectedMargin := *margin
+		projectedMargin.LoanAmount += projection.LoanChange
+		plan.Projected, err = ms.calculateStatus(&projectedMargin, projection.Positions, projection.Quotes)
+		if err != nil {
+			return nil, err
+		}
+		if !plan.Projected.MarginCall {
+			plan.Cured = true
+			break
+		}
+	}
+
+	for _, candidate := range candidates {
+		shares := sold[candidate.Symbol]
+		if shares == 0 {
+			continue
+		}
+		plan.Orders = append(plan.Orders, LiquidationOrder{
+			Symbol:              candidate.Symbol,
+			Side:                candidate.closingSide(),
+			Quantity:            shares,
+			Price:               candidate.Price,
+			Currency:            candidate.Currency,
+			MarketValue:         candidate.Price.MulInt(shares),
+			RequirementReleased: candidate.Requirement.MulInt(shares).Div(float64(abs(candidate.Quantity))),
+			RealizedPnL:         candidate.gain(shares),
+		})
+	}
+	return plan, nil
+}
+
+// liquidationCandidates returns the priced positions with a requirement, since
+// closing the others would not reduce the shortfall
+func liquidationCandidates(positions []Position, quotes map[string]PriceQuote, status *MarginStatus, pricing PricingPolicy) []liquidationCandidate {
+	requirements := make(map[string]Decimal)
+	for _, r := range status.Requirements {
+		requirements[r.Symbol] = r.Requirement
+	}
+
+	var candidates []liquidationCandidate
+	for _, position := range positions {
+		price, positionPricing, ok := pricing.priceFor(position, quotes)
+		if !ok || requirements[position.Symbol] <= 0 {
+			continue
+		}
+		candidates = append(candidates, liquidationCandidate{
+			Position:    position,
+			Price:       price,
+			Currency:    positionPricing.Currency,
+			Age:         quotes[position.Symbol].Age,
+			Requirement: requirements[position.Symbol],
+		})
+	}
+	return candidates
+}
+
+// sortLiquidationCandidates orders candidates by each preference in turn,
+// breaking ties by symbol
+func sortLiquidationCandidates(candidates []liquidationCandidate, prefs []LiquidationPreference) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		for _, pref := range prefs {
+			switch pref {
+			case LiquidateMostLiquid:
+				if a.Age != b.Age {
+					return a.Age < b.Age
+				}
+			case LiquidateLargestRequirement:
+				if a.Requirement != b.Requirement {
+					return a.Requirement > b.Requirement
+				}
+			case LiquidateAvoidLosses:
+				if aLoss, bLoss := a.gain(1) < 0, b.gain(1) < 0; aLoss != bLoss {
+					return !aLoss
+				}
+			}
+		}
+		return a.Symbol < b.Symbol
+	})
+}
+
+// liquidationTrades returns the trades closing the shares sold of each candidate
+func liquidationTrades(candidates []liquidationCandidate, sold map[string]int) []HypotheticalTrade {
+	var trades []HypotheticalTrade
+	for _, candidate := range candidates {
+		shares := sold[candidate.Symbol]
+		if shares == 0 {
+			continue
+		}
+		trades = append(trades, HypotheticalTrade{Symbol: candidate.Symbol, Side: candidate.closingSide(), Quantity: shares, Price: candidate.Price})
+	}
+	return trades
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// LiquidationPlanService stores the plans generated when margin calls escalate
+type LiquidationPlanService struct {
+	DB *sql.DB
+}
+
+// SavePlan stores a plan
+func (lps *LiquidationPlanService) SavePlan(plan *LiquidationPlan) error {
+	orders, err := json.Marshal(plan.Orders)
+	if err != nil {
+		return err
+	}
+	prefs := make([]string, len(plan.Preferences))
+	for i, pref := range plan.Preferences {
+		prefs[i] = string(pref)
+	}
+
+	result, err := lps.DB.Exec(`
+		INSERT INTO liquidation_plans (client_id, margin_call_id, preferences, shortfall, orders, cured, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, plan.ClientID, plan.MarginCallID, strings.Join(prefs, ","), plan.Shortfall, orders, plan.Cured, plan.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	plan.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetLatestPlan retrieves the most recent plan stored for a margin call
+func (lps *LiquidationPlanService) GetLatestPlan(marginCallID int64) (*LiquidationPlan, error) {
+	query := `
+		SELECT id, client_id, margin_call_id, preferences, shortfall, orders, cured, created_at
+		FROM liquidation_plans
+		WHERE margin_call_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+
+	var plan LiquidationPlan
+	var callID sql.NullInt64
+	var prefs string
+	var orders []byte
+	err := lps.DB.QueryRow(query, marginCallID).Scan(
+		&plan.ID,
+		&plan.ClientID,
+		&callID,
+		&prefs,
+		&plan.Shortfall,
+		&orders,
+		&plan.Cured,
+		&plan.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if callID.Valid {
+		plan.MarginCallID = &callID.Int64
+	}
+	if plan.Preferences, err = ParseLiquidationPreferences(strings.Split(prefs, ",")); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(orders, &plan.Orders); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
@@ -0,0 +1,292 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RuleType identifies what a maintenance rule matches on
+type RuleType string
+
+// Maintenance rule types
+const (
+	// RuleSymbol matches positions in one symbol
+	RuleSymbol RuleType = "symbol"
+	// RuleSector matches positions in instruments of one sector
+	RuleSector RuleType = "sector"
+	// RuleAssetClass matches positions in instruments of one asset class
+	RuleAssetClass RuleType = "asset_class"
+	// RulePriceBand matches positions priced in [MinPrice, MaxPrice)
+	RulePriceBand RuleType = "price_band"
+	// RuleConcentration matches positions making up [MinConcentration,
+	// MaxConcentration) of the portfolio's gross market value
+	RuleConcentration RuleType = "concentration"
+)
+
+// MaxRuleRate is the highest maintenance rate a rule may set
+const MaxRuleRate = 3.0
+
+// MaintenanceRule sets a maintenance rate for the positions it matches. Side
+// restricts the rule to long or short positions; empty matches both. Open-ended
+// bounds are nil.
+type MaintenanceRule struct {
+	ID               int64      `json:"id"`
+	Name             string     `json:"name"`
+	Type             RuleType   `json:"type"`
+	Side             LotSide    `json:"side,omitempty"`
+	Symbol           string     `json:"symbol,omitempty"`
+	Sector           string     `json:"sector,omitempty"`
+	AssetClass       AssetClass `json:"asset_class,omitempty"`
+	MinPrice         *float64   `json:"min_price,omitempty"`
+	MaxPrice         *float64   `json:"max_price,omitempty"`
+	MinConcentration *float64   `json:"min_concentration,omitempty"`
+	MaxConcentration *float64   `json:"max_concentration,omitempty"`
+	Rate             float64    `json:"rate"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Validate checks that the rule is well formed
+func (r *MaintenanceRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.Side != "" && r.Side != LotLong && r.Side != LotShort {
+		return fmt.Errorf("side must be long or short")
+	}
+	if r.Rate <= 0 || r.Rate > MaxRuleRate {
+		return fmt.Errorf("rate must be in (0, %g]", MaxRuleRate)
+	}
+
+	switch r.Type {
+	case RuleSymbol:
+		if r.Symbol == "" {
+			return fmt.Errorf("symbol rule requires a symbol")
+		}
+	case RuleSector:
+		if r.Sector == "" {
+			return fmt.Errorf("sector rule requires a sector")
+		}
+	case RuleAssetClass:
+		if r.AssetClass == "" {
+			return fmt.Errorf("asset class rule requires an asset class")
+		}
+	case RulePriceBand:
+		if r.MinPrice == nil && r.MaxPrice == nil {
+			return fmt.Errorf("price band rule requires a min or max price")
+		}
+		if r.MinPrice != nil && r.MaxPrice != nil && *r.MinPrice >= *r.MaxPrice {
+			return fmt.Errorf("min price must be below max price")
+		}
+	case RuleConcentration:
+		if r.MinConcentration == nil && r.MaxConcentration == nil {
+			return fmt.Errorf("concentration rule requires a min or max concentration")
+		}
+		for _, bound := range []*float64{r.MinConcentration, r.MaxConcentration} {
+			if bound != nil && (*bound < 0 || *bound > 1) {
+				return fmt.Errorf("concentration bounds must be in [0, 1]")
+			}
+		}
+		if r.MinConcentration != nil && r.MaxConcentration != nil && *r.MinConcentration >= *r.MaxConcentration {
+			return fmt.Errorf("min concentration must be below max concentration")
+		}
+	default:
+		return fmt.Errorf("unknown rule type: %s", r.Type)
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to a position in an instrument
+func (r *MaintenanceRule) Matches(instrument Instrument, side LotSide, price, concentration float64) bool {
+	if r.Side != "" && r.Side != side {
+		return false
+	}
+
+	switch r.Type {
+	case RuleSymbol:
+		return r.Symbol == instrument.Symbol
+	case RuleSector:
+		return r.Sector == instrument.Sector
+	case RuleAssetClass:
+		return r.AssetClass == instrument.AssetClass
+	case RulePriceBand:
+		return inBand(price, r.MinPrice, r.MaxPrice)
+	case RuleConcentration:
+		return inBand(concentration, r.MinConcentration, r.MaxConcentration)
+	default:
+		return false
+	}
+}
+
+// inBand reports whether v is in [min, max), treating nil bounds as open
+func inBand(v float64, min, max *float64) bool {
+	if min != nil && v < *min {
+		return false
+	}
+	if max != nil && v >= *max {
+		return false
+	}
+	return true
+}
+
+// BindingRule returns the matching rule with the highest rate, or nil if no
+// rule matches
+func BindingRule(rules []MaintenanceRule, instrument Instrument, side LotSide, price, concentration float64) *MaintenanceRule {
+	var binding *MaintenanceRule
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(instrument, side, price, concentration) {
+			continue
+		}
+		if binding == nil || rule.Rate > binding.Rate {
+			binding = rule
+		}
+	}
+	return binding
+}
+
+// MaintenanceRuleService handles database operations for maintenance rules
+type MaintenanceRuleService struct {
+	DB *sql.DB
+}
+
+const maintenanceRuleColumns = `id, name, rule_type, side, symbol, sector, asset_class,
+	min_price, max_price, min_concentration, max_concentration, rate, created_at, updated_at`
+
+// scanMaintenanceRule scans a rule selected with maintenanceRuleColumns
+func scanMaintenanceRule(row rowScanner) (*MaintenanceRule, error) {
+	var r MaintenanceRule
+	var side, symbol, sector, assetClass sql.NullString
+	var minPrice, maxPrice, minConcentration, maxConcentration sql.NullFloat64
+	err := row.Scan(
+		&r.ID,
+		&r.Name,
+		&r.Type,
+		&side,
+		&symbol,
+		&sector,
+		&assetClass,
+		&minPrice,
+		&maxPrice,
+		&minConcentration,
+		&maxConcentration,
+		&r.Rate,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.Side = LotSide(side.String)
+	r.Symbol = symbol.String
+	r.Sector = sector.String
+	r.AssetClass = AssetClass(assetClass.String)
+	r.MinPrice = nullFloatPtr(minPrice)
+	r.MaxPrice = nullFloatPtr(maxPrice)
+	r.MinConcentration = nullFloatPtr(minConcentration)
+	r.MaxConcentration = nullFloatPtr(maxConcentration)
+	return &r, nil
+}
+
+// nullFloatPtr converts a nullable float to a pointer
+func nullFloatPtr(nf sql.NullFloat64) *float64 {
+	if !nf.Valid {
+		return nil
+	}
+	f := nf.Float64
+	return &f
+}
+
+// nullString converts an empty string to NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// ListRules retrieves all maintenance rules
+func (mrs *MaintenanceRuleService) ListRules() ([]MaintenanceRule, error) {
+	rows, err := mrs.DB.Query("SELECT " + maintenanceRuleColumns + " FROM maintenance_rules ORDER BY rule_type, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []MaintenanceRule{}
+	for rows.Next() {
+		rule, err := scanMaintenanceRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// GetRule retrieves a maintenance rule by ID
+func (mrs *MaintenanceRuleService) GetRule(id int64) (*MaintenanceRule, error) {
+	row := mrs.DB.QueryRow("SELECT "+maintenanceRuleColumns+" FROM maintenance_rules WHERE id = ?", id)
+	rule, err := scanMaintenanceRule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// CreateRule creates a new maintenance rule
+func (mrs *MaintenanceRuleService) CreateRule(r *MaintenanceRule) error {
+	result, err := mrs.DB.Exec(`
+		INSERT INTO maintenance_rules (name, rule_type, side, symbol, sector, asset_class, min_price, max_price,
+			min_concentration, max_concentration, rate, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+	`, r.Name, r.Type, nullString(string(r.Side)), nullString(r.Symbol), nullString(r.Sector), nullString(string(r.AssetClass)), r.MinPrice, r.MaxPrice,
+		r.MinConcentration, r.MaxConcentration, r.Rate)
+	if err != nil {
+		return err
+	}
+
+	r.ID, err = result.LastInsertId()
+	return err
+}
+
+// UpdateRule replaces an existing maintenance rule. It returns false if there
+// is no rule with the ID.
+func (mrs *MaintenanceRuleService) UpdateRule(r *MaintenanceRule) (bool, error) {
+	result, err := mrs.DB.Exec(`
+		UPDATE maintenance_rules
+		SET name = ?, rule_type = ?, side = ?, symbol = ?, sector = ?, asset_class = ?, min_price = ?, max_price = ?,
+			min_concentration = ?, max_concentration = ?, rate = ?, updated_at = NOW()
+		WHERE id = ?
+	`, r.Name, r.Type, nullString(string(r.Side)), nullString(r.Symbol), nullString(r.Sector), nullString(string(r.AssetClass)), r.MinPrice, r.MaxPrice,
+		r.MinConcentration, r.MaxConcentration, r.Rate, r.ID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	// MySQL reports no rows affected when nothing changed
+	existing, err := mrs.GetRule(r.ID)
+	return existing != nil, err
+}
+
+// DeleteRule deletes a maintenance rule. It returns false if there is no rule
+// with the ID.
+func (mrs *MaintenanceRuleService) DeleteRule(id int64) (bool, error) {
+	result, err := mrs.DB.Exec("DELETE FROM maintenance_rules WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -2,37 +2,226 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/minirisk/config"
 )
 
-// Margin represents margin-related data for a client
+// Margin represents margin-related data for a client. SMA is the special
+// memorandum account: a running credit of equity above the initial requirement
+// that market gains raise, trades opening positions use and trades closing
+// them release. It is maintained by the system and not set through
+// UpdateMargin. Amounts are in the account's BaseCurrency, which is set when
+// the account is opened.
 type Margin struct {
-	ID                int64     `json:"id"`
-	ClientID          int64     `json:"client_id"`
-	LoanAmount        float64   `json:"loan_amount"`
-	InitialMargin     float64   `json:"initial_margin"`
-	MaintenanceMargin float64   `json:"maintenance_margin"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                     int64     `json:"id"`
+	ClientID               int64     `json:"client_id"`
+	BaseCurrency           string    `json:"base_currency"`
+	LoanAmount             Decimal   `json:"loan_amount"`
+	InitialMargin          float64   `json:"initial_margin"`
+	MaintenanceMargin      float64   `json:"maintenance_margin"`
+	ShortMaintenanceMargin float64   `json:"short_maintenance_margin"`
+	SMA                    Decimal   `json:"sma"`
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
 }
 
-// MarginStatus represents the current margin status for a client
+// Short maintenance requirements. A short position must carry the greater of
+// the account's short maintenance rate on its market value and
+// ShortMinPerShare per share; below ShortLowPriceThreshold, the greater of its
+// full market value and ShortLowPriceMinPerShare per share.
+const (
+	DefaultShortMaintenanceMargin = 0.30
+	ShortMinPerShare              = 5.00
+	ShortLowPriceThreshold        = 5.00
+	ShortLowPriceMinPerShare      = 2.50
+)
+
+// ShortRequirement returns the maintenance requirement on a short position of
+// shares at price
+func ShortRequirement(shares int, price Decimal, rate float64) Decimal {
+	marketValue := price.MulInt(shares)
+	if price < NewDecimal(ShortLowPriceThreshold) {
+		return MaxDecimal(marketValue, NewDecimal(ShortLowPriceMinPerShare).MulInt(shares))
+	}
+	return MaxDecimal(marketValue.Mul(rate), NewDecimal(ShortMinPerShare).MulInt(shares))
+}
+
+// MarginStatus represents the current margin status for a client. Short
+// positions count against PortfolioValue at their market value, and the
+// proceeds of the short sales are credited to NetEquity. RequiredMargin and
+// InitialRequirement include the ConcentrationAddOn charged on the
+// Concentrations over their threshold. SMA is the account's stored SMA raised
+// to the current InitialExcess. BuyingPower is the market value the SMA can buy
+// at the initial margin rate without the purchase taking the account below
+// maintenance, and AvailableToWithdraw is the SMA the account can pay out
+// while staying above maintenance. Amounts are in the account's BaseCurrency.
 type MarginStatus struct {
-	PortfolioValue  float64 `json:"portfolio_value"`
-	NetEquity       float64 `json:"net_equity"`
-	MarginShortfall float64 `json:"margin_shortfall"`
-	MarginCall      bool    `json:"margin_call"`
+	BaseCurrency        string                `json:"base_currency"`
+	PortfolioValue      Decimal               `json:"portfolio_value"`
+	LongMarketValue     Decimal               `json:"long_market_value"`
+	ShortMarketValue    Decimal               `json:"short_market_value"`
+	ShortCredit         Decimal               `json:"short_credit"`
+	LoanAmount          Decimal               `json:"loan_amount"`
+	RequiredMargin      Decimal               `json:"required_margin"`
+	InitialRequirement  Decimal               `json:"initial_requirement"`
+	ConcentrationAddOn  Decimal               `json:"concentration_add_on"`
+	NetEquity           Decimal               `json:"net_equity"`
+	InitialExcess       Decimal               `json:"initial_excess"`
+	MaintenanceExcess   Decimal               `json:"maintenance_excess"`
+	SMA                 Decimal               `json:"sma"`
+	BuyingPower         Decimal               `json:"buying_power"`
+	AvailableToWithdraw Decimal               `json:"available_to_withdraw"`
+	MarginShortfall     Decimal               `json:"margin_shortfall"`
+	MarginCall          bool                  `json:"margin_call"`
+	Requirements        []PositionRequirement `json:"requirements"`
+	Concentrations      []Concentration       `json:"concentrations"`
+	StalePositions      []PositionPricing     `json:"stale_positions"`
+	UnpricedPositions   []PositionPricing     `json:"unpriced_positions"`
+}
+
+// RequirementBasis is what determined a position's maintenance requirement
+type RequirementBasis string
+
+// Requirement bases
+const (
+	// RequirementAccountRate is the account's long or short maintenance rate
+	RequirementAccountRate RequirementBasis = "account_rate"
+	// RequirementRule is a maintenance rule with a higher rate than the account's
+	RequirementRule RequirementBasis = "rule"
+	// RequirementNonMarginable means the instrument is not marginable and the
+	// requirement is its full market value
+	RequirementNonMarginable RequirementBasis = "non_marginable"
+	// RequirementShortMinimum is the per-share minimum on a short position
+	RequirementShortMinimum RequirementBasis = "short_minimum"
+)
+
+// PositionRequirement is the maintenance requirement on one position. Market
+// value is absolute, and concentration is its share of the gross market value
+// of the portfolio. The initial requirement is the greater of the maintenance
+// requirement and the account's initial margin rate on the market value.
+// Price is in the account's base currency, converted from the symbol's Currency
+// at FXRate.
+type PositionRequirement struct {
+	PositionID         int64            `json:"position_id"`
+	Symbol             string           `json:"symbol"`
+	Quantity           int              `json:"quantity"`
+	Price              Decimal          `json:"price"`
+	Currency           string           `json:"currency"`
+	FXRate             float64          `json:"fx_rate"`
+	AssetClass         AssetClass       `json:"asset_class,omitempty"`
+	Sector             string           `json:"sector,omitempty"`
+	MarketValue        Decimal          `json:"market_value"`
+	Concentration      float64          `json:"concentration"`
+	Rate               float64          `json:"rate"`
+	Requirement        Decimal          `json:"requirement"`
+	InitialRequirement Decimal          `json:"initial_requirement"`
+	Basis              RequirementBasis `json:"basis"`
+	RuleID             *int64           `json:"rule_id,omitempty"`
+	RuleName           string           `json:"rule_name,omitempty"`
+	RuleType           RuleType         `json:"rule_type,omitempty"`
+}
+
+// PositionPricing describes how a position was priced in a margin calculation.
+// Price is in the symbol's Currency, and FXRate converts it into the account's
+// base currency, less FXHaircut.
+type PositionPricing struct {
+	PositionID      int64        `json:"position_id"`
+	Symbol          string       `json:"symbol"`
+	Quantity        int          `json:"quantity"`
+	Price           Decimal      `json:"price"`
+	Currency        string       `json:"currency,omitempty"`
+	PriceAgeSeconds int64        `json:"price_age_seconds"`
+	Quality         PriceQuality `json:"quality"`
+	Haircut         float64      `json:"haircut"`
+	FXRate          float64      `json:"fx_rate,omitempty"`
+	FXAgeSeconds    int64        `json:"fx_age_seconds,omitempty"`
+	FXHaircut       float64      `json:"fx_haircut,omitempty"`
+}
+
+// StalePricePolicy determines how positions with stale prices are valued
+type StalePricePolicy string
+
+// Stale price policies
+const (
+	// StalePriceUseLast values the position at the last known price
+	StalePriceUseLast StalePricePolicy = "last_price"
+	// StalePriceHaircut values the position at the last known price less a haircut
+	StalePriceHaircut StalePricePolicy = "haircut"
+	// StalePriceFail refuses to calculate margin status
+	StalePriceFail StalePricePolicy = "fail"
+)
+
+// DefaultMaxPriceAge is the age beyond which a price is considered stale
+const DefaultMaxPriceAge = 5 * time.Minute
+
+// PricingPolicy configures how margin calculations treat stale and missing prices.
+// The zero value uses DefaultMaxPriceAge and values stale positions at the last price.
+// FXHaircut is charged on positions quoted in a currency other than the account's.
+type PricingPolicy struct {
+	MaxAge      time.Duration
+	StalePolicy StalePricePolicy
+	Haircut     float64
+	FXHaircut   float64
+}
+
+// NewPricingPolicy builds a PricingPolicy from the pricing configuration
+func NewPricingPolicy(cfg config.PricingConfig) (PricingPolicy, error) {
+	policy, err := ParseStalePricePolicy(cfg.StalePricePolicy)
+	if err != nil {
+		return PricingPolicy{}, err
+	}
+	return PricingPolicy{
+		MaxAge:      cfg.MaxPriceAge,
+		StalePolicy: policy,
+		Haircut:     cfg.StalePriceHaircut,
+		FXHaircut:   cfg.FXHaircut,
+	}, nil
+}
+
+// MaxPriceAge returns the configured max price age or the default
+func (pp PricingPolicy) MaxPriceAge() time.Duration {
+	if pp.MaxAge <= 0 {
+		return DefaultMaxPriceAge
+	}
+	return pp.MaxAge
+}
+
+// ParseStalePricePolicy validates a stale price policy string
+func ParseStalePricePolicy(s string) (StalePricePolicy, error) {
+	switch StalePricePolicy(s) {
+	case "":
+		return StalePriceUseLast, nil
+	case StalePriceUseLast, StalePriceHaircut, StalePriceFail:
+		return StalePricePolicy(s), nil
+	default:
+		return "", fmt.Errorf("unknown stale price policy: %s", s)
+	}
+}
+
+// StalePriceError is returned when the pricing policy refuses to value
+// positions whose prices are stale or missing
+type StalePriceError struct {
+	Symbols []string
+}
+
+func (e *StalePriceError) Error() string {
+	return fmt.Sprintf("stale or missing prices for: %s", strings.Join(e.Symbols, ", "))
 }
 
 // MarginService handles database operations for margin data
 type MarginService struct {
-	DB *sql.DB
+	DB            *sql.DB
+	Pricing       PricingPolicy
+	Concentration ConcentrationPolicy
 }
 
 // GetMarginByClientID retrieves margin data for a specific client
 func (ms *MarginService) GetMarginByClientID(clientID int64) (*Margin, error) {
 	query := `
-		SELECT id, client_id, loan_amount, initial_margin, maintenance_margin, created_at, updated_at
+		SELECT id, client_id, base_currency, loan_amount, initial_margin, maintenance_margin, short_maintenance_margin, sma, created_at, updated_at
 		FROM margins
 		WHERE client_id = ?
 	`
@@ -41,9 +230,12 @@ func (ms *MarginService) GetMarginByClientID(clientID int64) (*Margin, error) {
 	err := ms.DB.QueryRow(query, clientID).Scan(
 		&m.ID,
 		&m.ClientID,
+		&m.BaseCurrency,
 		&m.LoanAmount,
 		&m.InitialMargin,
 		&m.MaintenanceMargin,
+		&m.ShortMaintenanceMargin,
+		&m.SMA,
 		&m.CreatedAt,
 		&m.UpdatedAt,
 	)
@@ -57,24 +249,91 @@ func (ms *MarginService) GetMarginByClientID(clientID int64) (*Margin, error) {
 	return &m, nil
 }
 
-// UpdateMargin updates margin data for a client
+// lockMargin loads and locks a client's margin account, returning nil if there
+// is none
+func lockMargin(tx *sql.Tx, clientID int64) (*Margin, error) {
+	query := `
+		SELECT id, client_id, base_currency, loan_amount, initial_margin, maintenance_margin, short_maintenance_margin, sma, created_at, updated_at
+		FROM margins
+		WHERE client_id = ?
+		FOR UPDATE
+	`
+
+	var m Margin
+	err := tx.QueryRow(query, clientID).Scan(
+		&m.ID,
+		&m.ClientID,
+		&m.BaseCurrency,
+		&m.LoanAmount,
+		&m.InitialMargin,
+		&m.MaintenanceMargin,
+		&m.ShortMaintenanceMargin,
+		&m.SMA,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// GetClientIDs retrieves the IDs of all clients with a margin account
+func (ms *MarginService) GetClientIDs() ([]int64, error) {
+	rows, err := ms.DB.Query("SELECT client_id FROM margins ORDER BY client_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clientIDs []int64
+	for rows.Next() {
+		var clientID int64
+		if err := rows.Scan(&clientID); err != nil {
+			return nil, err
+		}
+		clientIDs = append(clientIDs, clientID)
+	}
+
+	return clientIDs, rows.Err()
+}
+
+// UpdateMargin opens a client's margin account or updates its rates. The loan
+// amount is not changed: it is derived from the cash ledger and only moves
+// with the entries posted to it. A zero short maintenance margin is stored as
+// DefaultShortMaintenanceMargin. The base currency is only set when the account
+// is opened, to DefaultCurrency if empty.
 func (ms *MarginService) UpdateMargin(m *Margin) error {
+	if m.ShortMaintenanceMargin == 0 {
+		m.ShortMaintenanceMargin = DefaultShortMaintenanceMargin
+	}
+	if m.BaseCurrency == "" {
+		m.BaseCurrency = DefaultCurrency
+	}
+
 	query := `
-		INSERT INTO margins (client_id, loan_amount, initial_margin, maintenance_margin, created_at, updated_at)
-		VALUES (?, ?, ?, ?, NOW(), NOW())
+		INSERT INTO margins (client_id, base_currency, loan_amount, initial_margin, maintenance_margin, short_maintenance_margin, created_at, updated_at)
+		VALUES (?, ?, 0, ?, ?, ?, NOW(), NOW())
 		ON DUPLICATE KEY UPDATE
-		loan_amount = VALUES(loan_amount),
 		initial_margin = VALUES(initial_margin),
 		maintenance_margin = VALUES(maintenance_margin),
+		short_maintenance_margin = VALUES(short_maintenance_margin),
 		updated_at = VALUES(updated_at)
 	`
-
-	_, err := ms.DB.Exec(query, m.ClientID, m.LoanAmount, m.InitialMargin, m.MaintenanceMargin)
+	_, err := ms.DB.Exec(query, m.ClientID, m.BaseCurrency, m.InitialMargin, m.MaintenanceMargin, m.ShortMaintenanceMargin)
 	return err
 }
 
-// CalculateMarginStatus calculates the current margin status for a client
-func (ms *MarginService) CalculateMarginStatus(clientID int64, positions []Position, marketPrices map[string]float64) (*MarginStatus, error) {
+// CalculateMarginStatus calculates the current margin status for a client. Each
+// position's maintenance requirement is the higher of the account's rate and
+// the highest matching maintenance rule, with the short sale minimums applied
+// to short positions. The concentration add-on is charged on top. Positions
+// are converted into the account's base currency at the latest FX rates.
+func (ms *MarginService) CalculateMarginStatus(clientID int64, positions []Position, quotes map[string]PriceQuote) (*MarginStatus, error) {
 	margin, err := ms.GetMarginByClientID(clientID)
 	if err != nil {
 		return nil, err
@@ -82,29 +341,298 @@ func (ms *MarginService) CalculateMarginStatus(clientID int64, positions []Posit
 	if margin == nil {
 		return nil, sql.ErrNoRows
 	}
+	return ms.calculateStatus(margin, positions, quotes)
+}
 
-	// Calculate portfolio value
-	var portfolioValue float64
+// calculateStatus calculates the margin status of positions held in a margin
+// account
+func (ms *MarginService) calculateStatus(margin *Margin, positions []Position, quotes map[string]PriceQuote) (*MarginStatus, error) {
+	ruleService := &MaintenanceRuleService{DB: ms.DB}
+	rules, err := ruleService.ListRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance rules: %v", err)
+	}
+	fxService := &FXService{DB: ms.DB}
+	rates, err := fxService.GetRates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FX rates: %v", err)
+	}
+
+	status := &MarginStatus{
+		BaseCurrency:      margin.BaseCurrency,
+		Requirements:      []PositionRequirement{},
+		Concentrations:    []Concentration{},
+		StalePositions:    []PositionPricing{},
+		UnpricedPositions: []PositionPricing{},
+	}
+
+	// Calculate long and short market values, tracking positions without a fresh price
+	var priced []pricedPosition
+	var longValue, shortValue, shortCredit Decimal
+	var failedSymbols []string
 	for _, position := range positions {
-		if price, ok := marketPrices[position.Symbol]; ok {
-			portfolioValue += float64(position.Quantity) * price
+		price, pricing, ok := ms.Pricing.priceIn(margin.BaseCurrency, position, quotes, rates)
+		if !ok {
+			status.UnpricedPositions = append(status.UnpricedPositions, pricing)
+			failedSymbols = append(failedSymbols, position.Symbol)
+			continue
+		}
+		if pricing.Quality == PriceQualityStale {
+			status.StalePositions = append(status.StalePositions, pricing)
+			if ms.Pricing.StalePolicy == StalePriceFail {
+				failedSymbols = append(failedSymbols, position.Symbol)
+			}
+		}
+		priced = append(priced, pricedPosition{Position: position, Price: price, Currency: pricing.Currency, FXRate: pricing.FXRate})
+		if position.Quantity < 0 {
+			shortValue += price.MulInt(-position.Quantity)
+			shortCredit += position.CostBasis.Mul(pricing.FXRate).MulInt(-position.Quantity)
+			continue
+		}
+		longValue += price.MulInt(position.Quantity)
+	}
+
+	if ms.Pricing.StalePolicy == StalePriceFail && len(failedSymbols) > 0 {
+		return nil, &StalePriceError{Symbols: failedSymbols}
+	}
+
+	// Calculate the requirement on each position. Symbols missing from the
+	// instrument master are treated as unclassified and marginable.
+	var symbols []string
+	for _, p := range priced {
+		symbols = append(symbols, p.Symbol)
+	}
+	instrumentService := &InstrumentService{DB: ms.DB}
+	instruments, err := instrumentService.GetInstruments(symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instruments: %v", err)
+	}
+
+	var requiredMargin, initialRequirement Decimal
+	values := make(map[string]float64)
+	ruleCharged := make(map[string]bool)
+	grossValue := longValue + shortValue
+	for _, p := range priced {
+		instrument, ok := instruments[p.Symbol]
+		if !ok {
+			instrument = Instrument{Symbol: p.Symbol, Marginable: true}
+		}
+		requirement := p.requirement(margin, rules, instrument, grossValue)
+		requiredMargin += requirement.Requirement
+		initialRequirement += requirement.InitialRequirement
+		status.Requirements = append(status.Requirements, requirement)
+		values[p.Symbol] += requirement.MarketValue.Float64()
+		if requirement.Basis == RequirementRule && requirement.RuleType == RuleConcentration {
+			ruleCharged[p.Symbol] = true
 		}
 	}
 
+	// Charge the add-on on symbols and sectors over their concentration
+	// threshold. A symbol whose requirement was set by a concentration rule has
+	// already been charged for its concentration, so it is listed without an
+	// add-on.
+	for _, c := range ms.Concentration.Concentrations(values, instrumentSectors(instruments)) {
+		if !c.Exceeds() {
+			continue
+		}
+		if c.Type == ConcentrationSymbol && ruleCharged[c.Name] {
+			c.AddOn = 0
+			c.CoveredByRule = true
+		}
+		status.ConcentrationAddOn += NewDecimal(c.AddOn)
+		status.Concentrations = append(status.Concentrations, c)
+	}
+	requiredMargin += status.ConcentrationAddOn
+	initialRequirement += status.ConcentrationAddOn
+
 	// Calculate net equity
-	netEquity := portfolioValue - margin.LoanAmount
+	portfolioValue := longValue - shortValue
+	netEquity := portfolioValue + shortCredit - margin.LoanAmount
 
 	// Calculate margin shortfall
-	requiredMargin := portfolioValue * margin.MaintenanceMargin
 	marginShortfall := requiredMargin - netEquity
 
 	// Determine if margin call is needed
 	marginCall := marginShortfall > 0
 
-	return &MarginStatus{
-		PortfolioValue:  portfolioValue,
-		NetEquity:       netEquity,
-		MarginShortfall: marginShortfall,
-		MarginCall:      marginCall,
-	}, nil
+	status.PortfolioValue = portfolioValue
+	status.LongMarketValue = longValue
+	status.ShortMarketValue = shortValue
+	status.ShortCredit = shortCredit
+	status.LoanAmount = margin.LoanAmount
+	status.RequiredMargin = requiredMargin
+	status.InitialRequirement = initialRequirement
+	status.NetEquity = netEquity
+	status.MarginShortfall = marginShortfall
+	status.MarginCall = marginCall
+
+	// Work out what the client can still trade or withdraw
+	status.InitialExcess = netEquity - initialRequirement
+	status.MaintenanceExcess = netEquity - requiredMargin
+	status.SMA = MaxDecimal(margin.SMA, status.InitialExcess)
+	status.BuyingPower = buyingPower(margin, status)
+	status.AvailableToWithdraw = MaxDecimal(0, MinDecimal(status.SMA, status.MaintenanceExcess))
+	return status, nil
+}
+
+// buyingPower returns the market value of securities a client can buy: the SMA
+// at the initial margin rate, capped at the maintenance excess at the
+// maintenance rate
+func buyingPower(margin *Margin, status *MarginStatus) Decimal {
+	power := status.SMA
+	if margin.InitialMargin > 0 {
+		power = status.SMA.Div(margin.InitialMargin)
+	}
+	if margin.MaintenanceMargin > 0 {
+		power = MinDecimal(power, status.MaintenanceExcess.Div(margin.MaintenanceMargin))
+	}
+	return MaxDecimal(0, power)
+}
+
+// RaiseSMA raises a client's stored SMA to sma if it is higher, so that market
+// gains are kept as credit when prices later fall
+func (ms *MarginService) RaiseSMA(clientID int64, sma Decimal) error {
+	_, err := ms.DB.Exec("UPDATE margins SET sma = GREATEST(sma, ?) WHERE client_id = ?", sma, clientID)
+	return err
+}
+
+// adjustSMA charges a trade against a client's SMA at their initial margin
+// rate: closedValue is the market value of the positions the trade closed and
+// openedValue that of those it opened
+func adjustSMA(tx *sql.Tx, clientID int64, closedValue, openedValue Decimal) error {
+	_, err := tx.Exec(`
+		UPDATE margins
+		SET sma = sma + (? - ?) * initial_margin
+		WHERE client_id = ?
+	`, closedValue, openedValue, clientID)
+	return err
+}
+
+// pricedPosition is a position with the price it is valued at in the account's
+// base currency
+type pricedPosition struct {
+	Position
+	Price    Decimal
+	Currency string
+	FXRate   float64
+}
+
+// requirement works out the maintenance requirement on a position in an
+// instrument. grossValue is the absolute market value of all the client's
+// priced positions.
+func (p pricedPosition) requirement(margin *Margin, rules []MaintenanceRule, instrument Instrument, grossValue Decimal) PositionRequirement {
+	side, shares, rate := LotLong, p.Quantity, margin.MaintenanceMargin
+	if p.Quantity < 0 {
+		side, shares, rate = LotShort, -p.Quantity, margin.ShortMaintenanceMargin
+	}
+
+	req := PositionRequirement{
+		PositionID:  p.ID,
+		Symbol:      p.Symbol,
+		Quantity:    p.Quantity,
+		Price:       p.Price,
+		Currency:    p.Currency,
+		FXRate:      p.FXRate,
+		AssetClass:  instrument.AssetClass,
+		Sector:      instrument.Sector,
+		MarketValue: p.Price.MulInt(shares),
+		Basis:       RequirementAccountRate,
+	}
+	if grossValue > 0 {
+		req.Concentration = req.MarketValue.Ratio(grossValue)
+	}
+
+	if rule := BindingRule(rules, instrument, side, p.Price.Float64(), req.Concentration); rule != nil && rule.Rate > rate {
+		rate = rule.Rate
+		req.Basis = RequirementRule
+		req.RuleID = &rule.ID
+		req.RuleName = rule.Name
+		req.RuleType = rule.Type
+	}
+	if !instrument.Marginable && rate < 1 {
+		rate = 1
+		req.Basis = RequirementNonMarginable
+		req.RuleID = nil
+		req.RuleName = ""
+		req.RuleType = ""
+	}
+
+	req.Rate = rate
+	req.Requirement = req.MarketValue.Mul(rate)
+	if side == LotShort {
+		if minimum := ShortRequirement(shares, p.Price, rate); minimum > req.Requirement {
+			req.Requirement = minimum
+			req.Basis = RequirementShortMinimum
+		}
+	}
+	req.InitialRequirement = MaxDecimal(req.Requirement, req.MarketValue.Mul(margin.InitialMargin))
+	return req
+}
+
+// priceFor returns the price to value a position at under the policy. ok is false
+// when there is no price at all. A stale price haircut lowers the price of a long
+// position and raises that of a short one.
+func (pp PricingPolicy) priceFor(position Position, quotes map[string]PriceQuote) (price Decimal, pricing PositionPricing, ok bool) {
+	pricing = PositionPricing{
+		PositionID: position.ID,
+		Symbol:     position.Symbol,
+		Quantity:   position.Quantity,
+		Quality:    PriceQualityMissing,
+	}
+
+	quote, found := quotes[position.Symbol]
+	if !found {
+		return 0, pricing, false
+	}
+
+	pricing.Price = quote.Price
+	pricing.Currency = quote.Currency
+	if pricing.Currency == "" {
+		pricing.Currency = DefaultCurrency
+	}
+	pricing.PriceAgeSeconds = int64(quote.Age / time.Second)
+	pricing.Quality = PriceQualityFresh
+	if quote.Age > pp.MaxPriceAge() {
+		pricing.Quality = PriceQualityStale
+		if pp.StalePolicy == StalePriceHaircut {
+			pricing.Haircut = pp.Haircut
+		}
+	}
+
+	if position.Quantity < 0 {
+		return quote.Price.Mul(1 + pricing.Haircut), pricing, true
+	}
+	return quote.Price.Mul(1 - pricing.Haircut), pricing, true
+}
+
+// priceIn returns the price to value a position at in currency: the policy's
+// price converted at the latest FX rate. A position quoted in another currency
+// is also charged the FX haircut, lowering the price of a long position and
+// raising that of a short one, and is stale if its FX rate is. ok is false when
+// there is no price or no FX rate.
+func (pp PricingPolicy) priceIn(currency string, position Position, quotes map[string]PriceQuote, rates FXRates) (Decimal, PositionPricing, bool) {
+	price, pricing, ok := pp.priceFor(position, quotes)
+	if !ok {
+		return 0, pricing, false
+	}
+
+	rate, age, found := rates.Convert(pricing.Currency, currency)
+	if !found {
+		pricing.Quality = PriceQualityMissing
+		return 0, pricing, false
+	}
+	pricing.FXRate = rate
+	pricing.FXAgeSeconds = int64(age / time.Second)
+	if pricing.Currency == currency {
+		return price, pricing, true
+	}
+
+	if age > pp.MaxPriceAge() {
+		pricing.Quality = PriceQualityStale
+	}
+	pricing.FXHaircut = pp.FXHaircut
+	if position.Quantity < 0 {
+		return price.Mul(rate * (1 + pricing.FXHaircut)), pricing, true
+	}
+	return price.Mul(rate * (1 - pricing.FXHaircut)), pricing, true
 }
@@ -0,0 +1,382 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MarginCallStatus is the lifecycle state of a margin call
+type MarginCallStatus string
+
+// Margin call states
+const (
+	MarginCallIssued       MarginCallStatus = "issued"
+	MarginCallAcknowledged MarginCallStatus = "acknowledged"
+	MarginCallPartiallyMet MarginCallStatus = "partially_met"
+	MarginCallMet          MarginCallStatus = "met"
+	MarginCallEscalated    MarginCallStatus = "escalated"
+	MarginCallLiquidated   MarginCallStatus = "liquidated"
+)
+
+// marginCallTransitions lists the states each state may move to
+var marginCallTransitions = map[MarginCallStatus][]MarginCallStatus{
+	MarginCallIssued:       {MarginCallAcknowledged, MarginCallPartiallyMet, MarginCallMet, MarginCallEscalated},
+	MarginCallAcknowledged: {MarginCallPartiallyMet, MarginCallMet, MarginCallEscalated},
+	MarginCallPartiallyMet: {MarginCallPartiallyMet, MarginCallMet, MarginCallEscalated},
+	MarginCallEscalated:    {MarginCallPartiallyMet, MarginCallMet, MarginCallLiquidated},
+}
+
+// CanTransitionTo reports whether a call in this state may move to next
+func (s MarginCallStatus) CanTransitionTo(next MarginCallStatus) bool {
+	for _, allowed := range marginCallTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen reports whether the call still requires action
+func (s MarginCallStatus) IsOpen() bool {
+	return s != MarginCallMet && s != MarginCallLiquidated
+}
+
+// ErrInvalidTransition is returned when a margin call cannot move to the requested state
+var ErrInvalidTransition = errors.New("invalid margin call transition")
+
+// MarginCall represents a demand for a client to restore their margin
+type MarginCall struct {
+	ID             int64            `json:"id"`
+	ClientID       int64            `json:"client_id"`
+	Status         MarginCallStatus `json:"status"`
+	AmountDue      Decimal          `json:"amount_due"`
+	AmountMet      Decimal          `json:"amount_met"`
+	IssuedAt       time.Time        `json:"issued_at"`
+	DueAt          time.Time        `json:"due_at"`
+	AcknowledgedAt *time.Time       `json:"acknowledged_at,omitempty"`
+	EscalatedAt    *time.Time       `json:"escalated_at,omitempty"`
+	ResolvedAt     *time.Time       `json:"resolved_at,omitempty"`
+	Notes          string           `json:"notes"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// AmountOutstanding returns the part of the call not yet met
+func (mc *MarginCall) AmountOutstanding() Decimal {
+	if mc.AmountMet >= mc.AmountDue {
+		return 0
+	}
+	return mc.AmountDue - mc.AmountMet
+}
+
+// IsOverdue reports whether an open call has passed its due date
+func (mc *MarginCall) IsOverdue(now time.Time) bool {
+	return mc.Status.IsOpen() && now.After(mc.DueAt)
+}
+
+// MarginCallEvent records a state change of a margin call
+type MarginCallEvent struct {
+	ID           int64            `json:"id"`
+	MarginCallID int64            `json:"margin_call_id"`
+	FromStatus   MarginCallStatus `json:"from_status"`
+	ToStatus     MarginCallStatus `json:"to_status"`
+	Amount       Decimal          `json:"amount"`
+	Notes        string           `json:"notes"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// MarginCallFilter narrows a margin call listing; zero values match everything
+type MarginCallFilter struct {
+	ClientID int64
+	Status   MarginCallStatus
+	OpenOnly bool
+}
+
+// MarginCallService handles database operations for margin calls
+type MarginCallService struct {
+	DB *sql.DB
+}
+
+// marginCallColumns is the column list scanned by scanMarginCall
+const marginCallColumns = `
+	id, client_id, status, amount_due, amount_met, issued_at, due_at,
+	acknowledged_at, escalated_at, resolved_at, notes, created_at, updated_at
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMarginCall scans a margin call selected with marginCallColumns
+func scanMarginCall(row rowScanner) (*MarginCall, error) {
+	var mc MarginCall
+	var acknowledgedAt, escalatedAt, resolvedAt sql.NullTime
+	err := row.Scan(
+		&mc.ID,
+		&mc.ClientID,
+		&mc.Status,
+		&mc.AmountDue,
+		&mc.AmountMet,
+		&mc.IssuedAt,
+		&mc.DueAt,
+		&acknowledgedAt,
+		&escalatedAt,
+		&resolvedAt,
+		&mc.Notes,
+		&mc.CreatedAt,
+		&mc.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mc.AcknowledgedAt = nullTimePtr(acknowledgedAt)
+	mc.EscalatedAt = nullTimePtr(escalatedAt)
+	mc.ResolvedAt = nullTimePtr(resolvedAt)
+	return &mc, nil
+}
+
+// nullTimePtr converts a sql.NullTime to a *time.Time
+func nullTimePtr(nt sql.NullTime) *time.Time {
+	if !nt.Valid {
+		return nil
+	}
+	t := nt.Time
+	return &t
+}
+
+// GetMarginCall retrieves a margin call by ID
+func (mcs *MarginCallService) GetMarginCall(id int64) (*MarginCall, error) {
+	query := `SELECT ` + marginCallColumns + ` FROM margin_calls WHERE id = ?`
+
+	mc, err := scanMarginCall(mcs.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return mc, err
+}
+
+// GetOpenMarginCall retrieves the open margin call for a client, if any
+func (mcs *MarginCallService) GetOpenMarginCall(clientID int64) (*MarginCall, error) {
+	query := `SELECT ` + marginCallColumns + `
+		FROM margin_calls
+		WHERE client_id = ? AND status NOT IN (?, ?)
+		ORDER BY issued_at DESC, id DESC
+		LIMIT 1
+	`
+
+	mc, err := scanMarginCall(mcs.DB.QueryRow(query, clientID, MarginCallMet, MarginCallLiquidated))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return mc, err
+}
+
+// ListMarginCalls retrieves margin calls matching the filter, newest first
+func (mcs *MarginCallService) ListMarginCalls(filter MarginCallFilter) ([]MarginCall, error) {
+	query := `SELECT ` + marginCallColumns + ` FROM margin_calls WHERE 1 = 1`
+	var args []interface{}
+	if filter.ClientID != 0 {
+		query += " AND client_id = ?"
+		args = append(args, filter.ClientID)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	if filter.OpenOnly {
+		query += " AND status NOT IN (?, ?)"
+		args = append(args, MarginCallMet, MarginCallLiquidated)
+	}
+	query += " ORDER BY issued_at DESC, id DESC"
+
+	rows, err := mcs.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calls := []MarginCall{}
+	for rows.Next() {
+		mc, err := scanMarginCall(rows)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, *mc)
+	}
+
+	return calls, rows.Err()
+}
+
+// GetEvents retrieves the state history of a margin call, oldest first
+func (mcs *MarginCallService) GetEvents(marginCallID int64) ([]MarginCallEvent, error) {
+	query := `
+		SELECT id, margin_call_id, from_status, to_status, amount, notes, created_at
+		FROM margin_call_events
+		WHERE margin_call_id = ?
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := mcs.DB.Query(query, marginCallID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []MarginCallEvent{}
+	for rows.Next() {
+		var e MarginCallEvent
+		err := rows.Scan(&e.ID, &e.MarginCallID, &e.FromStatus, &e.ToStatus, &e.Amount, &e.Notes, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// IssueMarginCall creates a new margin call for a client
+func (mcs *MarginCallService) IssueMarginCall(clientID int64, amountDue Decimal, dueAt time.Time) (*MarginCall, error) {
+	tx, err := mcs.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO margin_calls (client_id, status, amount_due, amount_met, issued_at, due_at, created_at, updated_at)
+		VALUES (?, ?, ?, 0, NOW(), ?, NOW(), NOW())
+	`, clientID, MarginCallIssued, amountDue, dueAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertMarginCallEvent(tx, id, "", MarginCallIssued, amountDue, ""); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return mcs.GetMarginCall(id)
+}
+
+// Acknowledge records that the client has acknowledged a margin call
+func (mcs *MarginCallService) Acknowledge(id int64, notes string) (*MarginCall, error) {
+	return mcs.transition(id, notes, func(mc *MarginCall) (MarginCallStatus, Decimal, error) {
+		return MarginCallAcknowledged, 0, nil
+	})
+}
+
+// RecordPayment applies funds or collateral received against a margin call,
+// moving it to met once the amount due is covered and partially met otherwise
+func (mcs *MarginCallService) RecordPayment(id int64, amount Decimal, notes string) (*MarginCall, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("payment amount must be positive")
+	}
+
+	return mcs.transition(id, notes, func(mc *MarginCall) (MarginCallStatus, Decimal, error) {
+		if mc.AmountMet+amount >= mc.AmountDue {
+			return MarginCallMet, amount, nil
+		}
+		return MarginCallPartiallyMet, amount, nil
+	})
+}
+
+// Resolve closes a margin call as met without a payment, e.g. when market
+// moves have cured the shortfall
+func (mcs *MarginCallService) Resolve(id int64, notes string) (*MarginCall, error) {
+	return mcs.transition(id, notes, func(mc *MarginCall) (MarginCallStatus, Decimal, error) {
+		return MarginCallMet, 0, nil
+	})
+}
+
+// Escalate escalates an unmet margin call
+func (mcs *MarginCallService) Escalate(id int64, notes string) (*MarginCall, error) {
+	return mcs.transition(id, notes, func(mc *MarginCall) (MarginCallStatus, Decimal, error) {
+		return MarginCallEscalated, 0, nil
+	})
+}
+
+// Liquidate records that positions were liquidated to cover an escalated call
+func (mcs *MarginCallService) Liquidate(id int64, notes string) (*MarginCall, error) {
+	return mcs.transition(id, notes, func(mc *MarginCall) (MarginCallStatus, Decimal, error) {
+		return MarginCallLiquidated, 0, nil
+	})
+}
+
+// transition locks a margin call, asks next for the target state and amount
+// applied, validates the move and records it
+func (mcs *MarginCallService) transition(id int64, notes string, next func(mc *MarginCall) (MarginCallStatus, Decimal, error)) (*MarginCall, error) {
+	tx, err := mcs.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + marginCallColumns + ` FROM margin_calls WHERE id = ? FOR UPDATE`
+	mc, err := scanMarginCall(tx.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	to, amount, err := next(mc)
+	if err != nil {
+		return nil, err
+	}
+	if !mc.Status.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, mc.Status, to)
+	}
+
+	update := `UPDATE margin_calls SET status = ?, amount_met = amount_met + ?, updated_at = NOW()`
+	switch to {
+	case MarginCallAcknowledged:
+		update += ", acknowledged_at = NOW()"
+	case MarginCallEscalated:
+		update += ", escalated_at = NOW()"
+	case MarginCallMet, MarginCallLiquidated:
+		update += ", resolved_at = NOW()"
+	}
+	args := []interface{}{to, amount}
+	if notes != "" {
+		update += ", notes = ?"
+		args = append(args, notes)
+	}
+	update += " WHERE id = ?"
+	args = append(args, id)
+
+	if _, err := tx.Exec(update, args...); err != nil {
+		return nil, err
+	}
+	if err := insertMarginCallEvent(tx, id, mc.Status, to, amount, notes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return mcs.GetMarginCall(id)
+}
+
+// insertMarginCallEvent records a margin call state change
+func insertMarginCallEvent(tx *sql.Tx, marginCallID int64, from, to MarginCallStatus, amount Decimal, notes string) error {
+	_, err := tx.Exec(`
+		INSERT INTO margin_call_events (margin_call_id, from_status, to_status, amount, notes, created_at)
+		VALUES (?, ?, ?, ?, ?, NOW())
+	`, marginCallID, from, to, amount, notes)
+	return err
+}
@@ -2,17 +2,41 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 )
 
-// MarketData represents real-time market data for a symbol
+// MarketData represents real-time market data for a symbol. Currency is the
+// currency the symbol is quoted in; updates without one keep the stored
+// currency, or DefaultCurrency for a new symbol.
 type MarketData struct {
 	ID           int64     `json:"id"`
 	Symbol       string    `json:"symbol"`
 	CurrentPrice float64   `json:"current_price"`
+	Currency     string    `json:"currency"`
 	Timestamp    time.Time `json:"timestamp"`
 }
 
+// PriceQuality describes how trustworthy a price is for valuation
+type PriceQuality string
+
+// Price quality flags
+const (
+	PriceQualityFresh   PriceQuality = "fresh"
+	PriceQualityStale   PriceQuality = "stale"
+	PriceQualityMissing PriceQuality = "missing"
+)
+
+// PriceQuote is the latest stored price for a symbol together with its age
+type PriceQuote struct {
+	Symbol    string        `json:"symbol"`
+	Price     Decimal       `json:"price"`
+	Currency  string        `json:"currency"`
+	Timestamp time.Time     `json:"timestamp"`
+	Age       time.Duration `json:"-"`
+	Quality   PriceQuality  `json:"quality"`
+}
+
 // MarketDataService handles database operations for market data
 type MarketDataService struct {
 	DB *sql.DB
@@ -21,7 +45,7 @@ type MarketDataService struct {
 // GetCurrentPrice retrieves the current price for a symbol
 func (mds *MarketDataService) GetCurrentPrice(symbol string) (*MarketData, error) {
 	query := `
-		SELECT id, symbol, current_price, timestamp
+		SELECT id, symbol, current_price, currency, timestamp
 		FROM market_data
 		WHERE symbol = ?
 		ORDER BY timestamp DESC
@@ -33,6 +57,7 @@ func (mds *MarketDataService) GetCurrentPrice(symbol string) (*MarketData, error
 		&md.ID,
 		&md.Symbol,
 		&md.CurrentPrice,
+		&md.Currency,
 		&md.Timestamp,
 	)
 	if err == sql.ErrNoRows {
@@ -45,50 +70,107 @@ func (mds *MarketDataService) GetCurrentPrice(symbol string) (*MarketData, error
 	return &md, nil
 }
 
-// UpdateMarketData updates or inserts market data for a symbol
+// UpdateMarketData updates or inserts market data for a symbol and appends the
+// price to the symbol's history
 func (mds *MarketDataService) UpdateMarketData(md *MarketData) error {
 	query := `
-		INSERT INTO market_data (symbol, current_price, timestamp)
-		VALUES (?, ?, NOW())
+		INSERT INTO market_data (symbol, current_price, currency, timestamp)
+		VALUES (?, ?, ?, NOW())
 		ON DUPLICATE KEY UPDATE
 		current_price = VALUES(current_price),
+		currency = VALUES(currency),
 		timestamp = VALUES(timestamp)
 	`
+	historyQuery := `
+		INSERT INTO price_history (symbol, price, timestamp)
+		VALUES (?, ?, NOW())
+	`
 
-	_, err := mds.DB.Exec(query, md.Symbol, md.CurrentPrice)
-	return err
+	tx, err := mds.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if md.Currency == "" {
+		if md.Currency, err = symbolCurrency(tx, md.Symbol); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(query, md.Symbol, md.CurrentPrice, md.Currency); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(historyQuery, md.Symbol, md.CurrentPrice); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// GetMarketDataForSymbols retrieves current prices for multiple symbols
-func (mds *MarketDataService) GetMarketDataForSymbols(symbols []string) (map[string]float64, error) {
-	query := `
-		SELECT symbol, current_price
+// symbolCurrency returns the currency of a symbol in the instrument master,
+// else that of its latest stored price, or DefaultCurrency if it has neither
+func symbolCurrency(tx *sql.Tx, symbol string) (string, error) {
+	var currency string
+	err := tx.QueryRow("SELECT currency FROM instruments WHERE symbol = ?", symbol).Scan(&currency)
+	if err != sql.ErrNoRows {
+		return currency, err
+	}
+
+	err = tx.QueryRow(`
+		SELECT currency
 		FROM market_data
-		WHERE symbol IN (?)
-		AND timestamp >= DATE_SUB(NOW(), INTERVAL 5 MINUTE)
-	`
+		WHERE symbol = ?
+		ORDER BY timestamp DESC
+		LIMIT 1
+	`, symbol).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return DefaultCurrency, nil
+	}
+	return currency, err
+}
 
-	// Convert symbols slice to interface{} for the query
-	args := make([]interface{}, len(symbols))
-	for i, symbol := range symbols {
-		args[i] = symbol
+// GetQuotesForSymbols retrieves the latest stored price for each symbol, however
+// old, flagging prices older than maxAge as stale. Symbols with no stored price
+// are omitted from the result.
+func (mds *MarketDataService) GetQuotesForSymbols(symbols []string, maxAge time.Duration) (map[string]PriceQuote, error) {
+	quotes := make(map[string]PriceQuote)
+	if len(symbols) == 0 {
+		return quotes, nil
 	}
 
-	rows, err := mds.DB.Query(query, args...)
+	query := fmt.Sprintf(`
+		SELECT m.symbol, m.current_price, m.currency, m.timestamp, TIMESTAMPDIFF(SECOND, m.timestamp, NOW())
+		FROM market_data m
+		JOIN (
+			SELECT symbol, MAX(timestamp) AS latest
+			FROM market_data
+			WHERE symbol IN (%s)
+			GROUP BY symbol
+		) l ON m.symbol = l.symbol AND m.timestamp = l.latest
+	`, inPlaceholders(len(symbols)))
+
+	rows, err := mds.DB.Query(query, stringArgs(symbols)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	prices := make(map[string]float64)
 	for rows.Next() {
-		var symbol string
-		var price float64
-		if err := rows.Scan(&symbol, &price); err != nil {
+		var q PriceQuote
+		var ageSeconds int64
+		if err := rows.Scan(&q.Symbol, &q.Price, &q.Currency, &q.Timestamp, &ageSeconds); err != nil {
 			return nil, err
 		}
-		prices[symbol] = price
+		if ageSeconds < 0 {
+			ageSeconds = 0
+		}
+		q.Age = time.Duration(ageSeconds) * time.Second
+		q.Quality = PriceQualityFresh
+		if q.Age > maxAge {
+			q.Quality = PriceQualityStale
+		}
+		quotes[q.Symbol] = q
 	}
 
-	return prices, nil
+	return quotes, rows.Err()
 }
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minirisk/config"
+)
+
+// ChannelType identifies how a notification is delivered
+type ChannelType string
+
+// Notification channel types
+const (
+	ChannelEmail   ChannelType = "email"
+	ChannelWebhook ChannelType = "webhook"
+	ChannelFile    ChannelType = "file"
+)
+
+// NotificationChannel is a destination for margin call alerts. A nil ClientID
+// marks a global channel that receives alerts for every client.
+type NotificationChannel struct {
+	ID        int64       `json:"id"`
+	ClientID  *int64      `json:"client_id"`
+	Type      ChannelType `json:"channel_type"`
+	Target    string      `json:"target"`
+	Secret    string      `json:"secret,omitempty"`
+	Enabled   bool        `json:"enabled"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Validate checks that the channel is well formed
+func (nc *NotificationChannel) Validate() error {
+	switch nc.Type {
+	case ChannelEmail, ChannelWebhook, ChannelFile:
+	default:
+		return fmt.Errorf("unknown channel type: %s", nc.Type)
+	}
+	if nc.Target == "" {
+		return fmt.Errorf("channel target is required")
+	}
+	return nil
+}
+
+// ValidateTarget checks the channel's target against the delivery policy:
+// webhooks must post to an allowed scheme and host, and files must be written
+// inside the alert directory
+func (nc *NotificationChannel) ValidateTarget(cfg config.NotificationConfig) error {
+	switch nc.Type {
+	case ChannelWebhook:
+		return ValidateWebhookURL(nc.Target, cfg)
+	case ChannelFile:
+		_, err := ChannelFilePath(nc.Target, cfg)
+		return err
+	}
+	return nil
+}
+
+// ValidateWebhookURL checks that a webhook URL uses one of the allowed schemes
+// and hosts, so that channels cannot be pointed at internal services
+func ValidateWebhookURL(target string, cfg config.NotificationConfig) error {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL")
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook URL must not contain credentials")
+	}
+
+	schemeAllowed := false
+	for _, scheme := range cfg.WebhookAllowedSchemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			schemeAllowed = true
+		}
+	}
+	if !schemeAllowed {
+		return fmt.Errorf("webhook scheme %q is not allowed", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range cfg.WebhookAllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook host %q is not allowed", host)
+}
+
+// ChannelFilePath returns the path a file channel writes to: its target, a
+// relative path that must stay inside the alert directory
+func ChannelFilePath(target string, cfg config.NotificationConfig) (string, error) {
+	if cfg.FileDir == "" {
+		return "", fmt.Errorf("file channels are disabled")
+	}
+	if filepath.IsAbs(target) || !filepath.IsLocal(target) {
+		return "", fmt.Errorf("file target must be a relative path inside the alert directory")
+	}
+	if filepath.Ext(target) != ".jsonl" {
+		return "", fmt.Errorf("file target must be a .jsonl file")
+	}
+	return filepath.Join(cfg.FileDir, target), nil
+}
+
+// NotificationChannelService handles database operations for notification channels
+type NotificationChannelService struct {
+	DB *sql.DB
+}
+
+// notificationChannelColumns is the column list scanned by scanNotificationChannel
+const notificationChannelColumns = `id, client_id, channel_type, target, secret, enabled, created_at, updated_at`
+
+// scanNotificationChannel scans a channel selected with notificationChannelColumns
+func scanNotificationChannel(row rowScanner) (*NotificationChannel, error) {
+	var nc NotificationChannel
+	var clientID sql.NullInt64
+	err := row.Scan(&nc.ID, &clientID, &nc.Type, &nc.Target, &nc.Secret, &nc.Enabled, &nc.CreatedAt, &nc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if clientID.Valid {
+		nc.ClientID = &clientID.Int64
+	}
+	return &nc, nil
+}
+
+// queryNotificationChannels runs a channel query and scans the results
+func (ncs *NotificationChannelService) queryNotificationChannels(query string, args ...interface{}) ([]NotificationChannel, error) {
+	rows, err := ncs.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []NotificationChannel{}
+	for rows.Next() {
+		nc, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, *nc)
+	}
+
+	return channels, rows.Err()
+}
+
+// GetChannels retrieves all channels, or only those of one client when clientID is non-zero
+func (ncs *NotificationChannelService) GetChannels(clientID int64) ([]NotificationChannel, error) {
+	if clientID == 0 {
+		return ncs.queryNotificationChannels(`SELECT ` + notificationChannelColumns + ` FROM notification_channels ORDER BY id`)
+	}
+	return ncs.queryNotificationChannels(`
+		SELECT `+notificationChannelColumns+`
+		FROM notification_channels
+		WHERE client_id = ?
+		ORDER BY id
+	`, clientID)
+}
+
+// GetChannelsForClient retrieves the enabled channels that should receive a
+// client's alerts: the client's own channels and all global channels
+func (ncs *NotificationChannelService) GetChannelsForClient(clientID int64) ([]NotificationChannel, error) {
+	return ncs.queryNotificationChannels(`
+		SELECT `+notificationChannelColumns+`
+		FROM notification_channels
+		WHERE enabled = TRUE AND (client_id = ? OR client_id IS NULL)
+		ORDER BY id
+	`, clientID)
+}
+
+// CreateChannel creates a new notification channel
+func (ncs *NotificationChannelService) CreateChannel(nc *NotificationChannel) error {
+	query := `
+		INSERT INTO notification_channels (client_id, channel_type, target, secret, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+	`
+
+	result, err := ncs.DB.Exec(query, nc.ClientID, nc.Type, nc.Target, nc.Secret, nc.Enabled)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	nc.ID = id
+	return nil
+}
+
+// DeleteChannel deletes a notification channel
+func (ncs *NotificationChannelService) DeleteChannel(id int64) error {
+	_, err := ncs.DB.Exec("DELETE FROM notification_channels WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/minirisk/config"
+)
+
+func TestNotificationChannelValidateTarget(t *testing.T) {
+	cfg := config.NotificationConfig{
+		WebhookAllowedHosts:   []string{"hooks.example.com", "*.alerts.example.org"},
+		WebhookAllowedSchemes: []string{"https"},
+		FileDir:               "logs/alerts",
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.NotificationConfig
+		channel NotificationChannel
+		wantErr bool
+	}{
+		{name: "allowed host", cfg: cfg, channel: NotificationChannel{Type: ChannelWebhook, Target: "https://hooks.example.com/minirisk"}},
+		{name: "host is case-insensitive", cfg: cfg, channel: NotificationChannel{Type: ChannelWebhook, Target: "https://HOOKS.example.com/x"}},
+		{name: "wildcard subdomain", cfg: cfg, channel: NotificationChannel{Type: ChannelWebhook, Target: "https://ops.alerts.example.org/x"}},
+		{name: "wildcard does not match the bare domain suffix", cfg: cfg, channel: NotificationChannel{Type: ChannelWebhook, Target: "https://evilalerts.example.org/x"}, wantErr: true},
+		{name: "disallowed scheme", cfg: cfg, channel: NotificationChannel{Type: ChannelWebhook, Target: "http://hooks.example.com/x"}, wantErr: true},
+		{name: "internal address", cfg: cfg, channel: NotificationChannel{Type: ChannelWebhook, Target: "https://127.0.0.1/admin"}, wantErr: true},
+		{name: "credentials in URL", cfg: cfg, channel: NotificationChannel{Type: ChannelWebhook, Target: "https://user:pw@hooks.example.com/x"}, wantErr: true},
+		{name: "no allowed hosts", cfg: config.NotificationConfig{WebhookAllowedSchemes: []string{"https"}}, channel: NotificationChannel{Type: ChannelWebhook, Target: "https://hooks.example.com/x"}, wantErr: true},
+		{name: "file in directory", cfg: cfg, channel: NotificationChannel{Type: ChannelFile, Target: "client-42.jsonl"}},
+		{name: "file in subdirectory", cfg: cfg, channel: NotificationChannel{Type: ChannelFile, Target: "desk/client-42.jsonl"}},
+		{name: "absolute file", cfg: cfg, channel: NotificationChannel{Type: ChannelFile, Target: "/etc/passwd.jsonl"}, wantErr: true},
+		{name: "file escaping directory", cfg: cfg, channel: NotificationChannel{Type: ChannelFile, Target: "../minirisk.jsonl"}, wantErr: true},
+		{name: "file that is not jsonl", cfg: cfg, channel: NotificationChannel{Type: ChannelFile, Target: "client.sh"}, wantErr: true},
+		{name: "file channels disabled", cfg: config.NotificationConfig{}, channel: NotificationChannel{Type: ChannelFile, Target: "client-42.jsonl"}, wantErr: true},
+		{name: "email is not restricted", cfg: cfg, channel: NotificationChannel{Type: ChannelEmail, Target: "ops@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.channel.ValidateTarget(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTarget(%q) error = %v, wantErr %v", tt.channel.Target, err, tt.wantErr)
+			}
+		})
+	}
+
+	path, err := ChannelFilePath("desk/client-42.jsonl", cfg)
+	if err != nil || path != filepath.Join("logs/alerts", "desk/client-42.jsonl") {
+		t.Errorf("ChannelFilePath = %q, %v", path, err)
+	}
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PositionPnL is the profit and loss of one position
+type PositionPnL struct {
+	Symbol        string       `json:"symbol"`
+	Quantity      int          `json:"quantity"`
+	CostBasis     Decimal      `json:"cost_basis"`
+	Price         Decimal      `json:"price"`
+	Quality       PriceQuality `json:"quality"`
+	PreviousClose *Decimal     `json:"previous_close"`
+	MarketValue   Decimal      `json:"market_value"`
+	CostValue     Decimal      `json:"cost_value"`
+	UnrealizedPnL Decimal      `json:"unrealized_pnl"`
+	DayChange     Decimal      `json:"day_change"`
+	RealizedPnL   Decimal      `json:"realized_pnl"`
+}
+
+// PnLReport is a client's profit and loss at a point in time. Totals exclude
+// positions without a price. DayChange is the move in value of the positions
+// held since the previous day's close.
+type PnLReport struct {
+	ClientID         int64         `json:"client_id"`
+	AsOf             time.Time     `json:"as_of"`
+	MarketValue      Decimal       `json:"market_value"`
+	CostValue        Decimal       `json:"cost_value"`
+	UnrealizedPnL    Decimal       `json:"unrealized_pnl"`
+	DayChange        Decimal       `json:"day_change"`
+	RealizedPnL      Decimal       `json:"realized_pnl"`
+	RealizedPnLToday Decimal       `json:"realized_pnl_today"`
+	Positions        []PositionPnL `json:"positions"`
+	UnpricedSymbols  []string      `json:"unpriced_symbols"`
+}
+
+// PnLSnapshot is a client's P&L report persisted at the end of a day
+type PnLSnapshot struct {
+	ID           int64     `json:"id"`
+	SnapshotDate time.Time `json:"snapshot_date"`
+	PnLReport
+}
+
+// BuildPnLReport values positions at their quoted prices. previousCloses gives
+// the last price of each symbol before today; realized and realizedToday give
+// the realized P&L per symbol over all time and for today. Prices are rounded
+// to a Decimal before they are multiplied into values.
+func BuildPnLReport(clientID int64, positions []Position, quotes map[string]PriceQuote, previousCloses map[string]float64, realized, realizedToday map[string]Decimal) *PnLReport {
+	report := &PnLReport{
+		ClientID:        clientID,
+		AsOf:            time.Now(),
+		Positions:       []PositionPnL{},
+		UnpricedSymbols: []string{},
+	}
+
+	for _, position := range positions {
+		pnl := PositionPnL{
+			Symbol:      position.Symbol,
+			Quantity:    position.Quantity,
+			CostBasis:   position.CostBasis,
+			Quality:     PriceQualityMissing,
+			CostValue:   position.CostBasis.MulInt(position.Quantity),
+			RealizedPnL: realized[position.Symbol],
+		}
+
+		quote, ok := quotes[position.Symbol]
+		if !ok {
+			report.UnpricedSymbols = append(report.UnpricedSymbols, position.Symbol)
+			report.Positions = append(report.Positions, pnl)
+			continue
+		}
+
+		pnl.Price = quote.Price
+		pnl.Quality = quote.Quality
+		pnl.MarketValue = pnl.Price.MulInt(position.Quantity)
+		pnl.UnrealizedPnL = pnl.MarketValue - pnl.CostValue
+		if previous, ok := previousCloses[position.Symbol]; ok {
+			prev := NewDecimal(previous)
+			pnl.PreviousClose = &prev
+			pnl.DayChange = (pnl.Price - prev).MulInt(position.Quantity)
+		}
+
+		report.MarketValue += pnl.MarketValue
+		report.CostValue += pnl.CostValue
+		report.UnrealizedPnL += pnl.UnrealizedPnL
+		report.DayChange += pnl.DayChange
+		report.Positions = append(report.Positions, pnl)
+	}
+
+	// Realized P&L includes symbols that are no longer held
+	for _, amount := range realized {
+		report.RealizedPnL += amount
+	}
+	for _, amount := range realizedToday {
+		report.RealizedPnLToday += amount
+	}
+
+	sort.Slice(report.Positions, func(i, j int) bool {
+		return report.Positions[i].Symbol < report.Positions[j].Symbol
+	})
+	return report
+}
+
+// PnLService calculates and stores client P&L
+type PnLService struct {
+	DB      *sql.DB
+	Pricing PricingPolicy
+}
+
+// CalculatePnL builds a client's current P&L report from their positions,
+// market prices, price history and realized lots
+func (ps *PnLService) CalculatePnL(clientID int64) (*PnLReport, error) {
+	positionService := &PositionService{DB: ps.DB}
+	positions, err := positionService.GetPositionsByClientID(clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %v", err)
+	}
+
+	var symbols []string
+	for _, position := range positions {
+		symbols = append(symbols, position.Symbol)
+	}
+
+	marketDataService := &MarketDataService{DB: ps.DB}
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, ps.Pricing.MaxPriceAge())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market prices: %v", err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	priceHistoryService := &PriceHistoryService{DB: ps.DB}
+	previousCloses, err := priceHistoryService.GetPreviousCloses(symbols, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous closes: %v", err)
+	}
+
+	taxLotService := &TaxLotService{DB: ps.DB}
+	realized, realizedToday, err := taxLotService.GetRealizedBySymbol(clientID, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get realized P&L: %v", err)
+	}
+
+	return BuildPnLReport(clientID, positions, quotes, previousCloses, realized, realizedToday), nil
+}
+
+// SaveSnapshot stores a report as the client's snapshot for the report's day,
+// replacing any earlier snapshot for that day
+func (ps *PnLService) SaveSnapshot(report *PnLReport) error {
+	positions, err := json.Marshal(report.Positions)
+	if err != nil {
+		return err
+	}
+
+	_, err = ps.DB.Exec(`
+		INSERT INTO pnl_snapshots (client_id, snapshot_date, market_value, cost_value, unrealized_pnl, day_change, realized_pnl, realized_pnl_today, positions, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE
+			market_value = VALUES(market_value),
+			cost_value = VALUES(cost_value),
+			unrealized_pnl = VALUES(unrealized_pnl),
+			day_change = VALUES(day_change),
+			realized_pnl = VALUES(realized_pnl),
+			realized_pnl_today = VALUES(realized_pnl_today),
+			positions = VALUES(positions),
+			updated_at = NOW()
+	`, report.ClientID, report.AsOf.UTC().Truncate(24*time.Hour), report.MarketValue, report.CostValue,
+		report.UnrealizedPnL, report.DayChange, report.RealizedPnL, report.RealizedPnLToday, positions)
+	return err
+}
+
+// GetSnapshots retrieves a client's daily snapshots in [from, to), oldest first
+func (ps *PnLService) GetSnapshots(clientID int64, from, to time.Time) ([]PnLSnapshot, error) {
+	query := `
+		SELECT id, client_id, snapshot_date, updated_at, market_value, cost_value, unrealized_pnl,
+			day_change, realized_pnl, realized_pnl_today, positions
+		FROM pnl_snapshots
+		WHERE client_id = ? AND snapshot_date >= ? AND snapshot_date < ?
+		ORDER BY snapshot_date
+	`
+
+	rows, err := ps.DB.Query(query, clientID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []PnLSnapshot{}
+	for rows.Next() {
+		var s PnLSnapshot
+		var positions []byte
+		err := rows.Scan(
+			&s.ID,
+			&s.ClientID,
+			&s.SnapshotDate,
+			&s.AsOf,
+			&s.MarketValue,
+			&s.CostValue,
+			&s.UnrealizedPnL,
+			&s.DayChange,
+			&s.RealizedPnL,
+			&s.RealizedPnLToday,
+			&positions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(positions, &s.Positions); err != nil {
+			return nil, err
+		}
+		s.UnpricedSymbols = []string{}
+		for _, p := range s.Positions {
+			if p.Quality == PriceQualityMissing {
+				s.UnpricedSymbols = append(s.UnpricedSymbols, p.Symbol)
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	return snapshots, rows.Err()
+}
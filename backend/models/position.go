@@ -11,7 +11,7 @@ type Position struct {
 	ClientID  int64     `json:"client_id"`
 	Symbol    string    `json:"symbol"`
 	Quantity  int       `json:"quantity"`
-	CostBasis float64   `json:"cost_basis"`
+	CostBasis Decimal   `json:"cost_basis"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -77,25 +77,58 @@ func (ps *PositionService) CreatePosition(p *Position) error {
 	return nil
 }
 
-// UpdatePosition updates an existing position
-func (ps *PositionService) UpdatePosition(p *Position) error {
+// GetPositionBySymbol retrieves a client's position in a symbol
+func (ps *PositionService) GetPositionBySymbol(clientID int64, symbol string) (*Position, error) {
 	query := `
-		UPDATE positions
-		SET quantity = ?, cost_basis = ?, updated_at = NOW()
-		WHERE id = ? AND client_id = ?
+		SELECT id, client_id, symbol, quantity, cost_basis, created_at, updated_at
+		FROM positions
+		WHERE client_id = ? AND symbol = ?
 	`
 
-	_, err := ps.DB.Exec(query, p.Quantity, p.CostBasis, p.ID, p.ClientID)
-	return err
+	var p Position
+	err := ps.DB.QueryRow(query, clientID, symbol).Scan(
+		&p.ID,
+		&p.ClientID,
+		&p.Symbol,
+		&p.Quantity,
+		&p.CostBasis,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
 }
 
-// DeletePosition deletes a position
-func (ps *PositionService) DeletePosition(id, clientID int64) error {
+// GetPosition retrieves a client's position by ID
+func (ps *PositionService) GetPosition(id, clientID int64) (*Position, error) {
 	query := `
-		DELETE FROM positions
+		SELECT id, client_id, symbol, quantity, cost_basis, created_at, updated_at
+		FROM positions
 		WHERE id = ? AND client_id = ?
 	`
 
-	_, err := ps.DB.Exec(query, id, clientID)
-	return err
+	var p Position
+	err := ps.DB.QueryRow(query, id, clientID).Scan(
+		&p.ID,
+		&p.ClientID,
+		&p.Symbol,
+		&p.Quantity,
+		&p.CostBasis,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
 }
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PriceTick represents a single recorded price for a symbol
+type PriceTick struct {
+	ID        int64     `json:"id"`
+	Symbol    string    `json:"symbol"`
+	Price     float64   `json:"price"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BarInterval is the width of an OHLC bar
+type BarInterval string
+
+// Supported bar intervals
+const (
+	BarInterval1m BarInterval = "1m"
+	BarInterval1h BarInterval = "1h"
+	BarInterval1d BarInterval = "1d"
+)
+
+// ParseBarInterval validates a bar interval string
+func ParseBarInterval(s string) (BarInterval, error) {
+	switch BarInterval(s) {
+	case BarInterval1m, BarInterval1h, BarInterval1d:
+		return BarInterval(s), nil
+	default:
+		return "", fmt.Errorf("unsupported interval: %s", s)
+	}
+}
+
+// Duration returns the length of the interval
+func (bi BarInterval) Duration() time.Duration {
+	switch bi {
+	case BarInterval1m:
+		return time.Minute
+	case BarInterval1h:
+		return time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// OHLCBar represents aggregated open/high/low/close prices over an interval
+type OHLCBar struct {
+	Symbol    string      `json:"symbol"`
+	Interval  BarInterval `json:"interval"`
+	Start     time.Time   `json:"start"`
+	Open      float64     `json:"open"`
+	High      float64     `json:"high"`
+	Low       float64     `json:"low"`
+	Close     float64     `json:"close"`
+	TickCount int         `json:"tick_count"`
+}
+
+// PriceHistoryService handles database operations for price history
+type PriceHistoryService struct {
+	DB *sql.DB
+}
+
+// barEpoch is the origin bars are aligned from, so that bars fall on UTC
+// interval boundaries
+var barEpoch = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// GetBars aggregates the price history for a symbol in [from, to) into OHLC bars.
+// Bars are aligned to UTC interval boundaries and intervals without ticks are
+// omitted. Ticks are grouped in the database, so only the bars are loaded. The
+// open and close of a bar are its earliest and latest ticks by timestamp, ties
+// broken by the order they were recorded in.
+func (phs *PriceHistoryService) GetBars(symbol string, from, to time.Time, interval BarInterval) ([]OHLCBar, error) {
+	width := int64(interval.Duration() / time.Second)
+	query := `
+		SELECT bucket,
+			MAX(CASE WHEN first_tick = 1 THEN price END),
+			MAX(price), MIN(price),
+			MAX(CASE WHEN last_tick = 1 THEN price END),
+			COUNT(*)
+		FROM (
+			SELECT bucket, price,
+				ROW_NUMBER() OVER (PARTITION BY bucket ORDER BY timestamp ASC, id ASC) AS first_tick,
+				ROW_NUMBER() OVER (PARTITION BY bucket ORDER BY timestamp DESC, id DESC) AS last_tick
+			FROM (
+				SELECT id, price, timestamp, TIMESTAMPDIFF(SECOND, ?, timestamp) DIV ? AS bucket
+				FROM price_history
+				WHERE symbol = ? AND timestamp >= ? AND timestamp < ?
+			) t
+		) r
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`
+
+	rows, err := phs.DB.Query(query, barEpoch, width, symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bars := []OHLCBar{}
+	for rows.Next() {
+		bar := OHLCBar{Symbol: symbol, Interval: interval}
+		var bucket int64
+		if err := rows.Scan(&bucket, &bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.TickCount); err != nil {
+			return nil, err
+		}
+		bar.Start = barEpoch.Add(time.Duration(bucket*width) * time.Second)
+		bars = append(bars, bar)
+	}
+
+	return bars, rows.Err()
+}
+
+// GetDailyCloses retrieves the last recorded price of each day for a symbol in
+// [from, to), oldest first
+func (phs *PriceHistoryService) GetDailyCloses(symbol string, from, to time.Time) ([]PriceTick, error) {
+	query := `
+		SELECT p.id, p.symbol, p.price, p.timestamp
+		FROM price_history p
+		JOIN (
+			SELECT MAX(id) AS id
+			FROM price_history
+			WHERE symbol = ? AND timestamp >= ? AND timestamp < ?
+			GROUP BY DATE(timestamp)
+		) l ON p.id = l.id
+		ORDER BY p.timestamp ASC
+	`
+
+	rows, err := phs.DB.Query(query, symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var closes []PriceTick
+	for rows.Next() {
+		var t PriceTick
+		if err := rows.Scan(&t.ID, &t.Symbol, &t.Price, &t.Timestamp); err != nil {
+			return nil, err
+		}
+		closes = append(closes, t)
+	}
+
+	return closes, rows.Err()
+}
+
+// DailyReturn is the simple return of a symbol from the previous recorded close
+type DailyReturn struct {
+	Date   time.Time `json:"date"`
+	Return float64   `json:"return"`
+}
+
+// GetDailyReturns retrieves up to the last n daily returns for a symbol, oldest first.
+// n is measured in recorded trading days rather than calendar days.
+func (phs *PriceHistoryService) GetDailyReturns(symbol string, n int) ([]DailyReturn, error) {
+	to := time.Now().AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -(n*7/5 + 10))
+
+	closes, err := phs.GetDailyCloses(symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var returns []DailyReturn
+	for i := 1; i < len(closes); i++ {
+		prev := closes[i-1].Price
+		if prev <= 0 {
+			continue
+		}
+		returns = append(returns, DailyReturn{
+			Date:   closes[i].Timestamp.UTC().Truncate(24 * time.Hour),
+			Return: closes[i].Price/prev - 1,
+		})
+	}
+
+	if len(returns) > n {
+		returns = returns[len(returns)-n:]
+	}
+	return returns, nil
+}
+
+// GetPreviousCloses retrieves the last recorded price before a time for each
+// symbol. Symbols with no earlier price are omitted.
+func (phs *PriceHistoryService) GetPreviousCloses(symbols []string, before time.Time) (map[string]float64, error) {
+	closes := make(map[string]float64)
+	if len(symbols) == 0 {
+		return closes, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT p.symbol, p.price
+		FROM price_history p
+		JOIN (
+			SELECT MAX(id) AS id
+			FROM price_history
+			WHERE symbol IN (%s) AND timestamp < ?
+			GROUP BY symbol
+		) l ON p.id = l.id
+	`, inPlaceholders(len(symbols)))
+
+	args := append(stringArgs(symbols), before)
+	rows, err := phs.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var symbol string
+		var price float64
+		if err := rows.Scan(&symbol, &price); err != nil {
+			return nil, err
+		}
+		closes[symbol] = price
+	}
+
+	return closes, rows.Err()
+}
@@ -0,0 +1,17 @@
+package models
+
+import "strings"
+
+// inPlaceholders returns a comma-separated list of n query placeholders for an IN clause
+func inPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// stringArgs converts a string slice to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
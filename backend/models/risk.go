@@ -0,0 +1,198 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// varHorizonDays is the holding period of the multi-day VaR and Expected Shortfall
+const varHorizonDays = 10
+
+// ErrInsufficientHistory is returned when there is not enough price history to
+// build historical scenarios
+var ErrInsufficientHistory = errors.New("insufficient price history")
+
+// VaRParams configures a historical-simulation VaR calculation
+type VaRParams struct {
+	LookbackDays     int
+	ConfidenceLevels []float64
+}
+
+// VaRResult holds VaR and Expected Shortfall at one confidence level. Losses are
+// reported as positive amounts.
+type VaRResult struct {
+	Confidence float64 `json:"confidence"`
+	VaR1Day    float64 `json:"var_1d"`
+	ES1Day     float64 `json:"es_1d"`
+	VaR10Day   float64 `json:"var_10d"`
+	ES10Day    float64 `json:"es_10d"`
+}
+
+// VaRReport represents the Value-at-Risk of a client's portfolio. Scenarios10Day
+// is the number of overlapping 10-day scenarios; 10-day figures are zero when
+// there are fewer than two.
+type VaRReport struct {
+	ClientID        int64       `json:"client_id"`
+	AsOf            time.Time   `json:"as_of"`
+	PortfolioValue  float64     `json:"portfolio_value"`
+	LookbackDays    int         `json:"lookback_days"`
+	Scenarios       int         `json:"scenarios"`
+	Scenarios10Day  int         `json:"scenarios_10d"`
+	Results         []VaRResult `json:"results"`
+	ExcludedSymbols []string    `json:"excluded_symbols"`
+}
+
+// RiskService calculates portfolio risk measures from stored price history
+type RiskService struct {
+	DB *sql.DB
+}
+
+// CalculateVaR computes historical-simulation VaR and Expected Shortfall for a
+// client's positions. Each scenario revalues today's holdings with the returns
+// observed on one historical day. 10-day scenarios revalue them with the
+// returns compounded over each run of 10 consecutive days, so they overlap by
+// nine days. Symbols without a current price or any
+// return history are excluded and listed in the report.
+func (rs *RiskService) CalculateVaR(clientID int64, positions []Position, quotes map[string]PriceQuote, params VaRParams) (*VaRReport, error) {
+	report := &VaRReport{
+		ClientID:        clientID,
+		AsOf:            time.Now(),
+		LookbackDays:    params.LookbackDays,
+		Results:         []VaRResult{},
+		ExcludedSymbols: []string{},
+	}
+
+	// Aggregate current exposure per symbol
+	exposures := make(map[string]float64)
+	for _, position := range positions {
+		quote, ok := quotes[position.Symbol]
+		if !ok {
+			report.ExcludedSymbols = appendUnique(report.ExcludedSymbols, position.Symbol)
+			continue
+		}
+		exposures[position.Symbol] += quote.Price.MulInt(position.Quantity).Float64()
+	}
+
+	// Load returns and find the days on which every symbol has a return
+	priceHistoryService := &PriceHistoryService{DB: rs.DB}
+	returnsBySymbol := make(map[string]map[time.Time]float64)
+	var commonDates map[time.Time]bool
+	for symbol := range exposures {
+		returns, err := priceHistoryService.GetDailyReturns(symbol, params.LookbackDays)
+		if err != nil {
+			return nil, err
+		}
+		if len(returns) == 0 {
+			report.ExcludedSymbols = appendUnique(report.ExcludedSymbols, symbol)
+			delete(exposures, symbol)
+			continue
+		}
+
+		byDate := make(map[time.Time]float64)
+		dates := make(map[time.Time]bool)
+		for _, r := range returns {
+			byDate[r.Date] = r.Return
+			if commonDates == nil || commonDates[r.Date] {
+				dates[r.Date] = true
+			}
+		}
+		returnsBySymbol[symbol] = byDate
+		commonDates = dates
+	}
+
+	for _, value := range exposures {
+		report.PortfolioValue += value
+	}
+
+	// Build one P&L scenario per common date, and one per run of consecutive
+	// common dates over the longer horizon
+	dates := make([]time.Time, 0, len(commonDates))
+	for date := range commonDates {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	pnl := make([]float64, len(dates))
+	for i, date := range dates {
+		for symbol, value := range exposures {
+			pnl[i] += value * returnsBySymbol[symbol][date]
+		}
+	}
+	pnl10 := overlappingPnL(dates, exposures, returnsBySymbol, varHorizonDays)
+	report.Scenarios = len(pnl)
+	report.Scenarios10Day = len(pnl10)
+	if len(pnl) < 2 {
+		return report, ErrInsufficientHistory
+	}
+
+	sort.Float64s(pnl)
+	sort.Float64s(pnl10)
+	for _, confidence := range params.ConfidenceLevels {
+		result := VaRResult{Confidence: confidence}
+		result.VaR1Day, result.ES1Day = HistoricalVaR(pnl, confidence)
+		if len(pnl10) >= 2 {
+			result.VaR10Day, result.ES10Day = HistoricalVaR(pnl10, confidence)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	sort.Strings(report.ExcludedSymbols)
+	return report, nil
+}
+
+// overlappingPnL returns the P&L of each window of horizon consecutive dates,
+// revaluing each exposure with its returns compounded over the window. Windows
+// start on every date, so consecutive windows share all but one day.
+func overlappingPnL(dates []time.Time, exposures map[string]float64, returns map[string]map[time.Time]float64, horizon int) []float64 {
+	var pnl []float64
+	for end := horizon; end <= len(dates); end++ {
+		var scenario float64
+		for symbol, value := range exposures {
+			growth := 1.0
+			for _, date := range dates[end-horizon : end] {
+				growth *= 1 + returns[symbol][date]
+			}
+			scenario += value * (growth - 1)
+		}
+		pnl = append(pnl, scenario)
+	}
+	return pnl
+}
+
+// HistoricalVaR returns the VaR and Expected Shortfall at the given confidence
+// from P&L scenarios sorted in ascending order. Both are reported as positive
+// losses and floored at zero.
+func HistoricalVaR(sortedPnL []float64, confidence float64) (float64, float64) {
+	n := len(sortedPnL)
+	if n == 0 {
+		return 0, 0
+	}
+
+	// Number of scenarios in the tail beyond the VaR level, at least one
+	tail := int(math.Floor((1 - confidence) * float64(n)))
+	if tail < 1 {
+		tail = 1
+	}
+
+	var tailSum float64
+	for _, v := range sortedPnL[:tail] {
+		tailSum += v
+	}
+
+	valueAtRisk := math.Max(0, -sortedPnL[tail-1])
+	expectedShortfall := math.Max(0, -tailSum/float64(tail))
+	return valueAtRisk, expectedShortfall
+}
+
+// appendUnique appends s to list if not already present
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOverlappingPnL(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC) }
+	dates := []time.Time{day(1), day(2), day(3), day(4)}
+	returns := map[string]map[time.Time]float64{
+		"AAPL": {day(1): 0.10, day(2): -0.10, day(3): 0.05, day(4): 0},
+		"MSFT": {day(1): 0, day(2): 0.02, day(3): -0.01, day(4): 0.03},
+	}
+	exposures := map[string]float64{"AAPL": 1000, "MSFT": -500}
+
+	tests := []struct {
+		horizon int
+		want    []float64
+	}{
+		{horizon: 1, want: []float64{100, -110, 55, -15}},
+		{horizon: 2, want: []float64{1000*(1.1*0.9-1) - 500*0.02, 1000*(0.9*1.05-1) - 500*(1.02*0.99-1), 1000*0.05 - 500*(0.99*1.03-1)}},
+		{horizon: 4, want: []float64{1000*(1.1*0.9*1.05-1) - 500*(1.02*0.99*1.03-1)}},
+		{horizon: 5, want: nil},
+	}
+
+	for _, tt := range tests {
+		got := overlappingPnL(dates, exposures, returns, tt.horizon)
+		if len(got) != len(tt.want) {
+			t.Fatalf("horizon %d: got %d scenarios, want %d", tt.horizon, len(got), len(tt.want))
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("horizon %d scenario %d = %v, want %v", tt.horizon, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import "fmt"
+
+// Role determines what a user is permitted to do
+type Role string
+
+// User roles
+const (
+	RoleClient         Role = "client"
+	RoleRiskOfficer    Role = "risk_officer"
+	RoleAdmin          Role = "admin"
+	RoleMarketDataFeed Role = "market_data_feed"
+)
+
+// Permission is an action a role may be granted
+type Permission string
+
+// Permissions
+const (
+	PermMarketDataRead         Permission = "market_data:read"
+	PermMarketDataWrite        Permission = "market_data:write"
+	PermPositionsRead          Permission = "positions:read"
+	PermPositionsWrite         Permission = "positions:write"
+	PermTradesRead             Permission = "trades:read"
+	PermTradesWrite            Permission = "trades:write"
+	PermMarginRead             Permission = "margin:read"
+	PermMarginWrite            Permission = "margin:write"
+	PermMarginCallsRead        Permission = "margin_calls:read"
+	PermMarginCallsAcknowledge Permission = "margin_calls:acknowledge"
+	PermMarginCallsManage      Permission = "margin_calls:manage"
+	PermMarginRulesManage      Permission = "margin_rules:manage"
+	PermInstrumentsManage      Permission = "instruments:manage"
+	PermCorporateActionsManage Permission = "corporate_actions:manage"
+	PermBorrowManage           Permission = "borrow:manage"
+	PermInterestManage         Permission = "interest:manage"
+	PermCashRead               Permission = "cash:read"
+	PermCashWrite              Permission = "cash:write"
+	PermPnLRead                Permission = "pnl:read"
+	PermRiskRead               Permission = "risk:read"
+	PermFirmRiskRead           Permission = "risk:firm_read"
+	PermStressRead             Permission = "stress:read"
+	PermStressManage           Permission = "stress:manage"
+	PermNotificationsManage    Permission = "notifications:manage"
+	PermUsersManage            Permission = "users:manage"
+)
+
+// rolePermissions lists the permissions granted to each role. Admins are
+// granted every permission.
+var rolePermissions = map[Role][]Permission{
+	RoleClient: {
+		PermMarketDataRead,
+		PermPositionsRead,
+		PermTradesRead,
+		PermMarginRead,
+		PermMarginCallsRead,
+		PermMarginCallsAcknowledge,
+		PermCashRead,
+		PermPnLRead,
+		PermRiskRead,
+	},
+	RoleRiskOfficer: {
+		PermMarketDataRead,
+		PermPositionsRead,
+		PermPositionsWrite,
+		PermTradesRead,
+		PermTradesWrite,
+		PermMarginRead,
+		PermMarginWrite,
+		PermMarginCallsRead,
+		PermMarginCallsAcknowledge,
+		PermMarginCallsManage,
+		PermMarginRulesManage,
+		PermInstrumentsManage,
+		PermCorporateActionsManage,
+		PermBorrowManage,
+		PermInterestManage,
+		PermCashRead,
+		PermCashWrite,
+		PermPnLRead,
+		PermRiskRead,
+		PermFirmRiskRead,
+		PermStressRead,
+		PermStressManage,
+		PermNotificationsManage,
+	},
+	RoleMarketDataFeed: {
+		PermMarketDataRead,
+		PermMarketDataWrite,
+	},
+}
+
+// ParseRole validates a role string
+func ParseRole(s string) (Role, error) {
+	switch Role(s) {
+	case RoleClient, RoleRiskOfficer, RoleAdmin, RoleMarketDataFeed:
+		return Role(s), nil
+	default:
+		return "", fmt.Errorf("unknown role: %s", s)
+	}
+}
+
+// Can reports whether the role is granted a permission
+func (r Role) Can(p Permission) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresClient reports whether users with the role must be linked to a client
+func (r Role) RequiresClient() bool {
+	return r == RoleClient
+}
@@ -0,0 +1,408 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ShockType identifies what a stress shock applies to
+type ShockType string
+
+// Stress shock types
+const (
+	ShockMarket ShockType = "market"
+	ShockSector ShockType = "sector"
+	ShockSymbol ShockType = "symbol"
+)
+
+// StressShock is a relative price move applied to the market, a sector or a symbol
+type StressShock struct {
+	ID     int64     `json:"id"`
+	Type   ShockType `json:"type"`
+	Target string    `json:"target"`
+	Shock  float64   `json:"shock"`
+}
+
+// StressScenario is a named set of price shocks
+type StressScenario struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Shocks      []StressShock `json:"shocks"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// Validate checks that the scenario is well formed
+func (s *StressScenario) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("scenario name is required")
+	}
+	if len(s.Shocks) == 0 {
+		return fmt.Errorf("scenario must have at least one shock")
+	}
+	for _, shock := range s.Shocks {
+		switch shock.Type {
+		case ShockMarket:
+		case ShockSector, ShockSymbol:
+			if shock.Target == "" {
+				return fmt.Errorf("%s shock requires a target", shock.Type)
+			}
+		default:
+			return fmt.Errorf("unknown shock type: %s", shock.Type)
+		}
+		if shock.Shock <= -1 {
+			return fmt.Errorf("shock must be greater than -100%%")
+		}
+	}
+	return nil
+}
+
+// ShockFor returns the shock applied to a symbol. The most specific shock wins:
+// a symbol shock overrides a sector shock, which overrides the market shock.
+func (s *StressScenario) ShockFor(symbol, sector string) float64 {
+	var market, sectorShock, symbolShock float64
+	var hasSector, hasSymbol bool
+	for _, shock := range s.Shocks {
+		switch shock.Type {
+		case ShockMarket:
+			market = shock.Shock
+		case ShockSector:
+			if sector != "" && shock.Target == sector {
+				sectorShock, hasSector = shock.Shock, true
+			}
+		case ShockSymbol:
+			if shock.Target == symbol {
+				symbolShock, hasSymbol = shock.Shock, true
+			}
+		}
+	}
+
+	switch {
+	case hasSymbol:
+		return symbolShock
+	case hasSector:
+		return sectorShock
+	default:
+		return market
+	}
+}
+
+// ClientStressResult is the effect of a scenario on one client
+type ClientStressResult struct {
+	ClientID      int64         `json:"client_id"`
+	Base          *MarginStatus `json:"base,omitempty"`
+	Stressed      *MarginStatus `json:"stressed,omitempty"`
+	PnL           Decimal       `json:"pnl"`
+	NewMarginCall bool          `json:"new_margin_call"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// StressRun is the result of running a scenario against every client
+type StressRun struct {
+	ID                int64                `json:"id"`
+	ScenarioID        int64                `json:"scenario_id"`
+	ScenarioName      string               `json:"scenario_name"`
+	RunAt             time.Time            `json:"run_at"`
+	ClientCount       int                  `json:"client_count"`
+	MarginCallCount   int                  `json:"margin_call_count"`
+	MarginCallClients []int64              `json:"margin_call_clients"`
+	Results           []ClientStressResult `json:"results"`
+}
+
+// StressService handles stress scenarios and runs them against client portfolios
+type StressService struct {
+	DB            *sql.DB
+	Pricing       PricingPolicy
+	Concentration ConcentrationPolicy
+}
+
+// ListScenarios retrieves all stress scenarios with their shocks
+func (ss *StressService) ListScenarios() ([]StressScenario, error) {
+	query := `
+		SELECT id, name, description, created_at, updated_at
+		FROM stress_scenarios
+		ORDER BY id
+	`
+
+	rows, err := ss.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scenarios := []StressScenario{}
+	for rows.Next() {
+		var s StressScenario
+		if err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range scenarios {
+		shocks, err := ss.getShocks(scenarios[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		scenarios[i].Shocks = shocks
+	}
+
+	return scenarios, nil
+}
+
+// GetScenario retrieves a stress scenario by ID
+func (ss *StressService) GetScenario(id int64) (*StressScenario, error) {
+	query := `
+		SELECT id, name, description, created_at, updated_at
+		FROM stress_scenarios
+		WHERE id = ?
+	`
+
+	var s StressScenario
+	err := ss.DB.QueryRow(query, id).Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Shocks, err = ss.getShocks(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// getShocks retrieves the shocks of a scenario
+func (ss *StressService) getShocks(scenarioID int64) ([]StressShock, error) {
+	query := `
+		SELECT id, shock_type, target, shock
+		FROM stress_scenario_shocks
+		WHERE scenario_id = ?
+		ORDER BY id
+	`
+
+	rows, err := ss.DB.Query(query, scenarioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shocks := []StressShock{}
+	for rows.Next() {
+		var shock StressShock
+		if err := rows.Scan(&shock.ID, &shock.Type, &shock.Target, &shock.Shock); err != nil {
+			return nil, err
+		}
+		shocks = append(shocks, shock)
+	}
+
+	return shocks, rows.Err()
+}
+
+// CreateScenario creates a new stress scenario with its shocks
+func (ss *StressService) CreateScenario(s *StressScenario) error {
+	tx, err := ss.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO stress_scenarios (name, description, created_at, updated_at)
+		VALUES (?, ?, NOW(), NOW())
+	`, s.Name, s.Description)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for i := range s.Shocks {
+		shock := &s.Shocks[i]
+		result, err := tx.Exec(`
+			INSERT INTO stress_scenario_shocks (scenario_id, shock_type, target, shock)
+			VALUES (?, ?, ?, ?)
+		`, id, shock.Type, shock.Target, shock.Shock)
+		if err != nil {
+			return err
+		}
+		if shock.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.ID = id
+	return nil
+}
+
+// DeleteScenario deletes a stress scenario and its shocks and runs
+func (ss *StressService) DeleteScenario(id int64) error {
+	_, err := ss.DB.Exec("DELETE FROM stress_scenarios WHERE id = ?", id)
+	return err
+}
+
+// RunScenario applies a scenario to every client with a margin account and
+// returns the base and post-shock margin status of each
+func (ss *StressService) RunScenario(scenario *StressScenario) (*StressRun, error) {
+	marginService := &MarginService{DB: ss.DB, Pricing: ss.Pricing, Concentration: ss.Concentration}
+	clientIDs, err := marginService.GetClientIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clients: %v", err)
+	}
+
+	run := &StressRun{
+		ScenarioID:        scenario.ID,
+		ScenarioName:      scenario.Name,
+		RunAt:             time.Now(),
+		ClientCount:       len(clientIDs),
+		MarginCallClients: []int64{},
+		Results:           []ClientStressResult{},
+	}
+
+	for _, clientID := range clientIDs {
+		result := ss.runClient(marginService, scenario, clientID)
+		if result.Stressed != nil && result.Stressed.MarginCall {
+			run.MarginCallCount++
+			run.MarginCallClients = append(run.MarginCallClients, clientID)
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return run, nil
+}
+
+// runClient applies a scenario to a single client's positions
+func (ss *StressService) runClient(marginService *MarginService, scenario *StressScenario, clientID int64) ClientStressResult {
+	result := ClientStressResult{ClientID: clientID}
+
+	positionService := &PositionService{DB: ss.DB}
+	positions, err := positionService.GetPositionsByClientID(clientID)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get positions: %v", err)
+		return result
+	}
+
+	var symbols []string
+	for _, position := range positions {
+		symbols = append(symbols, position.Symbol)
+	}
+
+	marketDataService := &MarketDataService{DB: ss.DB}
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, ss.Pricing.MaxPriceAge())
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get market prices: %v", err)
+		return result
+	}
+
+	instrumentService := &InstrumentService{DB: ss.DB}
+	sectors, err := instrumentService.GetSectors(symbols)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get sectors: %v", err)
+		return result
+	}
+
+	shocked := make(map[string]PriceQuote, len(quotes))
+	for symbol, quote := range quotes {
+		quote.Price = quote.Price.Mul(1 + scenario.ShockFor(symbol, sectors[symbol]))
+		shocked[symbol] = quote
+	}
+
+	result.Base, err = marginService.CalculateMarginStatus(clientID, positions, quotes)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to calculate margin status: %v", err)
+		return result
+	}
+	result.Stressed, err = marginService.CalculateMarginStatus(clientID, positions, shocked)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to calculate stressed margin status: %v", err)
+		return result
+	}
+
+	result.PnL = result.Stressed.PortfolioValue - result.Base.PortfolioValue
+	result.NewMarginCall = result.Stressed.MarginCall && !result.Base.MarginCall
+	return result
+}
+
+// SaveRun persists the results of a stress run
+func (ss *StressService) SaveRun(run *StressRun) error {
+	results, err := json.Marshal(run.Results)
+	if err != nil {
+		return err
+	}
+
+	result, err := ss.DB.Exec(`
+		INSERT INTO stress_runs (scenario_id, run_at, client_count, margin_call_count, results)
+		VALUES (?, ?, ?, ?, ?)
+	`, run.ScenarioID, run.RunAt, run.ClientCount, run.MarginCallCount, results)
+	if err != nil {
+		return err
+	}
+
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetRuns retrieves the most recent runs of a scenario, newest first
+func (ss *StressService) GetRuns(scenarioID int64, limit int) ([]StressRun, error) {
+	query := `
+		SELECT r.id, r.scenario_id, s.name, r.run_at, r.client_count, r.margin_call_count, r.results
+		FROM stress_runs r
+		JOIN stress_scenarios s ON s.id = r.scenario_id
+		WHERE r.scenario_id = ?
+		ORDER BY r.run_at DESC, r.id DESC
+		LIMIT ?
+	`
+
+	rows, err := ss.DB.Query(query, scenarioID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []StressRun{}
+	for rows.Next() {
+		var run StressRun
+		var results []byte
+		err := rows.Scan(
+			&run.ID,
+			&run.ScenarioID,
+			&run.ScenarioName,
+			&run.RunAt,
+			&run.ClientCount,
+			&run.MarginCallCount,
+			&results,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(results, &run.Results); err != nil {
+			return nil, err
+		}
+
+		run.MarginCallClients = []int64{}
+		for _, result := range run.Results {
+			if result.Stressed != nil && result.Stressed.MarginCall {
+				run.MarginCallClients = append(run.MarginCallClients, result.ClientID)
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
@@ -0,0 +1,443 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidLotSelection is returned when a specific-lot sell names a lot that
+// is not open or asks for more than the lot holds
+var ErrInvalidLotSelection = errors.New("invalid lot selection")
+
+// ReliefMethod selects which tax lots a sell closes
+type ReliefMethod string
+
+// Lot relief methods
+const (
+	ReliefFIFO        ReliefMethod = "fifo"
+	ReliefLIFO        ReliefMethod = "lifo"
+	ReliefAverageCost ReliefMethod = "average_cost"
+	ReliefSpecificLot ReliefMethod = "specific_lot"
+)
+
+// ParseReliefMethod validates a lot relief method string
+func ParseReliefMethod(s string) (ReliefMethod, error) {
+	switch ReliefMethod(s) {
+	case ReliefFIFO, ReliefLIFO, ReliefAverageCost, ReliefSpecificLot:
+		return ReliefMethod(s), nil
+	default:
+		return "", fmt.Errorf("unknown lot relief method: %s", s)
+	}
+}
+
+// LotSide is whether a lot is a long or a short holding
+type LotSide string
+
+// Lot sides
+const (
+	LotLong  LotSide = "long"
+	LotShort LotSide = "short"
+)
+
+// TaxLot is a quantity of a symbol opened at one price by a trade: a buy opens
+// a long lot at its cost, a short sale opens a short lot at its net proceeds.
+// Quantities are always positive.
+type TaxLot struct {
+	ID                int64      `json:"id"`
+	ClientID          int64      `json:"client_id"`
+	Symbol            string     `json:"symbol"`
+	Side              LotSide    `json:"side"`
+	TradeID           *int64     `json:"trade_id"`
+	OpenDate          time.Time  `json:"open_date"`
+	Quantity          int        `json:"quantity"`
+	RemainingQuantity int        `json:"remaining_quantity"`
+	CostPerShare      Decimal    `json:"cost_per_share"`
+	ClosedAt          *time.Time `json:"closed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// LotSelection names a lot and quantity to close under specific-lot relief
+type LotSelection struct {
+	LotID    int64 `json:"lot_id"`
+	Quantity int   `json:"quantity"`
+}
+
+// LotRelief is the part of a lot closed by a trade and the P&L realized on it.
+// For a long lot the proceeds come from the sell; for a short lot the cost is
+// that of the buy that covers it. Fractional shares paid out as cash in lieu by
+// a split are relieved by the split's ActionID rather than a trade, with a zero
+// Quantity and values per whole split share.
+type LotRelief struct {
+	ID               int64     `json:"id"`
+	LotID            int64     `json:"lot_id"`
+	TradeID          *int64    `json:"trade_id"`
+	ActionID         *int64    `json:"action_id,omitempty"`
+	ClientID         int64     `json:"client_id"`
+	Symbol           string    `json:"symbol"`
+	Quantity         int       `json:"quantity"`
+	CostPerShare     Decimal   `json:"cost_per_share"`
+	ProceedsPerShare Decimal   `json:"proceeds_per_share"`
+	RealizedPnL      Decimal   `json:"realized_pnl"`
+	OpenDate         time.Time `json:"open_date"`
+	CloseDate        time.Time `json:"close_date"`
+}
+
+// RelieveLots closes quantity shares of lots for a trade using the given method
+// and returns one relief per lot touched. lots must be the client's open lots
+// in the symbol on the opposite side to the trade, ordered oldest first; their
+// remaining quantities are reduced in place. Trade fees are charged pro rata,
+// reducing sell proceeds and adding to the cost of a cover, so that the
+// reliefs' proceeds or costs add up to the trade's net value.
+//
+// Average cost relieves lots oldest first at the average cost of all open lots,
+// and restates the remaining lots at that average so later trades see the same
+// cost.
+func RelieveLots(lots []TaxLot, t *Trade, method ReliefMethod, quantity int) ([]LotRelief, error) {
+	open, averageCost := LotsCostBasis(lots)
+	if quantity > open {
+		return nil, ErrInsufficientQuantity
+	}
+
+	tradePerShare := t.NetPricePerShare()
+
+	// Work out how much to take from each lot
+	take := make([]int, len(lots))
+	switch method {
+	case ReliefFIFO, ReliefAverageCost:
+		fill(take, lots, quantity, false)
+	case ReliefLIFO:
+		fill(take, lots, quantity, true)
+	case ReliefSpecificLot:
+		index := make(map[int64]int, len(lots))
+		for i, lot := range lots {
+			index[lot.ID] = i
+		}
+		for _, sel := range t.Lots {
+			i, ok := index[sel.LotID]
+			if !ok || take[i]+sel.Quantity > lots[i].RemainingQuantity {
+				return nil, ErrInvalidLotSelection
+			}
+			take[i] += sel.Quantity
+		}
+	default:
+		return nil, fmt.Errorf("unknown lot relief method: %s", method)
+	}
+
+	var reliefs []LotRelief
+	var relieved int
+	for i := range lots {
+		lot := &lots[i]
+		if method == ReliefAverageCost {
+			lot.CostPerShare = averageCost
+		}
+		if take[i] == 0 {
+			continue
+		}
+		lot.RemainingQuantity -= take[i]
+		tradeValue := t.NetValue(relieved+take[i]) - t.NetValue(relieved)
+		relieved += take[i]
+		lotCost := lot.CostPerShare
+		relief := LotRelief{
+			LotID:            lot.ID,
+			TradeID:          &t.ID,
+			ClientID:         t.ClientID,
+			Symbol:           t.Symbol,
+			Quantity:         take[i],
+			CostPerShare:     lotCost,
+			ProceedsPerShare: tradePerShare,
+			OpenDate:         lot.OpenDate,
+			CloseDate:        t.TradeDate,
+		}
+		relief.RealizedPnL = tradeValue - lotCost.MulInt(take[i])
+		if lot.Side == LotShort {
+			relief.CostPerShare, relief.ProceedsPerShare = tradePerShare, lotCost
+			relief.RealizedPnL = -relief.RealizedPnL
+		}
+		reliefs = append(reliefs, relief)
+	}
+
+	return reliefs, nil
+}
+
+// fill takes quantity from lots in order, or in reverse order for LIFO
+func fill(take []int, lots []TaxLot, quantity int, reverse bool) {
+	for n := 0; n < len(lots) && quantity > 0; n++ {
+		i := n
+		if reverse {
+			i = len(lots) - 1 - n
+		}
+		q := lots[i].RemainingQuantity
+		if q > quantity {
+			q = quantity
+		}
+		take[i] = q
+		quantity -= q
+	}
+}
+
+// LotsCostBasis returns the open quantity and average cost per share of lots,
+// which must all be on the same side
+func LotsCostBasis(lots []TaxLot) (int, Decimal) {
+	var quantity int
+	var cost Decimal
+	for _, lot := range lots {
+		quantity += lot.RemainingQuantity
+		cost += lot.CostPerShare.MulInt(lot.RemainingQuantity)
+	}
+	if quantity == 0 {
+		return 0, 0
+	}
+	return quantity, cost.Div(float64(quantity))
+}
+
+// TaxLotService handles database operations for tax lots and realized P&L
+type TaxLotService struct {
+	DB *sql.DB
+}
+
+// GetLots retrieves a client's lots, oldest first, optionally restricted to one
+// symbol and to lots that are still open
+func (tls *TaxLotService) GetLots(clientID int64, symbol string, openOnly bool) ([]TaxLot, error) {
+	query := `
+		SELECT id, client_id, symbol, side, trade_id, open_date, quantity, remaining_quantity, cost_per_share, closed_at, created_at
+		FROM tax_lots
+		WHERE client_id = ?
+	`
+	args := []interface{}{clientID}
+	if symbol != "" {
+		query += " AND symbol = ?"
+		args = append(args, symbol)
+	}
+	if openOnly {
+		query += " AND remaining_quantity > 0"
+	}
+	query += " ORDER BY symbol, open_date, id"
+
+	rows, err := tls.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []TaxLot{}
+	for rows.Next() {
+		lot, err := scanTaxLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, *lot)
+	}
+
+	return lots, rows.Err()
+}
+
+// GetReliefs retrieves a client's realized lot closures with a close date in
+// [from, to), oldest first, optionally restricted to one symbol
+func (tls *TaxLotService) GetReliefs(clientID int64, symbol string, from, to time.Time) ([]LotRelief, error) {
+	query := `
+		SELECT id, lot_id, trade_id, action_id, client_id, symbol, quantity, cost_per_share, proceeds_per_share, realized_pnl, open_date, close_date
+		FROM lot_reliefs
+		WHERE client_id = ? AND close_date >= ? AND close_date < ?
+	`
+	args := []interface{}{clientID, from, to}
+	if symbol != "" {
+		query += " AND symbol = ?"
+		args = append(args, symbol)
+	}
+	query += " ORDER BY close_date, id"
+
+	rows, err := tls.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reliefs := []LotRelief{}
+	for rows.Next() {
+		var r LotRelief
+		var tradeID, actionID sql.NullInt64
+		err := rows.Scan(
+			&r.ID,
+			&r.LotID,
+			&tradeID,
+			&actionID,
+			&r.ClientID,
+			&r.Symbol,
+			&r.Quantity,
+			&r.CostPerShare,
+			&r.ProceedsPerShare,
+			&r.RealizedPnL,
+			&r.OpenDate,
+			&r.CloseDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if tradeID.Valid {
+			r.TradeID = &tradeID.Int64
+		}
+		if actionID.Valid {
+			r.ActionID = &actionID.Int64
+		}
+		reliefs = append(reliefs, r)
+	}
+
+	return reliefs, rows.Err()
+}
+
+// scanTaxLot scans a tax_lots row
+func scanTaxLot(row rowScanner) (*TaxLot, error) {
+	var lot TaxLot
+	var tradeID sql.NullInt64
+	var closedAt sql.NullTime
+	err := row.Scan(
+		&lot.ID,
+		&lot.ClientID,
+		&lot.Symbol,
+		&lot.Side,
+		&tradeID,
+		&lot.OpenDate,
+		&lot.Quantity,
+		&lot.RemainingQuantity,
+		&lot.CostPerShare,
+		&closedAt,
+		&lot.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tradeID.Valid {
+		lot.TradeID = &tradeID.Int64
+	}
+	lot.ClosedAt = nullTimePtr(closedAt)
+	return &lot, nil
+}
+
+// lockOpenLots loads and locks a client's open lots in a symbol, oldest first
+func lockOpenLots(tx *sql.Tx, clientID int64, symbol string) ([]TaxLot, error) {
+	query := `
+		SELECT id, client_id, symbol, side, trade_id, open_date, quantity, remaining_quantity, cost_per_share, closed_at, created_at
+		FROM tax_lots
+		WHERE client_id = ? AND symbol = ? AND remaining_quantity > 0
+		ORDER BY open_date, id
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(query, clientID, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []TaxLot
+	for rows.Next() {
+		lot, err := scanTaxLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, *lot)
+	}
+
+	return lots, rows.Err()
+}
+
+// openLot inserts a lot of quantity shares opened by a trade: long for a buy,
+// short for a sell
+func openLot(tx *sql.Tx, t *Trade, quantity int) (*TaxLot, error) {
+	lot := &TaxLot{
+		ClientID:          t.ClientID,
+		Symbol:            t.Symbol,
+		Side:              LotLong,
+		TradeID:           &t.ID,
+		OpenDate:          t.TradeDate,
+		Quantity:          quantity,
+		RemainingQuantity: quantity,
+		CostPerShare:      t.NetPricePerShare(),
+	}
+	if t.Side == TradeSell {
+		lot.Side = LotShort
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO tax_lots (client_id, symbol, side, trade_id, open_date, quantity, remaining_quantity, cost_per_share, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`, lot.ClientID, lot.Symbol, lot.Side, t.ID, lot.OpenDate, lot.Quantity, lot.RemainingQuantity, lot.CostPerShare)
+	if err != nil {
+		return nil, err
+	}
+	if lot.ID, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+
+	return lot, nil
+}
+
+// saveLotReliefs writes the lots changed by a trade and records its reliefs
+func saveLotReliefs(tx *sql.Tx, lots []TaxLot, reliefs []LotRelief) error {
+	for _, lot := range lots {
+		_, err := tx.Exec(`
+			UPDATE tax_lots
+			SET remaining_quantity = ?, cost_per_share = ?,
+				closed_at = IF(? = 0, NOW(), NULL)
+			WHERE id = ?
+		`, lot.RemainingQuantity, lot.CostPerShare, lot.RemainingQuantity, lot.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range reliefs {
+		if err := insertLotRelief(tx, &reliefs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertLotRelief records a lot relief
+func insertLotRelief(tx *sql.Tx, r *LotRelief) error {
+	result, err := tx.Exec(`
+		INSERT INTO lot_reliefs (lot_id, trade_id, action_id, client_id, symbol, quantity, cost_per_share, proceeds_per_share, realized_pnl, open_date, close_date, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`, r.LotID, r.TradeID, r.ActionID, r.ClientID, r.Symbol, r.Quantity, r.CostPerShare, r.ProceedsPerShare, r.RealizedPnL, r.OpenDate, r.CloseDate)
+	if err != nil {
+		return err
+	}
+
+	r.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetRealizedBySymbol sums a client's realized P&L per symbol, over all time and
+// for lots closed on or after since
+func (tls *TaxLotService) GetRealizedBySymbol(clientID int64, since time.Time) (total, sinceTotal map[string]Decimal, err error) {
+	query := `
+		SELECT symbol, SUM(realized_pnl), SUM(IF(close_date >= ?, realized_pnl, 0))
+		FROM lot_reliefs
+		WHERE client_id = ?
+		GROUP BY symbol
+	`
+
+	rows, err := tls.DB.Query(query, since, clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	total = make(map[string]Decimal)
+	sinceTotal = make(map[string]Decimal)
+	for rows.Next() {
+		var symbol string
+		var all, recent Decimal
+		if err := rows.Scan(&symbol, &all, &recent); err != nil {
+			return nil, nil, err
+		}
+		total[symbol] = all
+		sinceTotal[symbol] = recent
+	}
+
+	return total, sinceTotal, rows.Err()
+}
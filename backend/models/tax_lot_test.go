@@ -0,0 +1,96 @@
+package models
+
+import "testing"
+
+func TestRelieveLots(t *testing.T) {
+	openLots := func() []TaxLot {
+		return []TaxLot{
+			{ID: 1, Side: LotLong, Quantity: 10, RemainingQuantity: 10, CostPerShare: NewDecimal(100)},
+			{ID: 2, Side: LotLong, Quantity: 10, RemainingQuantity: 10, CostPerShare: NewDecimal(200)},
+		}
+	}
+
+	tests := []struct {
+		method        ReliefMethod
+		wantLots      []int64
+		wantCost      []Decimal
+		wantPnL       Decimal
+		wantRemaining []int
+		wantBasis     Decimal
+	}{
+		{method: ReliefFIFO, wantLots: []int64{1, 2}, wantCost: []Decimal{NewDecimal(100), NewDecimal(200)}, wantPnL: NewDecimal(10*80 - 5*20), wantRemaining: []int{0, 5}, wantBasis: NewDecimal(200)},
+		{method: ReliefLIFO, wantLots: []int64{2, 1}, wantCost: []Decimal{NewDecimal(200), NewDecimal(100)}, wantPnL: NewDecimal(-10*20 + 5*80), wantRemaining: []int{5, 0}, wantBasis: NewDecimal(100)},
+		{method: ReliefAverageCost, wantLots: []int64{1, 2}, wantCost: []Decimal{NewDecimal(150), NewDecimal(150)}, wantPnL: NewDecimal(15 * 30), wantRemaining: []int{0, 5}, wantBasis: NewDecimal(150)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			lots := openLots()
+			trade := &Trade{ID: 7, Side: TradeSell, Quantity: 15, Price: NewDecimal(180)}
+			reliefs, err := RelieveLots(lots, trade, tt.method, 15)
+			if err != nil {
+				t.Fatalf("RelieveLots returned error: %v", err)
+			}
+
+			var pnl Decimal
+			byLot := make(map[int64]LotRelief)
+			for _, r := range reliefs {
+				pnl += r.RealizedPnL
+				byLot[r.LotID] = r
+			}
+			if pnl != tt.wantPnL {
+				t.Errorf("realized P&L = %v, want %v", pnl, tt.wantPnL)
+			}
+			for i, id := range tt.wantLots {
+				if r, ok := byLot[id]; !ok || r.CostPerShare != tt.wantCost[i] {
+					t.Errorf("relief of lot %d = %+v, want cost %v", id, r, tt.wantCost[i])
+				}
+			}
+			for i, lot := range lots {
+				if lot.RemainingQuantity != tt.wantRemaining[i] {
+					t.Errorf("lot %d remaining = %d, want %d", lot.ID, lot.RemainingQuantity, tt.wantRemaining[i])
+				}
+			}
+
+			// Relieved and remaining cost add up to the cost paid
+			var cost Decimal
+			for _, r := range reliefs {
+				cost += r.CostPerShare.MulInt(r.Quantity)
+			}
+			for _, lot := range lots {
+				cost += lot.CostPerShare.MulInt(lot.RemainingQuantity)
+			}
+			if cost != NewDecimal(3000) {
+				t.Errorf("relieved and remaining cost = %s, want 3000", cost)
+			}
+			if _, basis := LotsCostBasis(lots); basis != tt.wantBasis {
+				t.Errorf("remaining cost basis = %s, want %s", basis, tt.wantBasis)
+			}
+		})
+	}
+}
+
+func TestRelieveLotsSharesFees(t *testing.T) {
+	lots := []TaxLot{
+		{ID: 1, Side: LotLong, Quantity: 10, RemainingQuantity: 10, CostPerShare: NewDecimal(100)},
+		{ID: 2, Side: LotLong, Quantity: 10, RemainingQuantity: 10, CostPerShare: NewDecimal(100)},
+		{ID: 3, Side: LotLong, Quantity: 10, RemainingQuantity: 10, CostPerShare: NewDecimal(100)},
+	}
+	trade := &Trade{ID: 7, Side: TradeSell, Quantity: 30, Price: NewDecimal(180), Fees: NewDecimal(10)}
+	reliefs, err := RelieveLots(lots, trade, ReliefFIFO, 30)
+	if err != nil {
+		t.Fatalf("RelieveLots returned error: %v", err)
+	}
+
+	var proceeds, pnl Decimal
+	for _, r := range reliefs {
+		proceeds += r.CostPerShare.MulInt(r.Quantity) + r.RealizedPnL
+		pnl += r.RealizedPnL
+	}
+	if want := trade.NetValue(30); proceeds != want {
+		t.Errorf("relieved proceeds = %s, want the trade's net value %s", proceeds, want)
+	}
+	if want := NewDecimal(30*80 - 10); pnl != want {
+		t.Errorf("realized P&L = %s, want %s", pnl, want)
+	}
+}
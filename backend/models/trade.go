@@ -0,0 +1,441 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TradeSide is the direction of a trade
+type TradeSide string
+
+// Trade sides
+const (
+	TradeBuy  TradeSide = "buy"
+	TradeSell TradeSide = "sell"
+)
+
+// DefaultSettlementDays is the number of business days from trade date to settlement
+const DefaultSettlementDays = 1
+
+// ErrInsufficientQuantity is returned when a sell exceeds the quantity held and
+// short selling is not allowed
+var ErrInsufficientQuantity = errors.New("insufficient quantity to sell")
+
+// Trade represents an execution in a client's account
+type Trade struct {
+	ID             int64     `json:"id"`
+	ClientID       int64     `json:"client_id"`
+	Symbol         string    `json:"symbol"`
+	Side           TradeSide `json:"side"`
+	Quantity       int       `json:"quantity"`
+	Price          Decimal   `json:"price"`
+	Fees           Decimal   `json:"fees"`
+	TradeDate      time.Time `json:"trade_date"`
+	SettlementDate time.Time `json:"settlement_date"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// ReliefMethod and Lots choose the lots a trade closes: long lots for a
+	// sell, short lots for a buy. Reliefs holds the result when the trade is booked
+	ReliefMethod ReliefMethod   `json:"relief_method,omitempty"`
+	Lots         []LotSelection `json:"lots,omitempty"`
+	Reliefs      []LotRelief    `json:"reliefs,omitempty"`
+}
+
+// Validate checks that the trade is well formed
+func (t *Trade) Validate() error {
+	if t.ClientID == 0 {
+		return fmt.Errorf("client ID is required")
+	}
+	if t.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if t.Side != TradeBuy && t.Side != TradeSell {
+		return fmt.Errorf("side must be buy or sell")
+	}
+	if t.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if t.Price <= 0 {
+		return fmt.Errorf("price must be positive")
+	}
+	if t.Fees < 0 {
+		return fmt.Errorf("fees cannot be negative")
+	}
+	if !t.SettlementDate.IsZero() && t.SettlementDate.Before(t.TradeDate) {
+		return fmt.Errorf("settlement date cannot be before trade date")
+	}
+	if t.ReliefMethod != "" {
+		if _, err := ParseReliefMethod(string(t.ReliefMethod)); err != nil {
+			return err
+		}
+	}
+	if t.ReliefMethod == ReliefSpecificLot {
+		var selected int
+		for _, sel := range t.Lots {
+			if sel.Quantity <= 0 {
+				return fmt.Errorf("lot quantities must be positive")
+			}
+			selected += sel.Quantity
+		}
+		if selected != t.Quantity {
+			return fmt.Errorf("selected lots total %d, trade quantity is %d", selected, t.Quantity)
+		}
+	} else if len(t.Lots) > 0 {
+		return fmt.Errorf("lots can only be selected with the specific_lot relief method")
+	}
+	return nil
+}
+
+// AddBusinessDays returns the date n weekdays after d
+func AddBusinessDays(d time.Time, n int) time.Time {
+	for n > 0 {
+		d = d.AddDate(0, 0, 1)
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			n--
+		}
+	}
+	return d
+}
+
+// NetPricePerShare returns the trade price adjusted for fees: the cost per
+// share of a buy, or the proceeds per share of a sell
+func (t *Trade) NetPricePerShare() Decimal {
+	feesPerShare := t.Fees.Div(float64(t.Quantity))
+	if t.Side == TradeSell {
+		return t.Price - feesPerShare
+	}
+	return t.Price + feesPerShare
+}
+
+// NetValue returns the value of quantity shares of the trade adjusted for
+// their pro rata share of the fees: the cost of a buy, or the proceeds of a
+// sell. The net value of the whole trade includes the fees exactly.
+func (t *Trade) NetValue(quantity int) Decimal {
+	fees := t.Fees.Mul(float64(quantity) / float64(t.Quantity))
+	if t.Side == TradeSell {
+		return t.Price.MulInt(quantity) - fees
+	}
+	return t.Price.MulInt(quantity) + fees
+}
+
+// SignedQuantity returns the change in position from the trade: positive for
+// buys and negative for sells
+func (t *Trade) SignedQuantity() int {
+	if t.Side == TradeSell {
+		return -t.Quantity
+	}
+	return t.Quantity
+}
+
+// OpeningQuantity returns how many of quantity shares traded on side open or
+// add to a position of held shares rather than close it
+func OpeningQuantity(held int, side TradeSide, quantity int) int {
+	if side == TradeSell {
+		held = -held
+	}
+	if held >= 0 {
+		return quantity
+	}
+	return max(0, quantity+held)
+}
+
+// TradeService handles database operations for the trade ledger and keeps
+// positions and tax lots in step with it
+type TradeService struct {
+	DB *sql.DB
+	// ReliefMethod is used for trades that close lots without choosing a
+	// method; FIFO if empty
+	ReliefMethod ReliefMethod
+	// AllowShort lets sells beyond the quantity held open a short position
+	AllowShort bool
+}
+
+// RecordTrade appends a trade to the ledger and applies it to the client's
+// position and tax lots in the same transaction. A trade first closes lots on
+// the opposite side by its relief method, recording the realized P&L in
+// t.Reliefs, and any remaining quantity opens a new lot: a buy beyond a short
+// position goes long, and a sell beyond a long position goes short. The value
+// opened and closed is charged against the client's SMA, and the trade is
+// settled in the cash ledger, both converted into the account's base currency
+// at the latest FX rate. It returns ErrNoMarginAccount if the client has no
+// margin account, and otherwise the updated position, which has zero quantity
+// if the trade closed it.
+func (ts *TradeService) RecordTrade(t *Trade) (*Position, error) {
+	if t.TradeDate.IsZero() {
+		t.TradeDate = time.Now().Truncate(24 * time.Hour)
+	}
+	if t.SettlementDate.IsZero() {
+		t.SettlementDate = AddBusinessDays(t.TradeDate, DefaultSettlementDays)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	position, err := lockPosition(tx, t.ClientID, t.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	lots, err := lockOpenLots(tx, t.ClientID, t.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// Split the open lots into those the trade closes and those it adds to
+	closingSide := LotShort
+	if t.Side == TradeSell {
+		closingSide = LotLong
+	}
+	var closing, remaining []TaxLot
+	var closable int
+	for _, lot := range lots {
+		if lot.Side == closingSide {
+			closing = append(closing, lot)
+			closable += lot.RemainingQuantity
+		} else {
+			remaining = append(remaining, lot)
+		}
+	}
+
+	currency, fxRate, err := settlementRate(tx, t.ClientID, t.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	closeQuantity := t.Quantity
+	if closeQuantity > closable && t.ReliefMethod != ReliefSpecificLot {
+		closeQuantity = closable
+	}
+	openQuantity := t.Quantity - closeQuantity
+	if t.Side == TradeSell && openQuantity > 0 && !ts.AllowShort {
+		return nil, ErrInsufficientQuantity
+	}
+	if closeQuantity > 0 && t.ReliefMethod == "" {
+		t.ReliefMethod = ts.ReliefMethod
+		if t.ReliefMethod == "" {
+			t.ReliefMethod = ReliefFIFO
+		}
+	}
+
+	var reliefMethod sql.NullString
+	if t.ReliefMethod != "" {
+		reliefMethod = sql.NullString{String: string(t.ReliefMethod), Valid: true}
+	}
+	result, err := tx.Exec(`
+		INSERT INTO trades (client_id, symbol, side, quantity, price, fees, trade_date, settlement_date, relief_method, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`, t.ClientID, t.Symbol, t.Side, t.Quantity, t.Price, t.Fees, t.TradeDate, t.SettlementDate, reliefMethod)
+	if err != nil {
+		return nil, err
+	}
+	if t.ID, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+
+	if closeQuantity > 0 {
+		t.Reliefs, err = RelieveLots(closing, t, t.ReliefMethod, closeQuantity)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveLotReliefs(tx, closing, t.Reliefs); err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, closing...)
+	}
+	if openQuantity > 0 {
+		lot, err := openLot(tx, t, openQuantity)
+		if err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, *lot)
+	}
+
+	// The cost basis of what remains depends on which lots were closed
+	position.Quantity += t.SignedQuantity()
+	_, position.CostBasis = LotsCostBasis(remaining)
+
+	if err := savePosition(tx, position); err != nil {
+		return nil, err
+	}
+	if err := settleTrade(tx, t, closeQuantity, openQuantity, currency, fxRate); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return position, nil
+}
+
+// settleTrade charges a trade against the client's SMA and settles it in the
+// cash ledger, converted into the account's base currency at fxRate. The
+// proceeds of a short sale are held as short credit rather than paid in, and
+// covering a short first uses that credit.
+func settleTrade(tx *sql.Tx, t *Trade, closeQuantity, openQuantity int, currency string, fxRate float64) error {
+	if err := adjustSMA(tx, t.ClientID, t.Price.MulInt(closeQuantity).Mul(fxRate), t.Price.MulInt(openQuantity).Mul(fxRate)); err != nil {
+		return err
+	}
+
+	amount := t.NetValue(closeQuantity)
+	if t.Side == TradeBuy {
+		amount = -t.NetValue(t.Quantity)
+		for _, r := range t.Reliefs {
+			amount += r.ProceedsPerShare.MulInt(r.Quantity)
+		}
+	}
+	settlement := &CashTransaction{
+		ClientID:      t.ClientID,
+		Type:          CashTrade,
+		Amount:        amount.Mul(fxRate),
+		TradeID:       &t.ID,
+		Description:   fmt.Sprintf("%s %d %s @ %s", t.Side, t.Quantity, t.Symbol, t.Price),
+		EffectiveDate: t.SettlementDate,
+	}
+	if fxRate != 1 {
+		settlement.Description += fmt.Sprintf(" %s at %.6f", currency, fxRate)
+	}
+	return postCash(tx, settlement)
+}
+
+// settlementRate returns the currency a symbol is quoted in and the rate
+// converting it into a client's base currency
+func settlementRate(tx *sql.Tx, clientID int64, symbol string) (string, float64, error) {
+	var base string
+	err := tx.QueryRow("SELECT base_currency FROM margins WHERE client_id = ?", clientID).Scan(&base)
+	if err == sql.ErrNoRows {
+		return "", 0, ErrNoMarginAccount
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	currency, err := symbolCurrency(tx, symbol)
+	if err != nil {
+		return "", 0, err
+	}
+
+	rates, err := getFXRates(tx)
+	if err != nil {
+		return "", 0, err
+	}
+	rate, _, ok := rates.Convert(currency, base)
+	if !ok {
+		return "", 0, fmt.Errorf("%w: %s to %s", ErrNoFXRate, currency, base)
+	}
+	return currency, rate, nil
+}
+
+// GetTradesByClientID retrieves a client's trades, newest first, optionally
+// restricted to one symbol
+func (ts *TradeService) GetTradesByClientID(clientID int64, symbol string) ([]Trade, error) {
+	query := `
+		SELECT id, client_id, symbol, side, quantity, price, fees, trade_date, settlement_date, relief_method, created_at
+		FROM trades
+		WHERE client_id = ?
+	`
+	args := []interface{}{clientID}
+	if symbol != "" {
+		query += " AND symbol = ?"
+		args = append(args, symbol)
+	}
+	query += " ORDER BY trade_date DESC, id DESC"
+
+	rows, err := ts.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trades := []Trade{}
+	for rows.Next() {
+		var t Trade
+		var reliefMethod sql.NullString
+		err := rows.Scan(
+			&t.ID,
+			&t.ClientID,
+			&t.Symbol,
+			&t.Side,
+			&t.Quantity,
+			&t.Price,
+			&t.Fees,
+			&t.TradeDate,
+			&t.SettlementDate,
+			&reliefMethod,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		t.ReliefMethod = ReliefMethod(reliefMethod.String)
+		trades = append(trades, t)
+	}
+
+	return trades, rows.Err()
+}
+
+// lockPosition loads and locks a client's position in a symbol, returning an
+// empty unsaved position if there is none
+func lockPosition(tx *sql.Tx, clientID int64, symbol string) (*Position, error) {
+	query := `
+		SELECT id, client_id, symbol, quantity, cost_basis, created_at, updated_at
+		FROM positions
+		WHERE client_id = ? AND symbol = ?
+		FOR UPDATE
+	`
+
+	var p Position
+	err := tx.QueryRow(query, clientID, symbol).Scan(
+		&p.ID,
+		&p.ClientID,
+		&p.Symbol,
+		&p.Quantity,
+		&p.CostBasis,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return &Position{ClientID: clientID, Symbol: symbol}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// savePosition writes a position projected from the ledger, deleting it once
+// its quantity reaches zero
+func savePosition(tx *sql.Tx, p *Position) error {
+	switch {
+	case p.Quantity == 0 && p.ID == 0:
+		return nil
+	case p.Quantity == 0:
+		_, err := tx.Exec("DELETE FROM positions WHERE id = ?", p.ID)
+		return err
+	case p.ID == 0:
+		result, err := tx.Exec(`
+			INSERT INTO positions (client_id, symbol, quantity, cost_basis, created_at, updated_at)
+			VALUES (?, ?, ?, ?, NOW(), NOW())
+		`, p.ClientID, p.Symbol, p.Quantity, p.CostBasis)
+		if err != nil {
+			return err
+		}
+		p.ID, err = result.LastInsertId()
+		return err
+	default:
+		_, err := tx.Exec(`
+			UPDATE positions
+			SET quantity = ?, cost_basis = ?, updated_at = NOW()
+			WHERE id = ?
+		`, p.Quantity, p.CostBasis, p.ID)
+		return err
+	}
+}
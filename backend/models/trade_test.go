@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestOpeningQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		held     int
+		side     TradeSide
+		quantity int
+		want     int
+	}{
+		{name: "buy opens a position", held: 0, side: TradeBuy, quantity: 100, want: 100},
+		{name: "buy adds to a long", held: 50, side: TradeBuy, quantity: 100, want: 100},
+		{name: "sell closes a long", held: 150, side: TradeSell, quantity: 100, want: 0},
+		{name: "sell beyond a long opens a short", held: 100, side: TradeSell, quantity: 300, want: 200},
+		{name: "sell adds to a short", held: -100, side: TradeSell, quantity: 100, want: 100},
+		{name: "buy covers a short", held: -100, side: TradeBuy, quantity: 100, want: 0},
+		{name: "buy beyond a short opens a long", held: -100, side: TradeBuy, quantity: 150, want: 50},
+	}
+	for _, tt := range tests {
+		if got := OpeningQuantity(tt.held, tt.side, tt.quantity); got != tt.want {
+			t.Errorf("%s: OpeningQuantity(%d, %s, %d) = %d, want %d", tt.name, tt.held, tt.side, tt.quantity, got, tt.want)
+		}
+	}
+}
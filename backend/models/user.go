@@ -0,0 +1,213 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User represents a login to the system. Client users have a ClientID and may
+// only access that client's data; staff users have no ClientID.
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role"`
+	ClientID     *int64    `json:"client_id"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Validate checks that the user's role and client link are consistent
+func (u *User) Validate() error {
+	if u.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if _, err := ParseRole(string(u.Role)); err != nil {
+		return err
+	}
+	if u.Role.RequiresClient() && u.ClientID == nil {
+		return fmt.Errorf("%s users must be linked to a client", u.Role)
+	}
+	if !u.Role.RequiresClient() && u.ClientID != nil {
+		return fmt.Errorf("%s users cannot be linked to a client", u.Role)
+	}
+	return nil
+}
+
+// ErrDuplicateUsername is returned when creating a user whose username is taken
+var ErrDuplicateUsername = errors.New("username already exists")
+
+// UserService handles database operations for users
+type UserService struct {
+	DB *sql.DB
+}
+
+// userColumns is the column list scanned by scanUser
+const userColumns = `id, username, password_hash, role, client_id, active, created_at, updated_at`
+
+// scanUser scans a user selected with userColumns
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	var clientID sql.NullInt64
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &clientID, &u.Active, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if clientID.Valid {
+		u.ClientID = &clientID.Int64
+	}
+	return &u, nil
+}
+
+// ListUsers retrieves all users
+func (us *UserService) ListUsers() ([]User, error) {
+	rows, err := us.DB.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+
+	return users, rows.Err()
+}
+
+// GetUserByID retrieves a user by ID
+func (us *UserService) GetUserByID(id int64) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+
+	u, err := scanUser(us.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+// GetUserByUsername retrieves a user by username
+func (us *UserService) GetUserByUsername(username string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ?`
+
+	u, err := scanUser(us.DB.QueryRow(query, username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+// Authenticate checks a username and password, returning the user if they match
+// an active account and nil otherwise
+func (us *UserService) Authenticate(username, password string) (*User, error) {
+	u, err := us.GetUserByUsername(username)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	if !u.Active {
+		return nil, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return nil, nil
+	}
+	return u, nil
+}
+
+// CreateUser creates a new user with the given password
+func (us *UserService) CreateUser(u *User, password string) error {
+	existing, err := us.GetUserByUsername(u.Username)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrDuplicateUsername
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO users (username, password_hash, role, client_id, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+	`
+
+	result, err := us.DB.Exec(query, u.Username, hash, u.Role, u.ClientID, u.Active)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	u.ID = id
+	u.PasswordHash = hash
+	return nil
+}
+
+// UpdateRole assigns a role, and the client link it requires, to a user
+func (us *UserService) UpdateRole(id int64, role Role, clientID *int64) error {
+	query := `
+		UPDATE users
+		SET role = ?, client_id = ?, updated_at = NOW()
+		WHERE id = ?
+	`
+
+	_, err := us.DB.Exec(query, role, clientID, id)
+	return err
+}
+
+// SetActive enables or disables a user's login
+func (us *UserService) SetActive(id int64, active bool) error {
+	_, err := us.DB.Exec("UPDATE users SET active = ?, updated_at = NOW() WHERE id = ?", active, id)
+	return err
+}
+
+// SetPassword replaces a user's password
+func (us *UserService) SetPassword(id int64, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = us.DB.Exec("UPDATE users SET password_hash = ?, updated_at = NOW() WHERE id = ?", hash, id)
+	return err
+}
+
+// DeleteUser deletes a user
+func (us *UserService) DeleteUser(id int64) error {
+	_, err := us.DB.Exec("DELETE FROM users WHERE id = ?", id)
+	return err
+}
+
+// ValidatePassword checks that a password is acceptable
+func ValidatePassword(password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("password must be at least 8 characters")
+	}
+	return nil
+}
+
+// HashPassword hashes a password for storage
+func HashPassword(password string) (string, error) {
+	if err := ValidatePassword(password); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
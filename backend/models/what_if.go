@@ -0,0 +1,209 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNoTradePrice is returned when a hypothetical trade has no price and its
+// symbol has no quote
+var ErrNoTradePrice = errors.New("no price for hypothetical trade")
+
+// HypotheticalTrade is a trade evaluated against a client's margin without
+// being booked. Price defaults to the symbol's latest quote.
+type HypotheticalTrade struct {
+	Symbol   string    `json:"symbol"`
+	Side     TradeSide `json:"side"`
+	Quantity int       `json:"quantity"`
+	Price    Decimal   `json:"price"`
+}
+
+// Validate checks that the hypothetical trade is well formed
+func (t *HypotheticalTrade) Validate() error {
+	if t.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if t.Side != TradeBuy && t.Side != TradeSell {
+		return fmt.Errorf("side must be buy or sell")
+	}
+	if t.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if t.Price < 0 {
+		return fmt.Errorf("price cannot be negative")
+	}
+	return nil
+}
+
+// InitialMarginCheck compares a client's margin status before and after a
+// change to their positions. BuyingPowerConsumed is how much the change reduces
+// the client's buying power. The change passes if it leaves equity covering the
+// initial requirement or does not consume buying power.
+type InitialMarginCheck struct {
+	Current             *MarginStatus `json:"current"`
+	Projected           *MarginStatus `json:"projected"`
+	BuyingPowerConsumed Decimal       `json:"buying_power_consumed"`
+	Pass                bool          `json:"pass"`
+}
+
+// Projection is a client's positions after a hypothetical change. Quotes
+// value the projected positions. LoanChange is the change in loan amount, and
+// ClosedValue and OpenedValue are the market value of the positions the change
+// closed and opened, which are charged against the SMA, all in the account's
+// base currency.
+type Projection struct {
+	Positions   []Position
+	Quotes      map[string]PriceQuote
+	LoanChange  Decimal
+	ClosedValue Decimal
+	OpenedValue Decimal
+}
+
+// ProjectTrades applies hypothetical trades to positions held in an account in
+// currency, filling in missing prices from quotes. Symbols without a quote are
+// valued at the trade price in DefaultCurrency. Buys are paid for from the
+// margin loan and the proceeds of sells paid into it, converted at the latest
+// FX rates, except that cash from covering a short is first taken from the
+// short sale proceeds, and the proceeds of opening a short are held as short
+// credit.
+func ProjectTrades(positions []Position, trades []HypotheticalTrade, quotes map[string]PriceQuote, rates FXRates, currency string) (*Projection, error) {
+	projected := make([]Position, len(positions))
+	copy(projected, positions)
+	projection := &Projection{Quotes: make(map[string]PriceQuote, len(quotes))}
+	for symbol, quote := range quotes {
+		projection.Quotes[symbol] = quote
+	}
+
+	for i := range trades {
+		t := &trades[i]
+		if t.Price == 0 {
+			quote, ok := quotes[t.Symbol]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNoTradePrice, t.Symbol)
+			}
+			t.Price = quote.Price
+		}
+		if _, ok := projection.Quotes[t.Symbol]; !ok {
+			projection.Quotes[t.Symbol] = PriceQuote{Symbol: t.Symbol, Price: t.Price, Currency: DefaultCurrency, Quality: PriceQualityFresh}
+		}
+		fxRate, _, ok := rates.Convert(projection.Quotes[t.Symbol].Currency, currency)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s to %s", ErrNoFXRate, projection.Quotes[t.Symbol].Currency, currency)
+		}
+
+		index := -1
+		for j := range projected {
+			if projected[j].Symbol == t.Symbol {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			projected = append(projected, Position{Symbol: t.Symbol})
+			index = len(projected) - 1
+		}
+		projection.apply(&projected[index], t, fxRate)
+	}
+
+	// Positions closed out by the trades are no longer held
+	for _, p := range projected {
+		if p.Quantity != 0 {
+			projection.Positions = append(projection.Positions, p)
+		}
+	}
+	return projection, nil
+}
+
+// apply applies a trade to a position, converting the cash it moves at fxRate.
+// Closing part of a position keeps its cost basis; adding to it averages the
+// cost basis, and reversing it starts a new one at the trade price.
+func (pr *Projection) apply(p *Position, t *HypotheticalTrade, fxRate float64) {
+	shares := t.Quantity
+	if t.Side == TradeSell {
+		shares = -shares
+	}
+
+	// The part of the trade that closes the existing position
+	closing := 0
+	if p.Quantity > 0 && shares < 0 {
+		closing = -min(p.Quantity, -shares)
+	} else if p.Quantity < 0 && shares > 0 {
+		closing = min(-p.Quantity, shares)
+	}
+	opening := shares - closing
+
+	if t.Side == TradeBuy {
+		pr.LoanChange += (t.Price.MulInt(t.Quantity) - p.CostBasis.MulInt(closing)).Mul(fxRate)
+	} else {
+		pr.LoanChange += t.Price.MulInt(closing).Mul(fxRate)
+	}
+	pr.ClosedValue += t.Price.MulInt(abs(closing)).Mul(fxRate)
+	pr.OpenedValue += t.Price.MulInt(abs(opening)).Mul(fxRate)
+
+	p.Quantity += closing
+	if opening != 0 {
+		if p.Quantity == 0 {
+			p.CostBasis = t.Price
+		} else {
+			p.CostBasis = (p.CostBasis.MulInt(p.Quantity) + t.Price.MulInt(opening)).Div(float64(p.Quantity + opening))
+		}
+		p.Quantity += opening
+	}
+}
+
+// EvaluateTrades projects hypothetical trades onto a client's positions and
+// checks the result against the account's initial margin
+func (ms *MarginService) EvaluateTrades(clientID int64, positions []Position, quotes map[string]PriceQuote, trades []HypotheticalTrade) (*InitialMarginCheck, error) {
+	margin, err := ms.GetMarginByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if margin == nil {
+		return nil, sql.ErrNoRows
+	}
+	fxService := &FXService{DB: ms.DB}
+	rates, err := fxService.GetRates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FX rates: %v", err)
+	}
+
+	projection, err := ProjectTrades(positions, trades, quotes, rates, margin.BaseCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return ms.CheckInitialMargin(clientID, positions, quotes, projection)
+}
+
+// CheckInitialMargin compares a client's current positions with a projection
+// of them
+func (ms *MarginService) CheckInitialMargin(clientID int64, positions []Position, quotes map[string]PriceQuote, projection *Projection) (*InitialMarginCheck, error) {
+	margin, err := ms.GetMarginByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if margin == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	current, err := ms.calculateStatus(margin, positions, quotes)
+	if err != nil {
+		return nil, err
+	}
+
+	projectedMargin := *margin
+	projectedMargin.LoanAmount += projection.LoanChange
+	projectedMargin.SMA += (projection.ClosedValue - projection.OpenedValue).Mul(margin.InitialMargin)
+	after, err := ms.calculateStatus(&projectedMargin, projection.Positions, projection.Quotes)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &InitialMarginCheck{
+		Current:             current,
+		Projected:           after,
+		BuyingPowerConsumed: current.BuyingPower - after.BuyingPower,
+	}
+	check.Pass = after.InitialExcess >= 0 || check.BuyingPowerConsumed <= 0
+	return check, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/minirisk/models"
+)
+
+// BorrowFeeService accrues borrow fees on short positions once a day
+type BorrowFeeService struct {
+	DB          *sql.DB
+	DefaultRate float64
+}
+
+// NewBorrowFeeService creates a new BorrowFeeService instance
+func NewBorrowFeeService(db *sql.DB) *BorrowFeeService {
+	return &BorrowFeeService{DB: db}
+}
+
+// AccrueFees charges borrow fees on every short position for each day up to
+// today that has not been charged yet. A client whose fees cannot be accrued
+// is logged and skipped so that it does not hold back the others.
+func (bfs *BorrowFeeService) AccrueFees() error {
+	borrowService := &models.BorrowService{DB: bfs.DB, DefaultRate: bfs.DefaultRate}
+	clients, err := borrowService.GetShortClients()
+	if err != nil {
+		return fmt.Errorf("failed to get clients with short positions: %v", err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	var total int
+	for _, clientID := range clients {
+		count, err := borrowService.AccrueClientFees(clientID, today)
+		total += count
+		if err != nil {
+			fmt.Printf("Failed to accrue borrow fees for client %d: %v\n", clientID, err)
+		}
+	}
+	if total > 0 {
+		fmt.Printf("Accrued %d borrow fees on short positions through %s\n", total, today.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// StartAccruals begins accruing borrow fees on a schedule. Each position is
+// charged at most once a day however often this runs.
+func (bfs *BorrowFeeService) StartAccruals(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := bfs.AccrueFees(); err != nil {
+				fmt.Printf("Error accruing borrow fees: %v\n", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/minirisk/models"
+)
+
+// CorporateActionProcessor applies corporate actions on their ex-dates and pays
+// cash dividends on their pay dates
+type CorporateActionProcessor struct {
+	DB *sql.DB
+}
+
+// NewCorporateActionProcessor creates a new CorporateActionProcessor instance
+func NewCorporateActionProcessor(db *sql.DB) *CorporateActionProcessor {
+	return &CorporateActionProcessor{DB: db}
+}
+
+// ProcessActions applies the pending actions that have gone ex, oldest first,
+// then pays the dividends that have fallen due. An action that fails is marked
+// with its error and skipped, to be tried again on the next run.
+func (cp *CorporateActionProcessor) ProcessActions() error {
+	actionService := &models.CorporateActionService{DB: cp.DB}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	due, err := actionService.GetDueActionIDs(today)
+	if err != nil {
+		return fmt.Errorf("failed to get due corporate actions: %v", err)
+	}
+	var applied int
+	for _, id := range due {
+		if _, err := actionService.Apply(id); err != nil {
+			fmt.Printf("Failed to apply corporate action %d: %v\n", id, err)
+			cp.recordFailure(actionService, id, err)
+			continue
+		}
+		applied++
+	}
+
+	payable, err := actionService.GetPayableDividendIDs(today)
+	if err != nil {
+		return fmt.Errorf("failed to get payable dividends: %v", err)
+	}
+	var paid int
+	for _, id := range payable {
+		if _, err := actionService.Pay(id); err != nil {
+			fmt.Printf("Failed to pay dividend %d: %v\n", id, err)
+			cp.recordFailure(actionService, id, err)
+			continue
+		}
+		paid++
+	}
+
+	if applied > 0 || paid > 0 {
+		fmt.Printf("Applied %d corporate actions and paid %d dividends for %s\n", applied, paid, today.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// recordFailure marks an action with the error it failed with
+func (cp *CorporateActionProcessor) recordFailure(actionService *models.CorporateActionService, id int64, cause error) {
+	if err := actionService.RecordFailure(id, cause); err != nil {
+		fmt.Printf("Failed to record failure of corporate action %d: %v\n", id, err)
+	}
+}
+
+// StartProcessing begins processing corporate actions on a schedule. Each
+// action is applied and paid once however often this runs.
+func (cp *CorporateActionProcessor) StartProcessing(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := cp.ProcessActions(); err != nil {
+				fmt.Printf("Error processing corporate actions: %v\n", err)
+			}
+		}
+	}()
+}
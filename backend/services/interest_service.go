@@ -0,0 +1,64 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/minirisk/models"
+)
+
+// InterestAccrualService accrues interest on margin loans once a day and
+// capitalizes it at month end
+type InterestAccrualService struct {
+	DB *sql.DB
+}
+
+// NewInterestAccrualService creates a new InterestAccrualService instance
+func NewInterestAccrualService(db *sql.DB) *InterestAccrualService {
+	return &InterestAccrualService{DB: db}
+}
+
+// AccrueInterest accrues interest on every margin loan for each day up to
+// today that has not been accrued yet, then capitalizes the interest of
+// previous months. A client that fails is logged and left for the next run.
+func (ias *InterestAccrualService) AccrueInterest() error {
+	interestService := &models.InterestService{DB: ias.DB}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	accrued, err := interestService.AccrueInterest(today)
+	if err != nil {
+		return err
+	}
+	for clientID, accrueErr := range accrued.Failures {
+		fmt.Printf("Failed to accrue interest for client %d: %v\n", clientID, accrueErr)
+	}
+	if accrued.Count > 0 {
+		fmt.Printf("Accrued %d days of interest on margin loans through %s\n", accrued.Count, today.Format("2006-01-02"))
+	}
+
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	capitalized, err := interestService.Capitalize(monthStart)
+	if err != nil {
+		return err
+	}
+	for clientID, capitalizeErr := range capitalized.Failures {
+		fmt.Printf("Failed to capitalize interest for client %d: %v\n", clientID, capitalizeErr)
+	}
+	if capitalized.Count > 0 {
+		fmt.Printf("Capitalized interest for %d clients before %s\n", capitalized.Count, monthStart.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// StartAccruals begins accruing interest on a schedule. Each loan is charged at
+// most once a day however often this runs.
+func (ias *InterestAccrualService) StartAccruals(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := ias.AccrueInterest(); err != nil {
+				fmt.Printf("Error accruing interest: %v\n", err)
+			}
+		}
+	}()
+}
@@ -8,17 +8,28 @@ import (
 	"github.com/minirisk/models"
 )
 
-// MarginAlertService handles margin calculations and alerts
+// MarginAlertService handles margin calculations and the margin call lifecycle
 type MarginAlertService struct {
-	DB *sql.DB
+	DB            *sql.DB
+	Pricing       models.PricingPolicy
+	Concentration models.ConcentrationPolicy
+	DuePeriod     time.Duration
+	Notifications *NotificationDispatcher
+
+	// PlanOnEscalation plans a liquidation by LiquidationPreferences when a
+	// margin call escalates, storing it and adding its orders to the alert
+	PlanOnEscalation       bool
+	LiquidationPreferences []models.LiquidationPreference
 }
 
 // NewMarginAlertService creates a new MarginAlertService instance
 func NewMarginAlertService(db *sql.DB) *MarginAlertService {
-	return &MarginAlertService{DB: db}
+	return &MarginAlertService{DB: db, DuePeriod: 72 * time.Hour}
 }
 
-// CheckMarginStatus checks margin status for all clients and sends alerts if needed
+// CheckMarginStatus checks margin status for all clients, issuing, escalating
+// and resolving margin calls as needed and keeping each client's SMA up with
+// their excess equity
 func (mas *MarginAlertService) CheckMarginStatus() error {
 	// Get all clients with positions
 	clients, err := mas.getClientsWithPositions()
@@ -27,6 +38,8 @@ func (mas *MarginAlertService) CheckMarginStatus() error {
 	}
 
 	// Check margin status for each client
+	marginCallService := &models.MarginCallService{DB: mas.DB}
+	marginService := &models.MarginService{DB: mas.DB}
 	for _, clientID := range clients {
 		status, err := mas.calculateClientMarginStatus(clientID)
 		if err != nil {
@@ -34,12 +47,59 @@ func (mas *MarginAlertService) CheckMarginStatus() error {
 			continue
 		}
 
-		// Send alert if margin call is needed
-		if status.MarginCall {
-			if err := mas.sendMarginCallAlert(clientID, status); err != nil {
-				fmt.Printf("Failed to send margin call alert for client %d: %v\n", clientID, err)
+		if err := marginService.RaiseSMA(clientID, status.SMA); err != nil {
+			fmt.Printf("Failed to update SMA for client %d: %v\n", clientID, err)
+		}
+
+		if err := mas.processMarginCall(marginCallService, clientID, status); err != nil {
+			fmt.Printf("Failed to process margin call for client %d: %v\n", clientID, err)
+		}
+	}
+
+	return nil
+}
+
+// processMarginCall advances a client's margin call from their current margin
+// status. A call is issued only once per shortfall; alerts are sent when a call
+// is issued or escalated, not on every check.
+func (mas *MarginAlertService) processMarginCall(marginCallService *models.MarginCallService, clientID int64, status *models.MarginStatus) error {
+	call, err := marginCallService.GetOpenMarginCall(clientID)
+	if err != nil {
+		return err
+	}
+
+	// No open call: issue one if the client is in shortfall
+	if call == nil {
+		if !status.MarginCall {
+			return nil
+		}
+		call, err = marginCallService.IssueMarginCall(clientID, status.MarginShortfall.Round(status.BaseCurrency), time.Now().Add(mas.DuePeriod))
+		if err != nil {
+			return err
+		}
+		return mas.sendMarginCallAlert(call, status, nil)
+	}
+
+	// Open call but the shortfall has been cured
+	if !status.MarginCall {
+		_, err := marginCallService.Resolve(call.ID, "Margin shortfall cured")
+		return err
+	}
+
+	// Open call past its due date
+	if call.IsOverdue(time.Now()) && call.Status != models.MarginCallEscalated {
+		call, err = marginCallService.Escalate(call.ID, "Margin call past due")
+		if err != nil {
+			return err
+		}
+
+		var plan *models.LiquidationPlan
+		if mas.PlanOnEscalation {
+			if plan, err = mas.planLiquidation(call); err != nil {
+				fmt.Printf("Failed to plan liquidation for margin call %d: %v\n", call.ID, err)
 			}
 		}
+		return mas.sendMarginCallAlert(call, status, plan)
 	}
 
 	return nil
@@ -68,11 +128,44 @@ func (mas *MarginAlertService) getClientsWithPositions() ([]int64, error) {
 
 // calculateClientMarginStatus calculates margin status for a specific client
 func (mas *MarginAlertService) calculateClientMarginStatus(clientID int64) (*models.MarginStatus, error) {
+	positions, quotes, err := mas.loadPortfolio(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate margin status
+	marginService := &models.MarginService{DB: mas.DB, Pricing: mas.Pricing, Concentration: mas.Concentration}
+	return marginService.CalculateMarginStatus(clientID, positions, quotes)
+}
+
+// planLiquidation plans and stores a liquidation curing an escalated margin call
+func (mas *MarginAlertService) planLiquidation(call *models.MarginCall) (*models.LiquidationPlan, error) {
+	positions, quotes, err := mas.loadPortfolio(call.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	marginService := &models.MarginService{DB: mas.DB, Pricing: mas.Pricing, Concentration: mas.Concentration}
+	plan, err := marginService.PlanLiquidation(call.ClientID, positions, quotes, mas.LiquidationPreferences)
+	if err != nil {
+		return nil, err
+	}
+	plan.MarginCallID = &call.ID
+
+	planService := &models.LiquidationPlanService{DB: mas.DB}
+	if err := planService.SavePlan(plan); err != nil {
+		return nil, fmt.Errorf("failed to save liquidation plan: %v", err)
+	}
+	return plan, nil
+}
+
+// loadPortfolio retrieves a client's positions and their current market prices
+func (mas *MarginAlertService) loadPortfolio(clientID int64) ([]models.Position, map[string]models.PriceQuote, error) {
 	// Get client's positions
 	positionService := &models.PositionService{DB: mas.DB}
 	positions, err := positionService.GetPositionsByClientID(clientID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get positions: %v", err)
+		return nil, nil, fmt.Errorf("failed to get positions: %v", err)
 	}
 
 	// Get current market prices
@@ -82,26 +175,24 @@ func (mas *MarginAlertService) calculateClientMarginStatus(clientID int64) (*mod
 	}
 
 	marketDataService := &models.MarketDataService{DB: mas.DB}
-	marketPrices, err := marketDataService.GetMarketDataForSymbols(symbols)
+	quotes, err := marketDataService.GetQuotesForSymbols(symbols, mas.Pricing.MaxPriceAge())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get market prices: %v", err)
+		return nil, nil, fmt.Errorf("failed to get market prices: %v", err)
 	}
-
-	// Calculate margin status
-	marginService := &models.MarginService{DB: mas.DB}
-	return marginService.CalculateMarginStatus(clientID, positions, marketPrices)
+	return positions, quotes, nil
 }
 
-// sendMarginCallAlert sends a margin call alert for a client
-func (mas *MarginAlertService) sendMarginCallAlert(clientID int64, status *models.MarginStatus) error {
-	// In a real implementation, this would send an email, SMS, or other notification
-	// For now, we'll just log the alert
-	fmt.Printf("MARGIN CALL ALERT - Client ID: %d\n", clientID)
-	fmt.Printf("Portfolio Value: $%.2f\n", status.PortfolioValue)
-	fmt.Printf("Net Equity: $%.2f\n", status.NetEquity)
-	fmt.Printf("Margin Shortfall: $%.2f\n", status.MarginShortfall)
-	fmt.Printf("Time: %s\n", time.Now().Format(time.RFC3339))
-	return nil
+// sendMarginCallAlert sends an alert for a newly issued or escalated margin
+// call, with the orders of its liquidation plan if there is one
+func (mas *MarginAlertService) sendMarginCallAlert(call *models.MarginCall, status *models.MarginStatus, plan *models.LiquidationPlan) error {
+	alert := NewMarginCallAlert(call, status)
+	if plan != nil {
+		alert.LiquidationOrders = plan.Orders
+	}
+	if mas.Notifications == nil {
+		return (&LogNotifier{}).Notify(alert)
+	}
+	return mas.Notifications.Dispatch(alert)
 }
 
 // StartMarginMonitoring begins the margin monitoring process
@@ -2,32 +2,46 @@ package services
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"math"
 	"time"
 
+	"github.com/minirisk/config"
 	"github.com/minirisk/models"
 )
 
 // MarketDataUpdater handles fetching and updating market data
 type MarketDataUpdater struct {
 	DB             *sql.DB
-	APIKey         string
-	APIURL         string
+	Provider       QuoteProvider
 	UpdateInterval time.Duration
+	// FXProvider fetches FX rates as currency pair symbols; nil leaves them to
+	// be set through the API
+	FXProvider QuoteProvider
+	// FXMaxMove is the largest relative change a fetched FX rate may make
+	FXMaxMove float64
 }
 
-// NewMarketDataUpdater creates a new MarketDataUpdater instance
-func NewMarketDataUpdater(db *sql.DB) *MarketDataUpdater {
-	return &MarketDataUpdater{
+// NewMarketDataUpdater creates a new MarketDataUpdater using the quote and FX
+// providers selected in cfg
+func NewMarketDataUpdater(db *sql.DB, cfg config.MarketConfig) (*MarketDataUpdater, error) {
+	provider, err := NewQuoteProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	updater := &MarketDataUpdater{
 		DB:             db,
-		APIKey:         os.Getenv("MARKET_DATA_API_KEY"),
-		APIURL:         os.Getenv("MARKET_DATA_API_URL"),
-		UpdateInterval: time.Duration(getEnvInt("MARKET_DATA_UPDATE_INTERVAL", 60)) * time.Second,
+		Provider:       provider,
+		UpdateInterval: cfg.UpdateInterval,
+		FXMaxMove:      cfg.FXMaxMove,
+	}
+	if cfg.FXProvider != "" {
+		if updater.FXProvider, err = NewQuoteProvider(cfg.FXSource()); err != nil {
+			return nil, err
+		}
 	}
+	return updater, nil
 }
 
 // Start begins the market data update process
@@ -51,14 +65,19 @@ func (mdu *MarketDataUpdater) UpdateMarketData() error {
 	}
 
 	// Fetch current prices for all symbols
-	prices, err := mdu.fetchMarketPrices(symbols)
+	result, err := mdu.Provider.FetchQuotes(symbols)
 	if err != nil {
-		return fmt.Errorf("failed to fetch market prices: %v", err)
+		return fmt.Errorf("failed to fetch market prices from %s provider: %v", mdu.Provider.Name(), err)
+	}
+
+	// A failed symbol keeps its last stored price; it must not hold back the others
+	for symbol, fetchErr := range result.Failures {
+		fmt.Printf("Failed to fetch price for %s: %v\n", symbol, fetchErr)
 	}
 
 	// Update market data in database
 	marketDataService := &models.MarketDataService{DB: mdu.DB}
-	for symbol, price := range prices {
+	for symbol, price := range result.Prices {
 		marketData := &models.MarketData{
 			Symbol:       symbol,
 			CurrentPrice: price,
@@ -68,6 +87,76 @@ func (mdu *MarketDataUpdater) UpdateMarketData() error {
 		}
 	}
 
+	return mdu.UpdateFXRates()
+}
+
+// UpdateFXRates fetches the rate against models.DefaultCurrency of every
+// currency symbols are quoted in or accounts are held in. Rates are requested
+// from the FX provider as six-letter currency pair symbols such as EURUSD. A
+// rate that is not positive or moves more than FXMaxMove from the stored rate
+// is rejected, keeping the stored rate.
+func (mdu *MarketDataUpdater) UpdateFXRates() error {
+	if mdu.FXProvider == nil {
+		return nil
+	}
+
+	fxService := &models.FXService{DB: mdu.DB}
+	currencies, err := fxService.GetCurrencies()
+	if err != nil {
+		return fmt.Errorf("failed to get currencies: %v", err)
+	}
+	if len(currencies) == 0 {
+		return nil
+	}
+
+	var pairs []string
+	for _, currency := range currencies {
+		pairs = append(pairs, currency+models.DefaultCurrency)
+	}
+
+	previous, err := fxService.GetRates()
+	if err != nil {
+		return fmt.Errorf("failed to get FX rates: %v", err)
+	}
+	result, err := mdu.FXProvider.FetchQuotes(pairs)
+	if err != nil {
+		return fmt.Errorf("failed to fetch FX rates from %s provider: %v", mdu.FXProvider.Name(), err)
+	}
+	for pair, fetchErr := range result.Failures {
+		fmt.Printf("Failed to fetch FX rate for %s: %v\n", pair, fetchErr)
+	}
+
+	for pair, rate := range result.Prices {
+		from, to, err := models.ParseCurrencyPair(pair)
+		if err != nil {
+			fmt.Printf("Failed to update FX rate for %s: %v\n", pair, err)
+			continue
+		}
+		if err := checkFXRate(previous, from, to, rate, mdu.FXMaxMove); err != nil {
+			fmt.Printf("Rejected FX rate for %s: %v\n", pair, err)
+			continue
+		}
+		if err := fxService.UpdateRate(from, to, rate); err != nil {
+			fmt.Printf("Failed to update FX rate for %s: %v\n", pair, err)
+		}
+	}
+
+	return nil
+}
+
+// checkFXRate checks that a fetched rate is positive and within maxMove of the
+// stored rate of the pair, if there is one
+func checkFXRate(previous models.FXRates, from, to string, rate, maxMove float64) error {
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return fmt.Errorf("invalid rate %v", rate)
+	}
+	stored, ok := previous[from+to]
+	if !ok || stored.Rate <= 0 {
+		return nil
+	}
+	if move := math.Abs(rate/stored.Rate - 1); move > maxMove {
+		return fmt.Errorf("rate %v moves %.1f%% from %v, more than %.1f%%", rate, move*100, stored.Rate, maxMove*100)
+	}
 	return nil
 }
 
@@ -91,45 +180,3 @@ func (mdu *MarketDataUpdater) getTrackedSymbols() ([]string, error) {
 
 	return symbols, nil
 }
-
-// fetchMarketPrices retrieves current prices from the market data API
-func (mdu *MarketDataUpdater) fetchMarketPrices(symbols []string) (map[string]float64, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	prices := make(map[string]float64)
-
-	for _, symbol := range symbols {
-		url := fmt.Sprintf("%s/quote/%s?apikey=%s", mdu.APIURL, symbol, mdu.APIKey)
-		resp, err := client.Get(url)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch price for %s: %v", symbol, err)
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response for %s: %v", symbol, err)
-		}
-
-		var result struct {
-			Price float64 `json:"price"`
-		}
-		if err := json.Unmarshal(body, &result); err != nil {
-			return nil, fmt.Errorf("failed to parse response for %s: %v", symbol, err)
-		}
-
-		prices[symbol] = result.Price
-	}
-
-	return prices, nil
-}
-
-// getEnvInt gets an environment variable as an integer with a default value
-func getEnvInt(key string, defaultValue int) int {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	var result int
-	fmt.Sscanf(value, "%d", &result)
-	return result
-}
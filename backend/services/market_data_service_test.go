@@ -0,0 +1,36 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/minirisk/models"
+)
+
+func TestCheckFXRate(t *testing.T) {
+	previous := models.FXRates{"EURUSD": {FromCurrency: "EUR", ToCurrency: "USD", Rate: 1.10}}
+
+	tests := []struct {
+		name    string
+		from    string
+		rate    float64
+		wantErr bool
+	}{
+		{name: "small move", from: "EUR", rate: 1.12},
+		{name: "zero", from: "EUR", rate: 0, wantErr: true},
+		{name: "negative", from: "EUR", rate: -1.1, wantErr: true},
+		{name: "not a number", from: "EUR", rate: math.NaN(), wantErr: true},
+		{name: "equity price instead of a rate", from: "EUR", rate: 187.5, wantErr: true},
+		{name: "large fall", from: "EUR", rate: 0.5, wantErr: true},
+		{name: "no stored rate", from: "GBP", rate: 1.27},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFXRate(previous, tt.from, "USD", tt.rate, 0.2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+)
+
+// MarginCallAlert is the payload delivered to notifiers when a margin call is
+// issued or escalated
+type MarginCallAlert struct {
+	ClientID          int64                     `json:"client_id"`
+	MarginCallID      int64                     `json:"margin_call_id"`
+	Status            models.MarginCallStatus   `json:"status"`
+	Currency          string                    `json:"currency"`
+	AmountDue         models.Decimal            `json:"amount_due"`
+	DueAt             time.Time                 `json:"due_at"`
+	PortfolioValue    models.Decimal            `json:"portfolio_value"`
+	NetEquity         models.Decimal            `json:"net_equity"`
+	MarginShortfall   models.Decimal            `json:"margin_shortfall"`
+	StalePositions    int                       `json:"stale_positions"`
+	UnpricedPositions int                       `json:"unpriced_positions"`
+	LiquidationOrders []models.LiquidationOrder `json:"liquidation_orders,omitempty"`
+	Time              time.Time                 `json:"time"`
+}
+
+// NewMarginCallAlert builds an alert from a margin call and the status that triggered it
+func NewMarginCallAlert(call *models.MarginCall, status *models.MarginStatus) *MarginCallAlert {
+	return &MarginCallAlert{
+		ClientID:          call.ClientID,
+		MarginCallID:      call.ID,
+		Status:            call.Status,
+		Currency:          status.BaseCurrency,
+		AmountDue:         call.AmountOutstanding(),
+		DueAt:             call.DueAt,
+		PortfolioValue:    status.PortfolioValue,
+		NetEquity:         status.NetEquity,
+		MarginShortfall:   status.MarginShortfall,
+		StalePositions:    len(status.StalePositions),
+		UnpricedPositions: len(status.UnpricedPositions),
+		Time:              time.Now(),
+	}
+}
+
+// Subject returns a one-line summary of the alert
+func (a *MarginCallAlert) Subject() string {
+	return fmt.Sprintf("Margin call %d %s - Client %d", a.MarginCallID, a.Status, a.ClientID)
+}
+
+// Text returns a plain-text description of the alert
+func (a *MarginCallAlert) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "MARGIN CALL ALERT - Client ID: %d\n", a.ClientID)
+	fmt.Fprintf(&b, "Margin Call ID: %d (%s)\n", a.MarginCallID, a.Status)
+	fmt.Fprintf(&b, "Portfolio Value: %s\n", a.PortfolioValue.Format(a.Currency))
+	fmt.Fprintf(&b, "Net Equity: %s\n", a.NetEquity.Format(a.Currency))
+	fmt.Fprintf(&b, "Margin Shortfall: %s\n", a.MarginShortfall.Format(a.Currency))
+	if a.StalePositions > 0 || a.UnpricedPositions > 0 {
+		fmt.Fprintf(&b, "Stale/Unpriced Positions: %d/%d\n", a.StalePositions, a.UnpricedPositions)
+	}
+	fmt.Fprintf(&b, "Amount Due: %s by %s\n", a.AmountDue.Format(a.Currency), a.DueAt.Format(time.RFC3339))
+	if len(a.LiquidationOrders) > 0 {
+		fmt.Fprintf(&b, "Suggested Liquidation:\n")
+		for _, order := range a.LiquidationOrders {
+			fmt.Fprintf(&b, "  %s %d %s @ %s\n", order.Side, order.Quantity, order.Symbol, order.Price.Format(order.Currency))
+		}
+	}
+	fmt.Fprintf(&b, "Time: %s\n", a.Time.Format(time.RFC3339))
+	return b.String()
+}
+
+// Notifier delivers margin call alerts to a destination
+type Notifier interface {
+	// Name returns a short description of the destination
+	Name() string
+	// Notify delivers an alert
+	Notify(alert *MarginCallAlert) error
+}
+
+// LogNotifier writes alerts to stdout
+type LogNotifier struct{}
+
+// Name returns the notifier description
+func (n *LogNotifier) Name() string {
+	return "log"
+}
+
+// Notify prints the alert
+func (n *LogNotifier) Notify(alert *MarginCallAlert) error {
+	fmt.Print(alert.Text())
+	return nil
+}
+
+// EmailNotifier sends alerts by SMTP. Authentication is skipped when Username is
+// empty, e.g. for a local SMTP stand-in.
+type EmailNotifier struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Name returns the notifier description
+func (n *EmailNotifier) Name() string {
+	return "email:" + strings.Join(n.To, ",")
+}
+
+// Notify sends the alert as a plain-text email
+func (n *EmailNotifier) Notify(alert *MarginCallAlert) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		host, _, err := net.SplitHostPort(n.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %v", err)
+		}
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", alert.Subject())
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(alert.Text(), "\n", "\r\n"))
+
+	if err := smtp.SendMail(n.Addr, auth, n.From, n.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// WebhookNotifier posts alerts as JSON to an HTTP endpoint. When Secret is set
+// each request carries an X-Minirisk-Signature header of
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)) along with the
+// X-Minirisk-Timestamp used. Network errors, 429 and 5xx responses are retried
+// with exponential backoff. Redirects are not followed, so a webhook cannot be
+// bounced to a host that was not allowed.
+type WebhookNotifier struct {
+	URL        string
+	Secret     string
+	Client     *http.Client
+	MaxRetries int
+	Backoff    time.Duration
+
+	// sleep waits between attempts; nil uses time.Sleep
+	sleep func(time.Duration)
+}
+
+// NewWebhookNotifier creates a new WebhookNotifier instance
+func NewWebhookNotifier(url, secret string, maxRetries int) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Secret: secret,
+		Client: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		MaxRetries: maxRetries,
+		Backoff:    time.Second,
+	}
+}
+
+// Name returns the notifier description
+func (n *WebhookNotifier) Name() string {
+	return "webhook:" + n.URL
+}
+
+// Notify posts the alert, retrying transient failures
+func (n *WebhookNotifier) Notify(alert *MarginCallAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	sleep := n.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= n.MaxRetries; attempt++ {
+		if attempt > 0 {
+			sleep(n.Backoff * time.Duration(1<<(attempt-1)))
+		}
+
+		retry, err := n.post(body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+
+	return fmt.Errorf("failed to deliver webhook: %v", lastErr)
+}
+
+// post sends a single webhook request and reports whether a failure is retryable
+func (n *WebhookNotifier) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Minirisk-Timestamp", timestamp)
+		req.Header.Set("X-Minirisk-Signature", "sha256="+SignWebhook(n.Secret, timestamp, body))
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// SignWebhook returns the hex HMAC-SHA256 signature of a webhook body
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fileNotifierMu serializes writes from all FileNotifiers
+var fileNotifierMu sync.Mutex
+
+// FileNotifier appends alerts to a file as JSON lines
+type FileNotifier struct {
+	Path string
+}
+
+// Name returns the notifier description
+func (n *FileNotifier) Name() string {
+	return "file:" + n.Path
+}
+
+// Notify appends the alert to the file
+func (n *FileNotifier) Notify(alert *MarginCallAlert) error {
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	fileNotifierMu.Lock()
+	defer fileNotifierMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(n.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create alert directory: %v", err)
+	}
+	file, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open alert file: %v", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(line)
+	return err
+}
+
+// alertQueueSize is the number of alerts that can wait for delivery
+const alertQueueSize = 256
+
+// NotificationDispatcher routes alerts to the globally configured notifiers and
+// to the channels stored for each client. Once StartDelivery has been called,
+// alerts are queued and delivered in the background.
+type NotificationDispatcher struct {
+	DB     *sql.DB
+	Global []Notifier
+	Config config.NotificationConfig
+
+	queue chan *MarginCallAlert
+}
+
+// NewNotificationDispatcher creates a dispatcher with the global notifiers from cfg.
+// Alerts are always written to stdout.
+func NewNotificationDispatcher(db *sql.DB, cfg config.NotificationConfig) *NotificationDispatcher {
+	global := []Notifier{&LogNotifier{}}
+	if len(cfg.EmailTo) > 0 {
+		global = append(global, newEmailNotifier(cfg, cfg.EmailTo))
+	}
+	if cfg.WebhookURL != "" {
+		global = append(global, NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret, cfg.WebhookRetries))
+	}
+	if cfg.FilePath != "" {
+		global = append(global, &FileNotifier{Path: cfg.FilePath})
+	}
+
+	return &NotificationDispatcher{DB: db, Global: global, Config: cfg}
+}
+
+// StartDelivery starts the workers that deliver queued alerts
+func (nd *NotificationDispatcher) StartDelivery(workers int) {
+	nd.queue = make(chan *MarginCallAlert, alertQueueSize)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for alert := range nd.queue {
+				if err := nd.Deliver(alert); err != nil {
+					fmt.Printf("Error delivering alert for margin call %d: %v\n", alert.MarginCallID, err)
+				}
+			}
+		}()
+	}
+}
+
+// Dispatch queues an alert for delivery, or delivers it straight away if
+// delivery has not been started. It only fails if the queue is full.
+func (nd *NotificationDispatcher) Dispatch(alert *MarginCallAlert) error {
+	if nd.queue == nil {
+		return nd.Deliver(alert)
+	}
+
+	select {
+	case nd.queue <- alert:
+		return nil
+	default:
+		return fmt.Errorf("alert queue is full, dropped alert for margin call %d", alert.MarginCallID)
+	}
+}
+
+// Deliver delivers an alert to every notifier for the alert's client. A failing
+// notifier does not prevent delivery to the others; all failures are returned.
+func (nd *NotificationDispatcher) Deliver(alert *MarginCallAlert) error {
+	notifiers := append([]Notifier{}, nd.Global...)
+
+	if nd.DB != nil {
+		channelService := &models.NotificationChannelService{DB: nd.DB}
+		channels, err := channelService.GetChannelsForClient(alert.ClientID)
+		if err != nil {
+			return fmt.Errorf("failed to get notification channels: %v", err)
+		}
+		for _, channel := range channels {
+			notifier, err := nd.notifierForChannel(channel)
+			if err != nil {
+				fmt.Printf("Skipping notification channel %d: %v\n", channel.ID, err)
+				continue
+			}
+			notifiers = append(notifiers, notifier)
+		}
+	}
+
+	var errs []error
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(alert); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", notifier.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// notifierForChannel creates the notifier for a stored channel. Targets are
+// checked against the delivery policy again, since it may have been tightened
+// since the channel was stored.
+func (nd *NotificationDispatcher) notifierForChannel(channel models.NotificationChannel) (Notifier, error) {
+	switch channel.Type {
+	case models.ChannelEmail:
+		if nd.Config.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP is not configured")
+		}
+		return newEmailNotifier(nd.Config, splitAddresses(channel.Target)), nil
+	case models.ChannelWebhook:
+		if err := models.ValidateWebhookURL(channel.Target, nd.Config); err != nil {
+			return nil, err
+		}
+		return NewWebhookNotifier(channel.Target, channel.Secret, nd.Config.WebhookRetries), nil
+	case models.ChannelFile:
+		path, err := models.ChannelFilePath(channel.Target, nd.Config)
+		if err != nil {
+			return nil, err
+		}
+		return &FileNotifier{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown channel type: %s", channel.Type)
+	}
+}
+
+// newEmailNotifier creates an EmailNotifier using the configured SMTP server
+func newEmailNotifier(cfg config.NotificationConfig, to []string) *EmailNotifier {
+	return &EmailNotifier{
+		Addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+		To:       to,
+	}
+}
+
+// splitAddresses splits a comma-separated list of email addresses
+func splitAddresses(s string) []string {
+	var addresses []string
+	for _, address := range strings.Split(s, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
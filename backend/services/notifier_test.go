@@ -0,0 +1,389 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minirisk/config"
+	"github.com/minirisk/models"
+)
+
+// testAlert returns an alert for margin call 7 of client 42
+func testAlert() *MarginCallAlert {
+	return &MarginCallAlert{
+		ClientID:        42,
+		MarginCallID:    7,
+		Status:          models.MarginCallIssued,
+		Currency:        "USD",
+		AmountDue:       models.NewDecimal(1250.5),
+		MarginShortfall: models.NewDecimal(1250.5),
+		DueAt:           time.Date(2024, 3, 1, 16, 0, 0, 0, time.UTC),
+		Time:            time.Date(2024, 2, 28, 16, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookNotifierRetriesWithBackoff(t *testing.T) {
+	const backoff = 100 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantErr      bool
+		wantAttempts int
+		wantSleeps   []time.Duration
+	}{
+		{
+			name:         "delivered first time",
+			statuses:     []int{200},
+			maxRetries:   3,
+			wantAttempts: 1,
+		},
+		{
+			name:         "server errors are retried with doubling backoff",
+			statuses:     []int{500, 503, 204},
+			maxRetries:   3,
+			wantAttempts: 3,
+			wantSleeps:   []time.Duration{backoff, 2 * backoff},
+		},
+		{
+			name:         "rate limiting is retried",
+			statuses:     []int{429, 200},
+			maxRetries:   3,
+			wantAttempts: 2,
+			wantSleeps:   []time.Duration{backoff},
+		},
+		{
+			name:         "client errors are not retried",
+			statuses:     []int{400},
+			maxRetries:   3,
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "redirects are not followed",
+			statuses:     []int{302},
+			maxRetries:   3,
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "gives up after max retries",
+			statuses:     []int{500, 500, 500, 500, 500},
+			maxRetries:   2,
+			wantErr:      true,
+			wantAttempts: 3,
+			wantSleeps:   []time.Duration{backoff, 2 * backoff},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := tt.statuses[attempts]
+				attempts++
+				mu.Unlock()
+
+				if status == http.StatusFound {
+					w.Header().Set("Location", "http://127.0.0.1:1/internal")
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			var sleeps []time.Duration
+			notifier := NewWebhookNotifier(server.URL, "", tt.maxRetries)
+			notifier.Backoff = backoff
+			notifier.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			err := notifier.Notify(testAlert())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if len(sleeps) != len(tt.wantSleeps) {
+				t.Fatalf("sleeps = %v, want %v", sleeps, tt.wantSleeps)
+			}
+			for i := range sleeps {
+				if sleeps[i] != tt.wantSleeps[i] {
+					t.Errorf("sleep %d = %v, want %v", i, sleeps[i], tt.wantSleeps[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierSignsBody(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer server.Close()
+
+	if err := NewWebhookNotifier(server.URL, "s3cret", 0).Notify(testAlert()); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+
+	r := <-received
+	timestamp := r.Header.Get("X-Minirisk-Timestamp")
+	want := "sha256=" + SignWebhook("s3cret", timestamp, body)
+	if got := r.Header.Get("X-Minirisk-Signature"); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+
+	var alert MarginCallAlert
+	if err := json.Unmarshal(body, &alert); err != nil {
+		t.Fatalf("body is not an alert: %v", err)
+	}
+	if alert.MarginCallID != 7 || alert.AmountDue != models.NewDecimal(1250.5) {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+}
+
+// smtpStandIn is a minimal SMTP server that records the messages it receives
+type smtpStandIn struct {
+	listener net.Listener
+	messages chan string
+}
+
+// newSMTPStandIn starts an SMTP stand-in on a local port
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{listener: listener, messages: make(chan string, 1)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.messages <- data.String()
+			reply("250 queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestEmailNotifierSendsToSMTPStandIn(t *testing.T) {
+	server := newSMTPStandIn(t)
+	notifier := &EmailNotifier{
+		Addr: server.listener.Addr().String(),
+		From: "alerts@minirisk.local",
+		To:   []string{"ops@example.com", "risk@example.com"},
+	}
+
+	if err := notifier.Notify(testAlert()); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+
+	message := <-server.messages
+	for _, want := range []string{
+		"From: alerts@minirisk.local",
+		"To: ops@example.com, risk@example.com",
+		"Subject: Margin call 7 issued - Client 42",
+		"Amount Due: 1250.50 USD",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message missing %q:\n%s", want, message)
+		}
+	}
+}
+
+func TestEmailNotifierReportsUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	notifier := &EmailNotifier{Addr: addr, From: "alerts@minirisk.local", To: []string{"ops@example.com"}}
+	if err := notifier.Notify(testAlert()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestFileNotifierAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts", "client-42.jsonl")
+	notifier := &FileNotifier{Path: path}
+	for i := 0; i < 2; i++ {
+		if err := notifier.Notify(testAlert()); err != nil {
+			t.Fatalf("Notify returned error: %v", err)
+		}
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	for _, line := range lines {
+		var alert MarginCallAlert
+		if err := json.Unmarshal([]byte(line), &alert); err != nil {
+			t.Errorf("line is not an alert: %v", err)
+		}
+	}
+}
+
+// recordingNotifier records the alerts it is sent, failing if err is set
+type recordingNotifier struct {
+	alerts chan *MarginCallAlert
+	err    error
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Notify(alert *MarginCallAlert) error {
+	n.alerts <- alert
+	return n.err
+}
+
+func TestNotificationDispatcherDeliversInBackground(t *testing.T) {
+	block := make(chan struct{})
+	slow := notifierFunc(func(*MarginCallAlert) error {
+		<-block
+		return nil
+	})
+	recorder := &recordingNotifier{alerts: make(chan *MarginCallAlert, 1)}
+
+	dispatcher := &NotificationDispatcher{Global: []Notifier{slow, recorder}}
+	dispatcher.StartDelivery(1)
+
+	done := make(chan error, 1)
+	go func() { done <- dispatcher.Dispatch(testAlert()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Dispatch returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Dispatch waited for delivery")
+	}
+
+	close(block)
+	select {
+	case alert := <-recorder.alerts:
+		if alert.MarginCallID != 7 {
+			t.Errorf("delivered alert for margin call %d, want 7", alert.MarginCallID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("alert was not delivered")
+	}
+}
+
+func TestNotificationDispatcherDeliversToEveryNotifier(t *testing.T) {
+	failing := &recordingNotifier{alerts: make(chan *MarginCallAlert, 1), err: io.ErrUnexpectedEOF}
+	working := &recordingNotifier{alerts: make(chan *MarginCallAlert, 1)}
+	dispatcher := &NotificationDispatcher{Global: []Notifier{failing, working}}
+
+	if err := dispatcher.Dispatch(testAlert()); err == nil {
+		t.Error("expected the failure to be reported")
+	}
+	if len(working.alerts) != 1 {
+		t.Error("a failing notifier held back the others")
+	}
+}
+
+func TestNotifierForChannelEnforcesPolicy(t *testing.T) {
+	dir := t.TempDir()
+	dispatcher := &NotificationDispatcher{Config: config.NotificationConfig{
+		WebhookAllowedHosts:   []string{"hooks.example.com"},
+		WebhookAllowedSchemes: []string{"https"},
+		FileDir:               dir,
+	}}
+
+	tests := []struct {
+		name     string
+		channel  models.NotificationChannel
+		wantErr  bool
+		wantPath string
+	}{
+		{name: "allowed webhook", channel: models.NotificationChannel{Type: models.ChannelWebhook, Target: "https://hooks.example.com/minirisk"}},
+		{name: "internal webhook", channel: models.NotificationChannel{Type: models.ChannelWebhook, Target: "http://169.254.169.254/latest"}, wantErr: true},
+		{name: "file inside directory", channel: models.NotificationChannel{Type: models.ChannelFile, Target: "client-42.jsonl"}, wantPath: filepath.Join(dir, "client-42.jsonl")},
+		{name: "file outside directory", channel: models.NotificationChannel{Type: models.ChannelFile, Target: "../../etc/cron.d/x.jsonl"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, err := dispatcher.notifierForChannel(tt.channel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if file, ok := notifier.(*FileNotifier); ok && file.Path != tt.wantPath {
+				t.Errorf("path = %q, want %q", file.Path, tt.wantPath)
+			}
+		})
+	}
+}
+
+// notifierFunc adapts a function to the Notifier interface
+type notifierFunc func(alert *MarginCallAlert) error
+
+func (f notifierFunc) Name() string { return "func" }
+
+func (f notifierFunc) Notify(alert *MarginCallAlert) error { return f(alert) }
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/minirisk/models"
+)
+
+// PnLSnapshotService persists each client's P&L once a day
+type PnLSnapshotService struct {
+	DB      *sql.DB
+	Pricing models.PricingPolicy
+}
+
+// NewPnLSnapshotService creates a new PnLSnapshotService instance
+func NewPnLSnapshotService(db *sql.DB) *PnLSnapshotService {
+	return &PnLSnapshotService{DB: db}
+}
+
+// SnapshotAll records the current day's P&L snapshot for every client
+func (pss *PnLSnapshotService) SnapshotAll() error {
+	marginService := &models.MarginService{DB: pss.DB}
+	clientIDs, err := marginService.GetClientIDs()
+	if err != nil {
+		return fmt.Errorf("failed to get clients: %v", err)
+	}
+
+	pnlService := &models.PnLService{DB: pss.DB, Pricing: pss.Pricing}
+	for _, clientID := range clientIDs {
+		report, err := pnlService.CalculatePnL(clientID)
+		if err != nil {
+			fmt.Printf("Failed to calculate P&L for client %d: %v\n", clientID, err)
+			continue
+		}
+		if err := pnlService.SaveSnapshot(report); err != nil {
+			fmt.Printf("Failed to save P&L snapshot for client %d: %v\n", clientID, err)
+		}
+	}
+
+	return nil
+}
+
+// StartSnapshots begins snapshotting P&L on a schedule. Each run overwrites the
+// current day's snapshot, so the last run of the day is the one kept.
+func (pss *PnLSnapshotService) StartSnapshots(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := pss.SnapshotAll(); err != nil {
+				fmt.Printf("Error taking P&L snapshots: %v\n", err)
+			}
+		}
+	}()
+}
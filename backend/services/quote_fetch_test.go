@@ -0,0 +1,139 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// quoteServer serves single and batch quotes for the given prices. Symbols
+// listed in failing get a 500 in single-symbol mode.
+func quoteServer(t *testing.T, prices map[string]float64, failing ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/quote/")
+		if r.URL.Query().Get("apikey") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.Contains(path, ",") {
+			var quotes []map[string]interface{}
+			for _, symbol := range strings.Split(path, ",") {
+				if price, ok := prices[symbol]; ok {
+					quotes = append(quotes, map[string]interface{}{"symbol": strings.ToLower(symbol), "price": price})
+				}
+			}
+			json.NewEncoder(w).Encode(quotes)
+			return
+		}
+
+		for _, symbol := range failing {
+			if symbol == path {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		price, ok := prices[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]float64{"price": price})
+	}))
+}
+
+func TestRESTQuoteProviderFailuresDoNotHoldBackOthers(t *testing.T) {
+	prices := map[string]float64{"AAPL": 190.5, "MSFT": 410.25, "ZERO": 0}
+	server := quoteServer(t, prices, "MSFT")
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		batchSize  int
+		apiKey     string
+		symbols    []string
+		wantPrices map[string]float64
+		wantFailed []string
+	}{
+		{
+			name:       "single symbol",
+			symbols:    []string{"AAPL"},
+			apiKey:     "key",
+			wantPrices: map[string]float64{"AAPL": 190.5},
+		},
+		{
+			name:       "server error fails only that symbol",
+			symbols:    []string{"AAPL", "MSFT"},
+			apiKey:     "key",
+			wantPrices: map[string]float64{"AAPL": 190.5},
+			wantFailed: []string{"MSFT"},
+		},
+		{
+			name:       "unknown and zero prices fail",
+			symbols:    []string{"AAPL", "NOPE", "ZERO"},
+			apiKey:     "key",
+			wantPrices: map[string]float64{"AAPL": 190.5},
+			wantFailed: []string{"NOPE", "ZERO"},
+		},
+		{
+			name:       "batch prices symbols case-insensitively",
+			batchSize:  2,
+			symbols:    []string{"AAPL", "MSFT"},
+			apiKey:     "key",
+			wantPrices: map[string]float64{"AAPL": 190.5, "MSFT": 410.25},
+		},
+		{
+			name:       "batch reports symbols it did not return",
+			batchSize:  3,
+			symbols:    []string{"AAPL", "NOPE", "MSFT"},
+			apiKey:     "key",
+			wantPrices: map[string]float64{"AAPL": 190.5, "MSFT": 410.25},
+			wantFailed: []string{"NOPE"},
+		},
+		{
+			name:       "rejected request fails every symbol in the batch",
+			batchSize:  5,
+			symbols:    []string{"AAPL", "MSFT"},
+			apiKey:     "wrong",
+			wantFailed: []string{"AAPL", "MSFT"},
+		},
+		{
+			name:    "no symbols",
+			apiKey:  "key",
+			symbols: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewRESTQuoteProvider(server.URL, tt.apiKey)
+			provider.BatchSize = tt.batchSize
+			provider.Concurrency = 2
+
+			result, err := provider.FetchQuotes(tt.symbols)
+			if err != nil {
+				t.Fatalf("FetchQuotes returned error: %v", err)
+			}
+			checkQuotes(t, result, tt.wantPrices, tt.wantFailed)
+		})
+	}
+}
+
+func TestFileQuoteProviderRejectsNonPositivePrices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.csv")
+	if err := os.WriteFile(path, []byte("AAPL,190.5\nZERO,0\nNEG,-3.25\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &FileQuoteProvider{Path: path}
+	result, err := provider.FetchQuotes([]string{"AAPL", "ZERO", "NEG"})
+	if err != nil {
+		t.Fatalf("FetchQuotes returned error: %v", err)
+	}
+	checkQuotes(t, result, map[string]float64{"AAPL": 190.5}, []string{"ZERO", "NEG"})
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minirisk/config"
+)
+
+// QuoteProvider fetches current prices for a set of symbols from a market data source
+type QuoteProvider interface {
+	// Name returns a short identifier for the provider
+	Name() string
+	// FetchQuotes returns the latest price for each requested symbol. Symbols that
+	// could not be priced are reported in the result's Failures rather than as an error;
+	// the error is reserved for failures of the provider as a whole.
+	FetchQuotes(symbols []string) (*QuoteResult, error)
+}
+
+// QuoteResult holds the prices and per-symbol failures of a quote fetch
+type QuoteResult struct {
+	Prices   map[string]float64
+	Failures map[string]error
+}
+
+// newQuoteResult creates an empty QuoteResult
+func newQuoteResult() *QuoteResult {
+	return &QuoteResult{
+		Prices:   make(map[string]float64),
+		Failures: make(map[string]error),
+	}
+}
+
+// NewQuoteProvider creates the quote provider selected by the market configuration
+func NewQuoteProvider(cfg config.MarketConfig) (QuoteProvider, error) {
+	switch cfg.Provider {
+	case "", "rest":
+		provider := NewRESTQuoteProvider(cfg.APIURL, cfg.APIKey)
+		if cfg.Concurrency > 0 {
+			provider.Concurrency = cfg.Concurrency
+		}
+		if cfg.RequestTimeout > 0 {
+			provider.RequestTimeout = cfg.RequestTimeout
+		}
+		provider.BatchSize = cfg.BatchSize
+		return provider, nil
+	case "file":
+		return &FileQuoteProvider{Path: cfg.QuoteFile}, nil
+	case "fake":
+		return NewFakeQuoteProvider(nil), nil
+	default:
+		return nil, fmt.Errorf("unknown market data provider: %s", cfg.Provider)
+	}
+}
+
+// RESTQuoteProvider fetches quotes from a REST API. In single-symbol mode it calls
+// GET {APIURL}/quote/{symbol}?apikey= and expects {"price": ...}. When BatchSize is
+// greater than zero it calls GET {APIURL}/quote/{SYM1,SYM2,...}?apikey= and expects
+// an array of {"symbol": ..., "price": ...}.
+type RESTQuoteProvider struct {
+	APIURL         string
+	APIKey         string
+	Client         *http.Client
+	Concurrency    int
+	BatchSize      int
+	RequestTimeout time.Duration
+}
+
+// NewRESTQuoteProvider creates a new RESTQuoteProvider instance
+func NewRESTQuoteProvider(apiURL, apiKey string) *RESTQuoteProvider {
+	return &RESTQuoteProvider{
+		APIURL:         apiURL,
+		APIKey:         apiKey,
+		Client:         &http.Client{},
+		Concurrency:    8,
+		RequestTimeout: 10 * time.Second,
+	}
+}
+
+// Name returns the provider identifier
+func (p *RESTQuoteProvider) Name() string {
+	return "rest"
+}
+
+// FetchQuotes retrieves current prices from the market data API using a bounded
+// pool of workers, one request per symbol or per batch of symbols
+func (p *RESTQuoteProvider) FetchQuotes(symbols []string) (*QuoteResult, error) {
+	result := newQuoteResult()
+	if len(symbols) == 0 {
+		return result, nil
+	}
+
+	jobs := make(chan []string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	workers := p.Concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				prices, failures := p.fetchBatch(batch)
+				mu.Lock()
+				for symbol, price := range prices {
+					result.Prices[symbol] = price
+				}
+				for symbol, err := range failures {
+					result.Failures[symbol] = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, batch := range splitBatches(symbols, p.BatchSize) {
+		jobs <- batch
+	}
+	close(jobs)
+	wg.Wait()
+
+	return result, nil
+}
+
+// fetchBatch fetches a single request's worth of symbols
+func (p *RESTQuoteProvider) fetchBatch(symbols []string) (map[string]float64, map[string]error) {
+	prices := make(map[string]float64)
+	failures := make(map[string]error)
+
+	if p.BatchSize <= 0 {
+		symbol := symbols[0]
+		var quote struct {
+			Price float64 `json:"price"`
+		}
+		if err := p.getJSON(symbol, &quote); err != nil {
+			failures[symbol] = err
+		} else if quote.Price <= 0 {
+			failures[symbol] = fmt.Errorf("invalid price %v", quote.Price)
+		} else {
+			prices[symbol] = quote.Price
+		}
+		return prices, failures
+	}
+
+	var quotes []struct {
+		Symbol string  `json:"symbol"`
+		Price  float64 `json:"price"`
+	}
+	if err := p.getJSON(strings.Join(symbols, ","), &quotes); err != nil {
+		for _, symbol := range symbols {
+			failures[symbol] = err
+		}
+		return prices, failures
+	}
+
+	returned := make(map[string]float64)
+	for _, quote := range quotes {
+		returned[strings.ToUpper(quote.Symbol)] = quote.Price
+	}
+	for _, symbol := range symbols {
+		price, ok := returned[strings.ToUpper(symbol)]
+		switch {
+		case !ok:
+			failures[symbol] = fmt.Errorf("symbol not returned by batch quote")
+		case price <= 0:
+			failures[symbol] = fmt.Errorf("invalid price %v", price)
+		default:
+			prices[symbol] = price
+		}
+	}
+
+	return prices, failures
+}
+
+// getJSON performs a quote request with a per-request timeout and decodes the response
+func (p *RESTQuoteProvider) getJSON(path string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.RequestTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/quote/%s?apikey=%s", p.APIURL, path, p.APIKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch quote: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	return nil
+}
+
+// splitBatches splits symbols into groups of at most size; a size of zero or less
+// yields one group per symbol
+func splitBatches(symbols []string, size int) [][]string {
+	if size <= 0 {
+		size = 1
+	}
+
+	var batches [][]string
+	for start := 0; start < len(symbols); start += size {
+		end := start + size
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+		batches = append(batches, symbols[start:end])
+	}
+	return batches
+}
+
+// FileQuoteProvider reads quotes from a CSV file of "symbol,price" rows.
+// The file is re-read on every fetch so an external process can drop in new prices.
+type FileQuoteProvider struct {
+	Path string
+}
+
+// Name returns the provider identifier
+func (p *FileQuoteProvider) Name() string {
+	return "file"
+}
+
+// FetchQuotes reads the quote file and returns prices for the requested symbols
+func (p *FileQuoteProvider) FetchQuotes(symbols []string) (*QuoteResult, error) {
+	file, err := os.Open(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quote file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	available := make(map[string]float64)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read quote file: %v", err)
+		}
+		if len(record) < 2 {
+			continue
+		}
+
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			// Skip header rows and malformed prices
+			continue
+		}
+		available[strings.ToUpper(strings.TrimSpace(record[0]))] = price
+	}
+
+	result := newQuoteResult()
+	for _, symbol := range symbols {
+		if price, ok := available[strings.ToUpper(symbol)]; !ok {
+			result.Failures[symbol] = fmt.Errorf("symbol not found in quote file")
+		} else if price <= 0 {
+			result.Failures[symbol] = fmt.Errorf("invalid price %v", price)
+		} else {
+			result.Prices[symbol] = price
+		}
+	}
+
+	return result, nil
+}
+
+// FakeQuoteProvider serves prices from memory, for tests and local development
+type FakeQuoteProvider struct {
+	mu     sync.RWMutex
+	prices map[string]float64
+}
+
+// NewFakeQuoteProvider creates a new FakeQuoteProvider seeded with the given prices
+func NewFakeQuoteProvider(prices map[string]float64) *FakeQuoteProvider {
+	p := &FakeQuoteProvider{prices: make(map[string]float64)}
+	for symbol, price := range prices {
+		p.prices[symbol] = price
+	}
+	return p
+}
+
+// Name returns the provider identifier
+func (p *FakeQuoteProvider) Name() string {
+	return "fake"
+}
+
+// SetPrice sets the price returned for a symbol
+func (p *FakeQuoteProvider) SetPrice(symbol string, price float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prices[symbol] = price
+}
+
+// FetchQuotes returns the configured prices for the requested symbols
+func (p *FakeQuoteProvider) FetchQuotes(symbols []string) (*QuoteResult, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := newQuoteResult()
+	for _, symbol := range symbols {
+		if price, ok := p.prices[symbol]; ok {
+			result.Prices[symbol] = price
+		} else {
+			result.Failures[symbol] = fmt.Errorf("no fake price set")
+		}
+	}
+
+	return result, nil
+}
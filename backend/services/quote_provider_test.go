@@ -0,0 +1,126 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/minirisk/config"
+)
+
+// checkQuotes compares a quote result with the expected prices and failed symbols
+func checkQuotes(t *testing.T, result *QuoteResult, prices map[string]float64, failed []string) {
+	t.Helper()
+	if len(result.Prices) != len(prices) {
+		t.Errorf("got prices %v, want %v", result.Prices, prices)
+	}
+	for symbol, want := range prices {
+		if got, ok := result.Prices[symbol]; !ok || got != want {
+			t.Errorf("price of %s = %v, %v; want %v", symbol, got, ok, want)
+		}
+	}
+	if len(result.Failures) != len(failed) {
+		t.Errorf("got failures %v, want %v", result.Failures, failed)
+	}
+	for _, symbol := range failed {
+		if _, ok := result.Failures[symbol]; !ok {
+			t.Errorf("expected %s to fail", symbol)
+		}
+		if _, ok := result.Prices[symbol]; ok {
+			t.Errorf("failed symbol %s was priced", symbol)
+		}
+	}
+}
+
+func TestFileQuoteProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "quotes.csv")
+	contents := "symbol,price\n# comment\naapl, 190.5\nMSFT,not-a-price\nNVDA,120\n"
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		symbols    []string
+		wantErr    bool
+		wantPrices map[string]float64
+		wantFailed []string
+	}{
+		{
+			name:       "prices listed symbols and fails the rest",
+			path:       path,
+			symbols:    []string{"AAPL", "NVDA", "MSFT", "AMD"},
+			wantPrices: map[string]float64{"AAPL": 190.5, "NVDA": 120},
+			wantFailed: []string{"MSFT", "AMD"},
+		},
+		{
+			name:    "missing file fails the whole fetch",
+			path:    filepath.Join(dir, "missing.csv"),
+			symbols: []string{"AAPL"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &FileQuoteProvider{Path: tt.path}
+			result, err := provider.FetchQuotes(tt.symbols)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchQuotes returned error: %v", err)
+			}
+			checkQuotes(t, result, tt.wantPrices, tt.wantFailed)
+		})
+	}
+}
+
+func TestFakeQuoteProvider(t *testing.T) {
+	provider := NewFakeQuoteProvider(map[string]float64{"AAPL": 100})
+	provider.SetPrice("MSFT", 200)
+	provider.SetPrice("AAPL", 101)
+
+	result, err := provider.FetchQuotes([]string{"AAPL", "MSFT", "NVDA"})
+	if err != nil {
+		t.Fatalf("FetchQuotes returned error: %v", err)
+	}
+	checkQuotes(t, result, map[string]float64{"AAPL": 101, "MSFT": 200}, []string{"NVDA"})
+}
+
+func TestNewQuoteProvider(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     string
+		wantErr  bool
+	}{
+		{provider: "", want: "rest"},
+		{provider: "rest", want: "rest"},
+		{provider: "file", want: "file"},
+		{provider: "fake", want: "fake"},
+		{provider: "carrier-pigeon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			provider, err := NewQuoteProvider(config.MarketConfig{Provider: tt.provider})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewQuoteProvider returned error: %v", err)
+			}
+			if provider.Name() != tt.want {
+				t.Errorf("Name() = %q, want %q", provider.Name(), tt.want)
+			}
+		})
+	}
+}
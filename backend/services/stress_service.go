@@ -0,0 +1,61 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/minirisk/models"
+)
+
+// StressTestService runs stored stress scenarios against all client portfolios
+type StressTestService struct {
+	DB            *sql.DB
+	Pricing       models.PricingPolicy
+	Concentration models.ConcentrationPolicy
+}
+
+// NewStressTestService creates a new StressTestService instance
+func NewStressTestService(db *sql.DB) *StressTestService {
+	return &StressTestService{DB: db}
+}
+
+// RunAllScenarios runs every stored scenario and records the results
+func (sts *StressTestService) RunAllScenarios() error {
+	stressService := &models.StressService{DB: sts.DB, Pricing: sts.Pricing, Concentration: sts.Concentration}
+	scenarios, err := stressService.ListScenarios()
+	if err != nil {
+		return fmt.Errorf("failed to get stress scenarios: %v", err)
+	}
+
+	for i := range scenarios {
+		scenario := &scenarios[i]
+		run, err := stressService.RunScenario(scenario)
+		if err != nil {
+			fmt.Printf("Failed to run stress scenario %q: %v\n", scenario.Name, err)
+			continue
+		}
+		if err := stressService.SaveRun(run); err != nil {
+			fmt.Printf("Failed to save stress run for scenario %q: %v\n", scenario.Name, err)
+			continue
+		}
+		if run.MarginCallCount > 0 {
+			fmt.Printf("STRESS SCENARIO %q - %d of %d clients would be in margin call: %v\n",
+				scenario.Name, run.MarginCallCount, run.ClientCount, run.MarginCallClients)
+		}
+	}
+
+	return nil
+}
+
+// StartStressTesting begins running stress scenarios on a schedule
+func (sts *StressTestService) StartStressTesting(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := sts.RunAllScenarios(); err != nil {
+				fmt.Printf("Error running stress scenarios: %v\n", err)
+			}
+		}
+	}()
+}
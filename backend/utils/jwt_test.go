@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// testClaims returns claims for a client user expiring after ttl
+func testClaims(ttl time.Duration) Claims {
+	clientID := int64(42)
+	now := time.Now()
+	return Claims{
+		Subject:   "alice",
+		UserID:    7,
+		Username:  "alice",
+		Role:      "client",
+		ClientID:  &clientID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+}
+
+// withHeader replaces the header of a token, keeping its payload and signature
+func withHeader(token, header string) string {
+	parts := strings.Split(token, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(header))
+	return strings.Join(parts, ".")
+}
+
+func TestParseJWT(t *testing.T) {
+	valid, err := GenerateJWT(testSecret, testClaims(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := GenerateJWT(testSecret, testClaims(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret, err := GenerateJWT("other-secret", testClaims(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(valid, ".")
+	tamperedClaims := testClaims(time.Hour)
+	tamperedClaims.Role = "admin"
+	tampered, err := GenerateJWT(testSecret, tamperedClaims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered = parts[0] + "." + strings.Split(tampered, ".")[1] + "." + parts[2]
+
+	// An alg none token signed with nothing, and one claiming another algorithm
+	// but signed with the HMAC secret
+	none := withHeader(valid, `{"alg":"none","typ":"JWT"}`)
+	none = none[:strings.LastIndex(none, ".")+1]
+	rs256 := withHeader(valid, `{"alg":"RS256","typ":"JWT"}`)
+	unsigned := rs256[:strings.LastIndex(rs256, ".")]
+	rs256 = unsigned + "." + signJWT(testSecret, unsigned)
+
+	tests := []struct {
+		name    string
+		secret  string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", secret: testSecret, token: valid},
+		{name: "signed with another secret", secret: testSecret, token: otherSecret, wantErr: ErrInvalidToken},
+		{name: "tampered payload", secret: testSecret, token: tampered, wantErr: ErrInvalidToken},
+		{name: "alg none", secret: testSecret, token: none, wantErr: ErrInvalidToken},
+		{name: "alg confusion", secret: testSecret, token: rs256, wantErr: ErrInvalidToken},
+		{name: "expired", secret: testSecret, token: expired, wantErr: ErrExpiredToken},
+		{name: "malformed", secret: testSecret, token: "not-a-token", wantErr: ErrInvalidToken},
+		{name: "empty", secret: testSecret, token: "", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseJWT(tt.secret, tt.token)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if claims.UserID != 7 || claims.Role != "client" || claims.ClientID == nil || *claims.ClientID != 42 {
+				t.Errorf("claims = %+v, want user 7, client role and client 42", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+-- Create append-only price history table
+CREATE TABLE IF NOT EXISTS price_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    price DECIMAL(20, 4) NOT NULL,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_price_history_symbol_timestamp (symbol, timestamp)
+) ENGINE=InnoDB;
+
+-- Seed history with the prices already in market_data
+INSERT INTO price_history (symbol, price, timestamp)
+SELECT symbol, current_price, timestamp FROM market_data;
@@ -0,0 +1,77 @@
+-- Create symbol sector classification table
+CREATE TABLE IF NOT EXISTS symbol_sectors (
+    symbol VARCHAR(10) PRIMARY KEY,
+    sector VARCHAR(50) NOT NULL,
+    INDEX idx_symbol_sectors_sector (sector)
+) ENGINE=InnoDB;
+
+-- Create stress scenarios table
+CREATE TABLE IF NOT EXISTS stress_scenarios (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_stress_scenarios_name (name)
+) ENGINE=InnoDB;
+
+-- Create stress scenario shocks table
+-- shock_type is one of market, sector or symbol; target is empty for market shocks
+CREATE TABLE IF NOT EXISTS stress_scenario_shocks (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    scenario_id BIGINT NOT NULL,
+    shock_type VARCHAR(10) NOT NULL,
+    target VARCHAR(50) NOT NULL DEFAULT '',
+    shock DECIMAL(7, 4) NOT NULL,
+    INDEX idx_stress_scenario_shocks_scenario_id (scenario_id),
+    CONSTRAINT fk_stress_scenario_shocks_scenario_id
+        FOREIGN KEY (scenario_id) REFERENCES stress_scenarios(id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Create stress run history table
+CREATE TABLE IF NOT EXISTS stress_runs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    scenario_id BIGINT NOT NULL,
+    run_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    client_count INT NOT NULL,
+    margin_call_count INT NOT NULL,
+    results JSON NOT NULL,
+    INDEX idx_stress_runs_scenario_id_run_at (scenario_id, run_at),
+    CONSTRAINT fk_stress_runs_scenario_id
+        FOREIGN KEY (scenario_id) REFERENCES stress_scenarios(id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Insert sector classification for sample symbols
+INSERT INTO symbol_sectors (symbol, sector) VALUES
+('AAPL', 'Technology'),
+('MSFT', 'Technology'),
+('NVDA', 'Technology'),
+('AMD', 'Technology'),
+('INTC', 'Technology'),
+('GOOGL', 'Communication Services'),
+('META', 'Communication Services'),
+('DIS', 'Communication Services'),
+('NFLX', 'Communication Services'),
+('AMZN', 'Consumer Discretionary'),
+('TSLA', 'Consumer Discretionary'),
+('JPM', 'Financials'),
+('PFE', 'Health Care'),
+('JNJ', 'Health Care'),
+('KO', 'Consumer Staples'),
+('PEP', 'Consumer Staples');
+
+-- Insert sample scenarios
+INSERT INTO stress_scenarios (id, name, description) VALUES
+(1, 'Tech -20%', 'Technology sector falls 20%'),
+(2, 'Market -10% + NVDA -35%', 'Broad market falls 10% with NVDA down 35%'),
+(3, 'Rates shock', 'Financials -15%, Consumer Discretionary -12%, market -5%');
+
+INSERT INTO stress_scenario_shocks (scenario_id, shock_type, target, shock) VALUES
+(1, 'sector', 'Technology', -0.2000),
+(2, 'market', '', -0.1000),
+(2, 'symbol', 'NVDA', -0.3500),
+(3, 'market', '', -0.0500),
+(3, 'sector', 'Financials', -0.1500),
+(3, 'sector', 'Consumer Discretionary', -0.1200);
@@ -0,0 +1,37 @@
+-- Create margin calls table
+-- status is one of issued, acknowledged, partially_met, met, escalated, liquidated
+CREATE TABLE IF NOT EXISTS margin_calls (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    amount_due DECIMAL(20, 4) NOT NULL,
+    amount_met DECIMAL(20, 4) NOT NULL DEFAULT 0,
+    issued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    due_at TIMESTAMP NOT NULL,
+    acknowledged_at TIMESTAMP NULL,
+    escalated_at TIMESTAMP NULL,
+    resolved_at TIMESTAMP NULL,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_margin_calls_client_id_status (client_id, status),
+    INDEX idx_margin_calls_status_due_at (status, due_at),
+    CONSTRAINT fk_margin_calls_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Create margin call event history table
+CREATE TABLE IF NOT EXISTS margin_call_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    margin_call_id BIGINT NOT NULL,
+    from_status VARCHAR(20) NOT NULL DEFAULT '',
+    to_status VARCHAR(20) NOT NULL,
+    amount DECIMAL(20, 4) NOT NULL DEFAULT 0,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_margin_call_events_margin_call_id (margin_call_id),
+    CONSTRAINT fk_margin_call_events_margin_call_id
+        FOREIGN KEY (margin_call_id) REFERENCES margin_calls(id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
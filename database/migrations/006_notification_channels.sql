@@ -0,0 +1,14 @@
+-- Create notification channels table
+-- A NULL client_id marks a global channel that receives alerts for every client
+-- channel_type is one of email, webhook or file
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NULL,
+    channel_type VARCHAR(10) NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    secret VARCHAR(255) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_notification_channels_client_id (client_id)
+) ENGINE=InnoDB;
@@ -0,0 +1,22 @@
+-- Create users table
+-- A NULL client_id marks a staff user who may access every client
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    password_hash VARCHAR(100) NOT NULL,
+    client_id BIGINT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_users_username (username),
+    INDEX idx_users_client_id (client_id),
+    CONSTRAINT fk_users_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Insert development users (passwords: admin123 and client123); change before deploying
+INSERT INTO users (username, password_hash, client_id) VALUES
+('admin', '$2a$10$7ma6/R37mIcTb0EZIn49deePb3zY004rrSxVdsBZeuFfGl6lSylv.', NULL),
+('client1001', '$2a$10$RXwLJNJIsZjn6999m/AFBeTCrZZT4dW8Fkm0D.UYHW8CgkZcJ64/6', 1001),
+('client1002', '$2a$10$RXwLJNJIsZjn6999m/AFBeTCrZZT4dW8Fkm0D.UYHW8CgkZcJ64/6', 1002);
@@ -0,0 +1,13 @@
+-- Add roles to users
+-- role is one of client, risk_officer, admin or market_data_feed
+ALTER TABLE users
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'client' AFTER password_hash;
+
+CREATE INDEX idx_users_role ON users(role);
+
+UPDATE users SET role = 'admin' WHERE client_id IS NULL;
+
+-- Insert development staff users (password: admin123); change before deploying
+INSERT INTO users (username, password_hash, role, client_id) VALUES
+('risk', '$2a$10$7ma6/R37mIcTb0EZIn49deePb3zY004rrSxVdsBZeuFfGl6lSylv.', 'risk_officer', NULL),
+('feed', '$2a$10$7ma6/R37mIcTb0EZIn49deePb3zY004rrSxVdsBZeuFfGl6lSylv.', 'market_data_feed', NULL);
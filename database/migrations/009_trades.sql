@@ -0,0 +1,28 @@
+-- Create trades table
+-- side is one of buy or sell; positions are maintained as a projection of this ledger
+CREATE TABLE IF NOT EXISTS trades (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    side VARCHAR(4) NOT NULL,
+    quantity INT NOT NULL,
+    price DECIMAL(20, 4) NOT NULL,
+    fees DECIMAL(20, 4) NOT NULL DEFAULT 0,
+    trade_date DATE NOT NULL,
+    settlement_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_trades_client_id_symbol (client_id, symbol),
+    INDEX idx_trades_trade_date (trade_date),
+    CONSTRAINT fk_trades_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- One position per client and symbol
+ALTER TABLE positions
+ADD UNIQUE KEY uk_positions_client_id_symbol (client_id, symbol);
+
+-- Record existing positions as opening trades so the ledger matches the projection
+INSERT INTO trades (client_id, symbol, side, quantity, price, fees, trade_date, settlement_date)
+SELECT client_id, symbol, 'buy', quantity, cost_basis, 0, DATE(created_at), DATE(created_at)
+FROM positions;
@@ -0,0 +1,56 @@
+-- Record how each sell relieved its lots
+ALTER TABLE trades
+ADD COLUMN relief_method VARCHAR(16) NULL AFTER settlement_date;
+
+-- Create tax_lots table
+-- Each buy opens a lot; cost_per_share includes the buy's fees
+CREATE TABLE IF NOT EXISTS tax_lots (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    trade_id BIGINT NULL,
+    open_date DATE NOT NULL,
+    quantity INT NOT NULL,
+    remaining_quantity INT NOT NULL,
+    cost_per_share DECIMAL(20, 6) NOT NULL,
+    closed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_tax_lots_client_id_symbol (client_id, symbol, remaining_quantity),
+    CONSTRAINT fk_tax_lots_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_tax_lots_trade_id
+        FOREIGN KEY (trade_id) REFERENCES trades(id)
+        ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+-- Create lot_reliefs table
+-- One row per lot closed, in whole or in part, by a sell, with its realized P&L
+CREATE TABLE IF NOT EXISTS lot_reliefs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    lot_id BIGINT NOT NULL,
+    trade_id BIGINT NOT NULL,
+    client_id BIGINT NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    quantity INT NOT NULL,
+    cost_per_share DECIMAL(20, 6) NOT NULL,
+    proceeds_per_share DECIMAL(20, 6) NOT NULL,
+    realized_pnl DECIMAL(20, 4) NOT NULL,
+    open_date DATE NOT NULL,
+    close_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_lot_reliefs_client_id_close_date (client_id, close_date),
+    INDEX idx_lot_reliefs_trade_id (trade_id),
+    CONSTRAINT fk_lot_reliefs_lot_id
+        FOREIGN KEY (lot_id) REFERENCES tax_lots(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_lot_reliefs_trade_id
+        FOREIGN KEY (trade_id) REFERENCES trades(id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Open one lot per existing position at its average cost
+INSERT INTO tax_lots (client_id, symbol, open_date, quantity, remaining_quantity, cost_per_share)
+SELECT client_id, symbol, DATE(created_at), quantity, quantity, cost_basis
+FROM positions
+WHERE quantity > 0;
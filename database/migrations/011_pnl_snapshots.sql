@@ -0,0 +1,21 @@
+-- Create pnl_snapshots table
+-- One row per client per day; the snapshot job overwrites the current day's row
+-- so it holds the last figures of the day
+CREATE TABLE IF NOT EXISTS pnl_snapshots (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    snapshot_date DATE NOT NULL,
+    market_value DECIMAL(20, 4) NOT NULL,
+    cost_value DECIMAL(20, 4) NOT NULL,
+    unrealized_pnl DECIMAL(20, 4) NOT NULL,
+    day_change DECIMAL(20, 4) NOT NULL,
+    realized_pnl DECIMAL(20, 4) NOT NULL,
+    realized_pnl_today DECIMAL(20, 4) NOT NULL,
+    positions JSON NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_pnl_snapshots_client_id_date (client_id, snapshot_date),
+    CONSTRAINT fk_pnl_snapshots_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,41 @@
+-- Tax lots are long or short; short lots carry the net proceeds of the short sale
+ALTER TABLE tax_lots
+ADD COLUMN side VARCHAR(5) NOT NULL DEFAULT 'long' AFTER symbol;
+
+-- Separate maintenance rate for short positions
+ALTER TABLE margins
+ADD COLUMN short_maintenance_margin DECIMAL(5, 4) NOT NULL DEFAULT 0.3000 AFTER maintenance_margin;
+
+-- Create borrow_rates table
+-- Annual stock borrow fee per symbol; symbols without a rate use DEFAULT_BORROW_RATE
+CREATE TABLE IF NOT EXISTS borrow_rates (
+    symbol VARCHAR(10) PRIMARY KEY,
+    annual_rate DECIMAL(8, 6) NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+-- Create borrow_fee_accruals table
+-- One accrual per short position per day, added to the client's loan amount
+CREATE TABLE IF NOT EXISTS borrow_fee_accruals (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    accrual_date DATE NOT NULL,
+    quantity INT NOT NULL,
+    price DECIMAL(20, 4) NOT NULL,
+    annual_rate DECIMAL(8, 6) NOT NULL,
+    fee DECIMAL(20, 4) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_borrow_fee_accruals_client_symbol_date (client_id, symbol, accrual_date),
+    CONSTRAINT fk_borrow_fee_accruals_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Insert sample borrow rates
+INSERT INTO borrow_rates (symbol, annual_rate) VALUES
+('AAPL', 0.002500),
+('MSFT', 0.002500),
+('TSLA', 0.012000),
+('NVDA', 0.008000),
+('AMD', 0.010000);
@@ -0,0 +1,29 @@
+-- Create maintenance_rules table
+-- rule_type is one of symbol, price_band or concentration; side is long, short
+-- or NULL for both. Price and concentration bands are [min, max) with NULL open.
+-- A position's maintenance rate is the highest of its account rate and every
+-- matching rule.
+CREATE TABLE IF NOT EXISTS maintenance_rules (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    rule_type VARCHAR(16) NOT NULL,
+    side VARCHAR(5) NULL,
+    symbol VARCHAR(10) NULL,
+    min_price DECIMAL(20, 4) NULL,
+    max_price DECIMAL(20, 4) NULL,
+    min_concentration DECIMAL(5, 4) NULL,
+    max_concentration DECIMAL(5, 4) NULL,
+    rate DECIMAL(6, 4) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_maintenance_rules_symbol (symbol)
+) ENGINE=InnoDB;
+
+-- Insert sample house rules
+INSERT INTO maintenance_rules (name, rule_type, side, symbol, min_price, max_price, min_concentration, max_concentration, rate) VALUES
+('High volatility: TSLA', 'symbol', NULL, 'TSLA', NULL, NULL, NULL, NULL, 0.5000),
+('High volatility: NVDA', 'symbol', NULL, 'NVDA', NULL, NULL, NULL, NULL, 0.5000),
+('Low-priced stocks', 'price_band', 'long', NULL, NULL, 5.0000, NULL, NULL, 1.0000),
+('Stocks $5-$10', 'price_band', 'long', NULL, 5.0000, 10.0000, NULL, NULL, 0.4000),
+('Concentration 30-50%', 'concentration', NULL, NULL, NULL, NULL, 0.3000, 0.5000, 0.4000),
+('Concentration over 50%', 'concentration', NULL, NULL, NULL, NULL, 0.5000, NULL, 0.5000);
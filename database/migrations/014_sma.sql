@@ -0,0 +1,4 @@
+-- Special memorandum account: credit of equity above the initial requirement.
+-- Starts at zero; the margin monitor raises it to each client's excess equity.
+ALTER TABLE margins
+ADD COLUMN sma DECIMAL(20, 4) NOT NULL DEFAULT 0 AFTER short_maintenance_margin;
@@ -0,0 +1,19 @@
+-- Create liquidation_plans table
+-- Suggested orders to cure a margin shortfall, stored when a margin call escalates
+CREATE TABLE IF NOT EXISTS liquidation_plans (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    margin_call_id BIGINT NULL,
+    preferences VARCHAR(100) NOT NULL,
+    shortfall DECIMAL(20, 4) NOT NULL,
+    orders JSON NOT NULL,
+    cured BOOLEAN NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_liquidation_plans_margin_call_id (margin_call_id, created_at),
+    CONSTRAINT fk_liquidation_plans_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_liquidation_plans_margin_call_id
+        FOREIGN KEY (margin_call_id) REFERENCES margin_calls(id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
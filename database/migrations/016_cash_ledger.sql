@@ -0,0 +1,23 @@
+-- Create cash_transactions table
+-- Cash ledger per client; margins.loan_amount is the negative of its running balance
+CREATE TABLE IF NOT EXISTS cash_transactions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    transaction_type VARCHAR(20) NOT NULL,
+    amount DECIMAL(20, 4) NOT NULL,
+    loan_balance DECIMAL(20, 4) NOT NULL,
+    trade_id BIGINT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    effective_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_cash_transactions_client_id (client_id, created_at),
+    CONSTRAINT fk_cash_transactions_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- Open each ledger at the existing loan amount
+INSERT INTO cash_transactions (client_id, transaction_type, amount, loan_balance, description, effective_date)
+SELECT client_id, 'adjustment', -loan_amount, loan_amount, 'Opening balance', CURDATE()
+FROM margins
+WHERE loan_amount <> 0;
@@ -0,0 +1,47 @@
+-- Create interest_benchmark_rates table
+-- Benchmark rate for margin loan interest; each rate applies from its effective date
+CREATE TABLE IF NOT EXISTS interest_benchmark_rates (
+    effective_date DATE PRIMARY KEY,
+    rate DECIMAL(8, 6) NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+-- Create interest_rate_tiers table
+-- Each band of the loan balance from min_balance up to the next tier is charged the benchmark plus spread
+CREATE TABLE IF NOT EXISTS interest_rate_tiers (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    min_balance DECIMAL(20, 4) NOT NULL,
+    spread DECIMAL(8, 6) NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_interest_rate_tiers_min_balance (min_balance)
+) ENGINE=InnoDB;
+
+-- Create interest_accruals table
+-- One accrual per margin loan per day, capitalized into the loan by a cash ledger entry after month end
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    client_id BIGINT NOT NULL,
+    accrual_date DATE NOT NULL,
+    loan_balance DECIMAL(20, 4) NOT NULL,
+    benchmark_rate DECIMAL(8, 6) NOT NULL,
+    annual_rate DECIMAL(8, 6) NOT NULL,
+    interest DECIMAL(20, 4) NOT NULL,
+    cash_transaction_id BIGINT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_interest_accruals_client_date (client_id, accrual_date),
+    CONSTRAINT fk_interest_accruals_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_interest_accruals_cash_transaction_id
+        FOREIGN KEY (cash_transaction_id) REFERENCES cash_transactions(id)
+        ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+-- Insert sample benchmark rate and tiers
+INSERT INTO interest_benchmark_rates (effective_date, rate) VALUES
+(CURDATE(), 0.053000);
+
+INSERT INTO interest_rate_tiers (min_balance, spread) VALUES
+(0, 0.015000),
+(100000, 0.010000),
+(1000000, 0.005000);
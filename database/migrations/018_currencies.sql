@@ -0,0 +1,16 @@
+-- Currency each symbol is quoted in and each account is held in
+ALTER TABLE market_data
+ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER current_price;
+
+ALTER TABLE margins
+ADD COLUMN base_currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER client_id;
+
+-- Create fx_rates table
+-- Latest rate of each currency pair: one from_currency is worth rate to_currency
+CREATE TABLE IF NOT EXISTS fx_rates (
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    rate DECIMAL(20, 8) NOT NULL,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (from_currency, to_currency)
+) ENGINE=InnoDB;
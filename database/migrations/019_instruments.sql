@@ -0,0 +1,60 @@
+-- Create instruments table
+-- Reference data of each symbol. asset_class is one of equity, etf, adr or
+-- preferred; status is one of active, halted or delisted. Positions can only be
+-- opened in active instruments, in whole lots of lot_size. Positions in
+-- instruments that are not marginable are charged their full market value.
+CREATE TABLE IF NOT EXISTS instruments (
+    symbol VARCHAR(10) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    asset_class VARCHAR(16) NOT NULL DEFAULT 'equity',
+    exchange VARCHAR(16) NOT NULL DEFAULT '',
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    sector VARCHAR(50) NULL,
+    lot_size INT NOT NULL DEFAULT 1,
+    marginable BOOLEAN NOT NULL DEFAULT TRUE,
+    status VARCHAR(10) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_instruments_sector (sector),
+    INDEX idx_instruments_asset_class (asset_class)
+) ENGINE=InnoDB;
+
+-- Insert reference data for sample symbols
+INSERT INTO instruments (symbol, name, exchange) VALUES
+('AAPL', 'Apple Inc.', 'NASDAQ'),
+('MSFT', 'Microsoft Corporation', 'NASDAQ'),
+('NVDA', 'NVIDIA Corporation', 'NASDAQ'),
+('AMD', 'Advanced Micro Devices, Inc.', 'NASDAQ'),
+('INTC', 'Intel Corporation', 'NASDAQ'),
+('GOOGL', 'Alphabet Inc. Class A', 'NASDAQ'),
+('META', 'Meta Platforms, Inc.', 'NASDAQ'),
+('DIS', 'The Walt Disney Company', 'NYSE'),
+('NFLX', 'Netflix, Inc.', 'NASDAQ'),
+('AMZN', 'Amazon.com, Inc.', 'NASDAQ'),
+('TSLA', 'Tesla, Inc.', 'NASDAQ'),
+('JPM', 'JPMorgan Chase & Co.', 'NYSE'),
+('PFE', 'Pfizer Inc.', 'NYSE'),
+('JNJ', 'Johnson & Johnson', 'NYSE'),
+('KO', 'The Coca-Cola Company', 'NYSE'),
+('PEP', 'PepsiCo, Inc.', 'NASDAQ');
+
+-- Move the sector classification into the instrument master, adding any
+-- classified, priced or held symbol not listed above under its own name
+INSERT INTO instruments (symbol, name, sector)
+SELECT symbol, symbol, sector FROM symbol_sectors
+ON DUPLICATE KEY UPDATE sector = VALUES(sector);
+
+INSERT IGNORE INTO instruments (symbol, name, currency)
+SELECT md.symbol, md.symbol, md.currency
+FROM market_data md
+WHERE md.timestamp = (SELECT MAX(timestamp) FROM market_data WHERE symbol = md.symbol);
+
+INSERT IGNORE INTO instruments (symbol, name)
+SELECT DISTINCT symbol, symbol FROM positions;
+
+DROP TABLE symbol_sectors;
+
+-- Maintenance rules may match an instrument's sector or asset class
+ALTER TABLE maintenance_rules
+ADD COLUMN sector VARCHAR(50) NULL AFTER symbol,
+ADD COLUMN asset_class VARCHAR(16) NULL AFTER sector;
@@ -0,0 +1,52 @@
+-- Create corporate_actions table
+-- action_type is one of split, reverse_split, cash_dividend or symbol_change.
+-- Splits give ratio_new shares for every ratio_old held; dividends pay
+-- amount_per_share in the symbol's currency on pay_date to holders on ex_date.
+-- status moves from pending to applied on ex_date, then to paid for dividends,
+-- or to cancelled.
+CREATE TABLE IF NOT EXISTS corporate_actions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    action_type VARCHAR(20) NOT NULL,
+    ex_date DATE NOT NULL,
+    pay_date DATE NULL,
+    ratio_new INT NULL,
+    ratio_old INT NULL,
+    amount_per_share DECIMAL(20, 6) NULL,
+    new_symbol VARCHAR(10) NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    applied_at TIMESTAMP NULL,
+    paid_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_corporate_actions_status_ex_date (status, ex_date),
+    INDEX idx_corporate_actions_symbol (symbol)
+) ENGINE=InnoDB;
+
+-- Create corporate_action_adjustments table
+-- The change an applied action made to each client's position. cash_amount is
+-- the cash in lieu of fractional shares or the dividend, in the symbol's
+-- currency, posted to the cash ledger as cash_transaction_id.
+CREATE TABLE IF NOT EXISTS corporate_action_adjustments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    action_id BIGINT NOT NULL,
+    client_id BIGINT NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    quantity_before INT NOT NULL,
+    quantity_after INT NOT NULL,
+    cost_basis_before DECIMAL(20, 4) NOT NULL,
+    cost_basis_after DECIMAL(20, 4) NOT NULL,
+    cash_amount DECIMAL(20, 4) NOT NULL DEFAULT 0,
+    cash_transaction_id BIGINT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_corporate_action_adjustments_action_id
+        FOREIGN KEY (action_id) REFERENCES corporate_actions(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_corporate_action_adjustments_client_id
+        FOREIGN KEY (client_id) REFERENCES margins(client_id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_corporate_action_adjustments_cash_transaction_id
+        FOREIGN KEY (cash_transaction_id) REFERENCES cash_transactions(id)
+        ON DELETE SET NULL
+) ENGINE=InnoDB;
@@ -0,0 +1,13 @@
+-- The last error applying or paying a corporate action, cleared once it succeeds
+ALTER TABLE corporate_actions
+ADD COLUMN last_error VARCHAR(255) NULL AFTER description,
+ADD COLUMN failed_at TIMESTAMP NULL AFTER paid_at;
+
+-- Lots relieved by a split's cash in lieu of fractional shares have no trade;
+-- action_id records the split instead
+ALTER TABLE lot_reliefs
+MODIFY COLUMN trade_id BIGINT NULL,
+ADD COLUMN action_id BIGINT NULL AFTER trade_id,
+ADD CONSTRAINT fk_lot_reliefs_action_id
+    FOREIGN KEY (action_id) REFERENCES corporate_actions(id)
+    ON DELETE CASCADE;
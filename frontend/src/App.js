@@ -5,7 +5,9 @@ import { createTheme } from '@mui/material/styles';
 import Dashboard from './pages/Dashboard';
 import Positions from './pages/Positions';
 import Margin from './pages/Margin';
+import Login from './pages/Login';
 import Layout from './components/Layout';
+import { AuthProvider, useAuth } from './contexts/AuthContext';
 import { MarginProvider } from './contexts/MarginContext';
 
 // Create a theme instance
@@ -26,21 +28,35 @@ const theme = createTheme({
   },
 });
 
+// The API requires a signed in user, so nothing else is shown until then
+function AuthenticatedApp() {
+  const { token } = useAuth();
+  if (!token) {
+    return <Login />;
+  }
+
+  return (
+    <MarginProvider>
+      <Router>
+        <Layout>
+          <Routes>
+            <Route path="/" element={<Dashboard />} />
+            <Route path="/positions" element={<Positions />} />
+            <Route path="/margin" element={<Margin />} />
+          </Routes>
+        </Layout>
+      </Router>
+    </MarginProvider>
+  );
+}
+
 function App() {
   return (
     <ThemeProvider theme={theme}>
       <CssBaseline />
-      <MarginProvider>
-        <Router>
-          <Layout>
-            <Routes>
-              <Route path="/" element={<Dashboard />} />
-              <Route path="/positions" element={<Positions />} />
-              <Route path="/margin" element={<Margin />} />
-            </Routes>
-          </Layout>
-        </Router>
-      </MarginProvider>
+      <AuthProvider>
+        <AuthenticatedApp />
+      </AuthProvider>
     </ThemeProvider>
   );
 }
@@ -3,6 +3,7 @@ import { useNavigate, useLocation } from 'react-router-dom';
 import {
   AppBar,
   Box,
+  Button,
   CssBaseline,
   Drawer,
   IconButton,
@@ -19,6 +20,7 @@ import {
   AccountBalance as PositionsIcon,
   Warning as MarginIcon,
 } from '@mui/icons-material';
+import { useAuth } from '../contexts/AuthContext';
 
 const drawerWidth = 240;
 
@@ -26,6 +28,7 @@ function Layout({ children }) {
   const [mobileOpen, setMobileOpen] = React.useState(false);
   const navigate = useNavigate();
   const location = useLocation();
+  const { user, logout } = useAuth();
 
   const handleDrawerToggle = () => {
     setMobileOpen(!mobileOpen);
@@ -76,9 +79,15 @@ function Layout({ children }) {
           >
             <MenuIcon />
           </IconButton>
-          <Typography variant="h6" noWrap component="div">
+          <Typography variant="h6" noWrap component="div" sx={{ flexGrow: 1 }}>
             Mini Risk Monitoring
           </Typography>
+          <Typography variant="body2" sx={{ mr: 2 }}>
+            {user?.username}
+          </Typography>
+          <Button color="inherit" onClick={logout}>
+            Sign out
+          </Button>
         </Toolbar>
       </AppBar>
       <Box
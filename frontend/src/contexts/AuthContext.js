@@ -0,0 +1,84 @@
+import React, { createContext, useContext, useState, useEffect } from 'react';
+import axios from 'axios';
+
+const TOKEN_KEY = 'minirisk_token';
+const USER_KEY = 'minirisk_user';
+
+// Every API request carries the bearer token of the signed in user
+axios.interceptors.request.use((config) => {
+  const token = localStorage.getItem(TOKEN_KEY);
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
+const AuthContext = createContext();
+
+function storedUser() {
+  try {
+    return JSON.parse(localStorage.getItem(USER_KEY));
+  } catch (err) {
+    return null;
+  }
+}
+
+export function AuthProvider({ children }) {
+  const [token, setToken] = useState(() => localStorage.getItem(TOKEN_KEY));
+  const [user, setUser] = useState(storedUser);
+
+  const login = async (username, password) => {
+    const response = await axios.post('http://localhost:8080/api/auth/login', {
+      username,
+      password,
+    });
+    localStorage.setItem(TOKEN_KEY, response.data.token);
+    localStorage.setItem(USER_KEY, JSON.stringify(response.data.user));
+    setToken(response.data.token);
+    setUser(response.data.user);
+  };
+
+  const logout = () => {
+    localStorage.removeItem(TOKEN_KEY);
+    localStorage.removeItem(USER_KEY);
+    setToken(null);
+    setUser(null);
+  };
+
+  useEffect(() => {
+    // Sign out when the token expires or the account is disabled
+    const interceptor = axios.interceptors.response.use(
+      (response) => response,
+      (error) => {
+        if (error.response?.status === 401 && !error.config.url.endsWith('/api/auth/login')) {
+          logout();
+        }
+        return Promise.reject(error);
+      }
+    );
+    return () => axios.interceptors.response.eject(interceptor);
+  }, []);
+
+  return (
+    <AuthContext.Provider
+      value={{
+        token,
+        user,
+        // Staff users are not linked to a client and view client 1
+        clientId: user?.client_id ?? 1,
+        login,
+        logout,
+      }}
+    >
+      {children}
+    </AuthContext.Provider>
+  );
+}
+
+export function useAuth() {
+  const context = useContext(AuthContext);
+  if (!context) {
+    throw new Error('useAuth must be used within an AuthProvider');
+  }
+  return context;
+}
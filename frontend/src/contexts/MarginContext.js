@@ -1,14 +1,16 @@
 import React, { createContext, useContext, useState, useEffect } from 'react';
 import axios from 'axios';
+import { useAuth } from './AuthContext';
 
 const MarginContext = createContext();
 
 export function MarginProvider({ children }) {
+  const { clientId: defaultClientId } = useAuth();
   const [marginData, setMarginData] = useState(null);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
 
-  const fetchMarginData = async (clientId = 1) => {
+  const fetchMarginData = async (clientId = defaultClientId) => {
     try {
       setLoading(true);
       const response = await axios.get(`http://localhost:8080/api/margin/status/${clientId}`);
@@ -0,0 +1,54 @@
+import React, { useState } from 'react';
+import { Alert, Box, Button, Paper, TextField, Typography } from '@mui/material';
+import { useAuth } from '../contexts/AuthContext';
+
+function Login() {
+  const { login } = useAuth();
+  const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
+  const [error, setError] = useState(null);
+
+  const handleSubmit = async (event) => {
+    event.preventDefault();
+    try {
+      await login(username, password);
+    } catch (err) {
+      setError(err.response?.data?.error || err.message);
+    }
+  };
+
+  return (
+    <Box sx={{ display: 'flex', justifyContent: 'center', mt: 12 }}>
+      <Paper component="form" onSubmit={handleSubmit} sx={{ p: 4, width: 360 }}>
+        <Typography variant="h5" gutterBottom>
+          Sign in
+        </Typography>
+        {error && (
+          <Alert severity="error" sx={{ mb: 2 }}>
+            {error}
+          </Alert>
+        )}
+        <TextField
+          label="Username"
+          fullWidth
+          margin="normal"
+          value={username}
+          onChange={(e) => setUsername(e.target.value)}
+        />
+        <TextField
+          label="Password"
+          type="password"
+          fullWidth
+          margin="normal"
+          value={password}
+          onChange={(e) => setPassword(e.target.value)}
+        />
+        <Button type="submit" variant="contained" color="primary" fullWidth sx={{ mt: 2 }}>
+          Sign in
+        </Button>
+      </Paper>
+    </Box>
+  );
+}
+
+export default Login;
@@ -12,8 +12,10 @@ import {
 } from '@mui/material';
 import { DataGrid } from '@mui/x-data-grid';
 import axios from 'axios';
+import { useAuth } from '../contexts/AuthContext';
 
 function Positions() {
+  const { clientId } = useAuth();
   const [positions, setPositions] = useState([]);
   const [loading, setLoading] = useState(true);
   const [open, setOpen] = useState(false);
@@ -51,7 +53,7 @@ function Positions() {
 
   const fetchPositions = async () => {
     try {
-      const response = await axios.get('http://localhost:8080/api/positions/1');
+      const response = await axios.get(`http://localhost:8080/api/positions/${clientId}`);
       setPositions(response.data);
     } catch (error) {
       console.error('Error fetching positions:', error);
@@ -81,7 +83,7 @@ function Positions() {
     try {
       await axios.post('http://localhost:8080/api/positions', {
         ...newPosition,
-        clientId: 1,
+        clientId,
       });
       fetchPositions();
       handleClose();